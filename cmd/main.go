@@ -16,19 +16,37 @@ import (
 func main() {
 	initial.InitLogger()
 	initial.InitConfig()
+	initial.InitJWTKeys()
 
 	initial.InitDB()
 	initial.InitRedis()
 	initial.InitKafka()
+	initial.InitStorage()
+	initial.InitMailer()
 
 	service.StartConsumer()
 
+	// 清理重复的好友关系，之后 AutoMigrate 才能建立唯一索引
+	initial.DedupRelations()
+
 	// 自动迁移 (Auto Migrate)
-	if err := global.DB.AutoMigrate(&models.User{}, &models.Message{}, &models.Relation{}, &models.Group{}, &models.GroupMember{}, &models.FriendRequest{}); err != nil {
+	if err := global.DB.AutoMigrate(&models.User{}, &models.Message{}, &models.Relation{}, &models.Group{}, &models.GroupMember{}, &models.FriendRequest{}, &models.FriendTag{}, &models.Session{}, &models.LoginAudit{}, &models.RecoveryCode{}, &models.UserIdentity{}, &models.Media{}, &models.MediaUpload{}, &models.MediaUploadChunk{}, &models.ModerationHit{}); err != nil {
 		global.Log.Fatal("Database auto migration failed")
 	}
 	global.Log.Info("Database auto migration success")
 
+	// 过期的好友申请 (依赖 friend_requests 表已建好)
+	service.StartFriendRequestJanitor()
+
+	// 清理放弃的分片上传 (依赖 media_uploads 表已建好)
+	service.StartMediaUploadJanitor()
+
+	// 聊天记录全文索引 (依赖 messages 表已建好)
+	service.InitMessageIndex()
+
+	// 内容审核规则
+	service.InitModeration()
+
 	r := routers.InitRouter()
 
 	port := global.Config.GetString("server.port")
@@ -0,0 +1,41 @@
+// mock-oidc 本地模拟的 OIDC 身份提供方，用于联调单点登录
+//
+//	go run ./cmd/mock-oidc -addr :9000 -email alice@example.com
+//
+// 然后在 config.yaml 中配置 oidc.issuer: "http://localhost:9000"，client_id / client_secret 与这里一致
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"go-chat/internal/pkg/oidc/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "监听地址")
+	issuer := flag.String("issuer", "http://localhost:9000", "对外地址，需与 oidc.issuer 一致")
+	clientID := flag.String("client-id", "go-chat", "client_id")
+	clientSecret := flag.String("client-secret", "secret", "client_secret")
+	subject := flag.String("sub", "mock-user-1", "登录用户的 sub")
+	email := flag.String("email", "alice@example.com", "登录用户的邮箱")
+	emailVerified := flag.Bool("email-verified", true, "邮箱是否已验证")
+	name := flag.String("name", "Alice", "登录用户的姓名")
+	username := flag.String("username", "", "登录用户的 preferred_username")
+	flag.Parse()
+
+	p, err := oidctest.New(*issuer, *clientID, *clientSecret, oidctest.User{
+		Subject:           *subject,
+		Email:             *email,
+		EmailVerified:     *emailVerified,
+		Name:              *name,
+		PreferredUsername: *username,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("mock oidc provider listening on %s (issuer %s)", *addr, *issuer)
+	log.Fatal(http.ListenAndServe(*addr, p))
+}
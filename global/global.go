@@ -1,6 +1,10 @@
 package global
 
 import (
+	"go-chat/internal/pkg/jwtkeys"
+	"go-chat/internal/pkg/mailer"
+	"go-chat/internal/pkg/storage"
+
 	"github.com/IBM/sarama"
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
@@ -14,6 +18,9 @@ var (
 	Log           *zap.Logger
 	RDB           *redis.Client
 	KafkaProducer sarama.SyncProducer
+	Storage       storage.Storage
+	Mailer        mailer.Mailer
+	JWTKeys       *jwtkeys.KeySet
 )
 
 type KafkaTopic struct {
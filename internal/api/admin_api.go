@@ -0,0 +1,118 @@
+package api
+
+import (
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/pkg/utils"
+	"go-chat/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminApi 管理后台接口，只有 admin.user_ids 中的用户可以访问
+type AdminApi struct{}
+
+// ListModerationHits 审核记录
+// @Summary 查看内容审核记录
+// @Description 消息命中审核规则 (屏蔽、待审核、拒绝) 的记录，时间倒序分页。review_status=1 为待人工审核的消息
+// @Tags 管理后台
+// @Security ApiKeyAuth
+// @Produce json
+// @Param review_status query int false "0无需处理 1待审核 2通过 3未通过，不传为全部"
+// @Param action query string false "mask / review / reject"
+// @Param user_id query int false "只看该用户发送的"
+// @Param page query int false "页码，默认1"
+// @Param page_size query int false "每页数量，默认20，最多100"
+// @Success 200 {object} utils.Response{data=service.ModerationHitPageDTO}
+// @Router /admin/moderation/hits [get]
+func (a *AdminApi) ListModerationHits(c *gin.Context) {
+	var req service.ModerationHitsReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	page, err := service.ListModerationHits(c.Request.Context(), req)
+	if err != nil {
+		global.Log.Error("list moderation hits failed", zap.Error(err))
+		utils.ServerError(c, "获取审核记录失败")
+		return
+	}
+	utils.Success(c, page)
+}
+
+// ApproveModerationHit 审核通过
+// @Summary 审核通过
+// @Description 待审核的消息恢复正常并投递给接收者，发送者收到 message.approved 事件
+// @Tags 管理后台
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "审核记录ID"
+// @Success 200 {object} utils.Response
+// @Router /admin/moderation/hits/{id}/approve [post]
+func (a *AdminApi) ApproveModerationHit(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	if err := service.ApproveModerationHit(c.Request.Context(), c.GetUint("userID"), uint(id)); err != nil {
+		moderationError(c, err)
+		return
+	}
+	utils.SuccessWithMsg(c, "已通过", nil)
+}
+
+// RejectModerationHit 审核不通过
+// @Summary 审核不通过
+// @Description 待审核的消息不再投递，发送者收到 message.removed 事件 (带上说明)
+// @Tags 管理后台
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "审核记录ID"
+// @Param data body service.RejectModerationReq false "告知发送者的说明"
+// @Success 200 {object} utils.Response
+// @Router /admin/moderation/hits/{id}/reject [post]
+func (a *AdminApi) RejectModerationHit(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	var req service.RejectModerationReq
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.Fail(c, "说明不能超过255个字符")
+			return
+		}
+	}
+
+	if err := service.RejectModerationHit(c.Request.Context(), c.GetUint("userID"), uint(id), req.Note); err != nil {
+		moderationError(c, err)
+		return
+	}
+	utils.SuccessWithMsg(c, "已驳回", nil)
+}
+
+func moderationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrModerationHitNotFound):
+		utils.FailWithCode(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrModerationNotPending):
+		utils.FailWithCode(c, http.StatusConflict, err.Error())
+	default:
+		global.Log.Error("review moderation hit failed", zap.Error(err))
+		utils.ServerError(c, "操作失败")
+	}
+}
@@ -1,29 +1,52 @@
 package api
 
 import (
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/pkg/protocol"
 	"go-chat/internal/pkg/utils"
 	"go-chat/internal/service"
 	"net/http"
+	"strconv"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
 )
 
 type ChatApi struct{}
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // 允许跨域
-	},
+var (
+	upgrader     websocket.Upgrader
+	upgraderOnce sync.Once
+)
+
+// getUpgrader 首次建连时按配置构建 Upgrader，保证读取时配置已加载
+func getUpgrader() *websocket.Upgrader {
+	upgraderOnce.Do(func() {
+		upgrader = websocket.Upgrader{
+			ReadBufferSize:    global.Config.GetInt("websocket.read_buffer_size"),
+			WriteBufferSize:   global.Config.GetInt("websocket.write_buffer_size"),
+			EnableCompression: global.Config.GetBool("websocket.compression"), // 协商 permessage-deflate
+			CheckOrigin: func(r *http.Request) bool {
+				return true // 允许跨域
+			},
+			Subprotocols: protocol.Subprotocols(), // 支持的编码格式，按优先级协商
+		}
+	})
+	return &upgrader
 }
 
 // Connect WebSocket
 // @Summary 建立WebSocket连接
 // @Description 用户通过JWT Token建立WebSocket实时通信连接
+// @Description 通过 Sec-WebSocket-Protocol 协商编码：gochat.proto (Protobuf 二进制帧) 或 gochat.json (默认 JSON 文本帧)
 // @Tags 聊天模块
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
+// @Param v query int false "信封协议版本，不传为旧版扁平格式"
 // @Success 101 {string} string "切换协议到WebSocket"
 // @Router /ws [get]
 func (api *ChatApi) Connect(c *gin.Context) {
@@ -47,17 +70,36 @@ func (api *ChatApi) Connect(c *gin.Context) {
 	}
 
 	// 升级 HTTP -> WebSocket
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	conn, err := getUpgrader().Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		utils.FailWithCode(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	// 单条消息大小上限，超出后以 1009 (Message Too Big) 关闭连接
+	if maxSize := global.Config.GetInt64("websocket.max_message_size"); maxSize > 0 {
+		conn.SetReadLimit(maxSize)
+	}
+	// 压缩级别只在协商成功后生效，未协商时设置无副作用
+	if level := global.Config.GetInt("websocket.compression_level"); level != 0 {
+		if err := conn.SetCompressionLevel(level); err != nil {
+			global.Log.Warn("invalid websocket compression level", zap.Int("level", level))
+		}
+	}
+
 	// 创建 Client 对象
 	client := &service.Client{
-		UserID: userID,
-		Socket: conn,
-		Send:   make(chan []byte),
+		UserID:    userID,
+		SessionID: c.GetString("sessionID"),
+		TokenID:   c.GetString("tokenID"),
+		Socket:    conn,
+		Send:      make(chan []byte),
+		Done:      make(chan struct{}),
+		Codec:     protocol.CodecFor(conn.Subprotocol()),
+	}
+	// 新版客户端通过 ?v=1 声明使用信封格式，未声明按旧版处理
+	if v, err := strconv.Atoi(c.Query("v")); err == nil && v > 0 && v <= protocol.Version {
+		client.Version = v
 	}
 
 	// 注册到 Manager
@@ -96,3 +138,67 @@ func (api *ChatApi) GetHistory(c *gin.Context) {
 
 	utils.SuccessWithMsg(c, "历史记录拉取成功", messages)
 }
+
+// GetMessageRequests 获取陌生人消息请求
+// @Summary 获取陌生人消息请求
+// @Description 获取非好友发给我的消息 (仅在 chat.stranger_policy 为 request 时产生)
+// @Tags 聊天模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]service.MessageDTO}
+// @Router /chat/requests [get]
+func (api *ChatApi) GetMessageRequests(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	messages, err := service.GetMessageRequests(c.Request.Context(), userID)
+	if err != nil {
+		utils.ServerError(c, "获取消息请求失败")
+		return
+	}
+
+	utils.Success(c, messages)
+}
+
+// SearchMessages 搜索聊天记录
+// @Summary 搜索聊天记录
+// @Description 在我参与的单聊和所在群的群聊中全文检索消息，支持按会话、发送者、时间过滤，分页返回并高亮关键词
+// @Tags 聊天模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Param keyword query string true "关键词，多个词以空格分隔，须全部命中"
+// @Param peer_id query int false "只搜与该用户的单聊"
+// @Param group_id query int false "只搜该群"
+// @Param sender_id query int false "只搜该用户发送的消息"
+// @Param start query int false "起始时间 (毫秒时间戳)"
+// @Param end query int false "结束时间 (毫秒时间戳)"
+// @Param page query int false "页码，默认1"
+// @Param page_size query int false "每页数量，默认20，最多50"
+// @Success 200 {object} utils.Response{data=service.MessageSearchPageDTO}
+// @Router /chat/search [get]
+func (api *ChatApi) SearchMessages(c *gin.Context) {
+	var req service.SearchMessagesReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "请输入搜索关键词")
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	userID := c.GetUint("userID")
+
+	page, err := service.SearchMessages(c.Request.Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrNotGroupMember) {
+			utils.FailWithCode(c, http.StatusForbidden, err.Error())
+		} else {
+			utils.ServerError(c, "搜索失败")
+		}
+		return
+	}
+
+	utils.Success(c, page)
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"go-chat/internal/pkg/utils"
+	"go-chat/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InviteGroupMember 邀请入群
+// @Summary 邀请用户加入群聊
+// @Description 群成员可以邀请其他用户入群，与对方之间存在拉黑关系 (任意一方) 时不能邀请
+// @Tags 群聊模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body service.InviteGroupMemberReq true "邀请参数"
+// @Success 200 {object} utils.Response
+// @Router /group/invite [post]
+func InviteGroupMember(c *gin.Context) {
+	var req service.InviteGroupMemberReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	if err := service.InviteGroupMember(c.Request.Context(), userID, req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMsg(c, "已邀请", nil)
+}
@@ -0,0 +1,331 @@
+package api
+
+import (
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"go-chat/internal/pkg/utils"
+	"go-chat/internal/service"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type MediaApi struct{}
+
+// Upload 上传聊天附件
+// @Summary 上传聊天附件
+// @Description 上传图片 (JPEG/PNG/GIF)、音频 (MP3/M4A/WAV/OGG)、视频 (MP4/MOV/WebM) 或任意文件，类型根据文件内容识别。返回的 id 作为 WebSocket 消息的 media_id 发送
+// @Tags 聊天模块
+// @Security ApiKeyAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "文件"
+// @Param as_file formData bool false "作为普通文件发送 (不按图片/音视频处理)"
+// @Success 200 {object} utils.Response{data=service.MediaDTO}
+// @Router /media/upload [post]
+func (m *MediaApi) Upload(c *gin.Context) {
+	// 解析表单之前先限制请求体大小，超大的请求不必读完
+	maxSize := service.MediaUploadMaxSize()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.FailWithCode(c, http.StatusRequestEntityTooLarge, "文件不能超过 "+strconv.FormatInt(maxSize>>20, 10)+"MB")
+			return
+		}
+		utils.Fail(c, "请选择要上传的文件")
+		return
+	}
+	asFile, _ := strconv.ParseBool(c.PostForm("as_file"))
+
+	f, err := file.Open()
+	if err != nil {
+		utils.ServerError(c, "读取文件失败")
+		return
+	}
+	defer f.Close()
+
+	userID := c.GetUint("userID")
+	media, err := service.UploadMedia(c.Request.Context(), userID, f, file.Size, file.Filename, asFile)
+	if err != nil {
+		var tooLarge *service.MediaTooLargeError
+		switch {
+		case errors.As(err, &tooLarge):
+			utils.FailWithCode(c, http.StatusRequestEntityTooLarge, err.Error())
+		case errors.Is(err, service.ErrMediaEmpty), errors.Is(err, service.ErrMediaMalformed),
+			errors.Is(err, service.ErrMediaType), errors.Is(err, service.ErrImageTooLarge):
+			utils.Fail(c, err.Error())
+		default:
+			global.Log.Error("upload media failed", zap.Uint("user_id", userID), zap.Error(err))
+			utils.ServerError(c, "上传失败")
+		}
+		return
+	}
+
+	utils.SuccessWithMsg(c, "上传成功", media)
+}
+
+// Get 下载附件 (需登录)
+// @Summary 下载附件
+// @Description 只有上传者和附件所在会话的参与者 (单聊双方、群成员) 可以访问。variant 为缩略图尺寸 (如 480x360)，不传为原文件。S3 存储时跳转到临时地址
+// @Tags 聊天模块
+// @Security ApiKeyAuth
+// @Produce octet-stream
+// @Param id path int true "附件ID"
+// @Param variant query string false "缩略图尺寸"
+// @Success 200 {file} binary
+// @Router /media/{id} [get]
+func (m *MediaApi) Get(c *gin.Context) {
+	media, ok := m.accessibleMedia(c)
+	if !ok {
+		return
+	}
+	serveMedia(c, media, c.Query("variant"), "private, max-age=300")
+}
+
+// GetURL 获取附件的签名下载地址
+// @Summary 获取附件签名地址
+// @Description 签名地址无需登录即可访问，用于 <img>/<video> 标签、CDN 缓存和离线下载；消息中的地址过期后调用此接口重新获取
+// @Tags 聊天模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "附件ID"
+// @Param variant query string false "缩略图尺寸"
+// @Success 200 {object} utils.Response{data=service.MediaURLDTO}
+// @Router /media/{id}/url [get]
+func (m *MediaApi) GetURL(c *gin.Context) {
+	media, ok := m.accessibleMedia(c)
+	if !ok {
+		return
+	}
+	variant := c.Query("variant")
+	if variant != "" && !service.HasThumbnail(media, variant) {
+		utils.FailWithCode(c, http.StatusNotFound, service.ErrMediaNotFound.Error())
+		return
+	}
+	url, expires := service.SignMediaURL(media.ID, variant)
+	utils.Success(c, service.MediaURLDTO{URL: url, ExpiresAt: expires.Unix()})
+}
+
+// Download 通过签名地址下载附件 (无需登录)
+// @Summary 签名地址下载附件
+// @Description 地址由消息中的 attachment.url 或 /media/{id}/url 给出，过期或签名不符时返回 403
+// @Tags 聊天模块
+// @Produce octet-stream
+// @Param id path int true "附件ID"
+// @Param variant query string false "缩略图尺寸"
+// @Param expires query int true "过期时间"
+// @Param sig query string true "签名"
+// @Success 200 {file} binary
+// @Router /media/{id}/download [get]
+func (m *MediaApi) Download(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.FailWithCode(c, http.StatusForbidden, service.ErrMediaLinkInvalid.Error())
+		return
+	}
+	variant := c.Query("variant")
+	remaining, err := service.VerifyMediaURL(uint(id), variant, c.Query("expires"), c.Query("sig"))
+	if err != nil {
+		utils.FailWithCode(c, http.StatusForbidden, err.Error())
+		return
+	}
+
+	media, err := service.GetMedia(c.Request.Context(), uint(id))
+	if err != nil {
+		mediaError(c, err)
+		return
+	}
+	// 地址在过期前内容不会变化，允许 CDN 缓存到过期为止
+	serveMedia(c, media, variant, "public, max-age="+strconv.Itoa(int(remaining.Seconds())))
+}
+
+// accessibleMedia 读取路径中的附件并校验当前用户的访问权限，失败时已写入响应
+func (m *MediaApi) accessibleMedia(c *gin.Context) (*models.Media, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.Fail(c, "参数错误")
+		return nil, false
+	}
+	media, err := service.GetAccessibleMedia(c.Request.Context(), c.GetUint("userID"), uint(id))
+	if err != nil {
+		mediaError(c, err)
+		return nil, false
+	}
+	return media, true
+}
+
+// serveMedia 输出附件内容；存储后端支持预签名时跳转过去，由存储直接提供下载
+func serveMedia(c *gin.Context, media *models.Media, variant, cacheControl string) {
+	content, err := service.OpenMedia(c.Request.Context(), media, variant)
+	if err != nil {
+		mediaError(c, err)
+		return
+	}
+	if content.RedirectURL != "" {
+		// 临时地址很快过期，跳转本身不能长期缓存
+		c.Header("Cache-Control", "private, max-age=60")
+		c.Redirect(http.StatusFound, content.RedirectURL)
+		return
+	}
+	defer content.Body.Close()
+
+	c.Header("Cache-Control", cacheControl)
+	c.Header("Content-Type", content.ContentType)
+	c.Header("Content-Disposition", content.Disposition)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	if rs, ok := content.Body.(io.ReadSeeker); ok {
+		// 支持 Range 请求，音视频可以拖动进度
+		http.ServeContent(c.Writer, c.Request, "", content.ModTime, rs)
+		return
+	}
+	c.DataFromReader(http.StatusOK, content.Size, content.ContentType, content.Body, nil)
+}
+
+func mediaError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrMediaNotFound) {
+		utils.FailWithCode(c, http.StatusNotFound, err.Error())
+		return
+	}
+	global.Log.Error("serve media failed", zap.Error(err))
+	utils.ServerError(c, "读取附件失败")
+}
+
+// InitUpload 创建分片上传任务
+// @Summary 创建分片上传任务
+// @Description 大文件分片上传：创建任务 → 逐片 PUT (可并发、可断点续传) → complete 合并，返回与普通上传相同的附件信息
+// @Tags 聊天模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param data body service.InitUploadRequest true "文件信息"
+// @Success 200 {object} utils.Response{data=service.UploadSessionDTO}
+// @Router /media/uploads [post]
+func (m *MediaApi) InitUpload(c *gin.Context) {
+	var req service.InitUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, "参数错误: "+err.Error())
+		return
+	}
+	session, err := service.InitMediaUpload(c.Request.Context(), c.GetUint("userID"), req)
+	if err != nil {
+		uploadError(c, err)
+		return
+	}
+	utils.Success(c, session)
+}
+
+// GetUpload 查询分片上传进度
+// @Summary 查询分片上传进度
+// @Description 返回已上传的分片序号，断线重连后只需上传缺少的分片
+// @Tags 聊天模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Param upload_id path string true "上传任务ID"
+// @Success 200 {object} utils.Response{data=service.UploadSessionDTO}
+// @Router /media/uploads/{upload_id} [get]
+func (m *MediaApi) GetUpload(c *gin.Context) {
+	session, err := service.GetMediaUpload(c.Request.Context(), c.GetUint("userID"), c.Param("upload_id"))
+	if err != nil {
+		uploadError(c, err)
+		return
+	}
+	utils.Success(c, session)
+}
+
+// UploadChunk 上传一个分片
+// @Summary 上传分片
+// @Description 请求体为分片的原始内容，X-Chunk-SHA256 头为该分片的 SHA-256 (十六进制)。除最后一片外大小必须等于 chunk_size，同一分片可以重复上传
+// @Tags 聊天模块
+// @Security ApiKeyAuth
+// @Accept octet-stream
+// @Produce json
+// @Param upload_id path string true "上传任务ID"
+// @Param index path int true "分片序号 (从 0 开始)"
+// @Param X-Chunk-SHA256 header string true "分片的 SHA-256"
+// @Success 200 {object} utils.Response
+// @Router /media/uploads/{upload_id}/chunks/{index} [put]
+func (m *MediaApi) UploadChunk(c *gin.Context) {
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		utils.Fail(c, service.ErrUploadChunkIndex.Error())
+		return
+	}
+	checksum := c.GetHeader("X-Chunk-SHA256")
+	if checksum == "" {
+		utils.Fail(c, "缺少分片校验值 X-Chunk-SHA256")
+		return
+	}
+
+	err = service.UploadChunk(c.Request.Context(), c.GetUint("userID"), c.Param("upload_id"), index, c.Request.Body, checksum)
+	if err != nil {
+		uploadError(c, err)
+		return
+	}
+	utils.SuccessWithMsg(c, "分片已上传", nil)
+}
+
+// CompleteUpload 合并分片，生成附件
+// @Summary 完成分片上传
+// @Description 所有分片上传后调用，合并并按普通上传的规则校验类型和大小，返回附件信息 (id 作为消息的 media_id)。重复调用返回同一附件
+// @Tags 聊天模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Param upload_id path string true "上传任务ID"
+// @Success 200 {object} utils.Response{data=service.MediaDTO}
+// @Router /media/uploads/{upload_id}/complete [post]
+func (m *MediaApi) CompleteUpload(c *gin.Context) {
+	media, err := service.CompleteMediaUpload(c.Request.Context(), c.GetUint("userID"), c.Param("upload_id"))
+	if err != nil {
+		uploadError(c, err)
+		return
+	}
+	utils.SuccessWithMsg(c, "上传成功", media)
+}
+
+// AbortUpload 取消分片上传
+// @Summary 取消分片上传
+// @Description 删除上传任务和已上传的分片
+// @Tags 聊天模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Param upload_id path string true "上传任务ID"
+// @Success 200 {object} utils.Response
+// @Router /media/uploads/{upload_id} [delete]
+func (m *MediaApi) AbortUpload(c *gin.Context) {
+	if err := service.AbortMediaUpload(c.Request.Context(), c.GetUint("userID"), c.Param("upload_id")); err != nil {
+		uploadError(c, err)
+		return
+	}
+	utils.SuccessWithMsg(c, "已取消", nil)
+}
+
+func uploadError(c *gin.Context, err error) {
+	var tooLarge *service.MediaTooLargeError
+	switch {
+	case errors.Is(err, service.ErrUploadNotFound):
+		utils.FailWithCode(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrUploadBusy), errors.Is(err, service.ErrUploadCompleted):
+		utils.FailWithCode(c, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrUploadTooMany):
+		utils.FailWithCode(c, http.StatusTooManyRequests, err.Error())
+	case errors.As(err, &tooLarge):
+		utils.FailWithCode(c, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, service.ErrUploadChunkIndex), errors.Is(err, service.ErrUploadChunkSize),
+		errors.Is(err, service.ErrUploadChecksum), errors.Is(err, service.ErrUploadIncomplete),
+		errors.Is(err, service.ErrUploadFileChecksum),
+		errors.Is(err, service.ErrMediaEmpty), errors.Is(err, service.ErrMediaMalformed),
+		errors.Is(err, service.ErrMediaType), errors.Is(err, service.ErrImageTooLarge):
+		utils.Fail(c, err.Error())
+	default:
+		global.Log.Error("media upload failed", zap.Uint("user_id", c.GetUint("userID")), zap.Error(err))
+		utils.ServerError(c, "上传失败")
+	}
+}
@@ -1,9 +1,11 @@
 package api
 
 import (
+	"errors"
 	"go-chat/internal/pkg/utils"
 	"go-chat/internal/service"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -79,28 +81,78 @@ func SearchUser(c *gin.Context) {
 		return
 	}
 
-	userDTO, err := service.SearchUserByUsername(c.Request.Context(), username)
+	userDTO, err := service.SearchUserByUsername(c.Request.Context(), c.GetUint("userID"), username)
 	if err != nil {
-		utils.Fail(c, err.Error())
+		if errors.Is(err, service.ErrUserNotFound) {
+			utils.Fail(c, err.Error())
+		} else {
+			utils.ServerError(c, "搜索失败")
+		}
 		return
 	}
 
 	utils.Success(c, userDTO)
 }
 
+// SearchUsers 模糊搜索用户
+// @Summary 模糊搜索用户
+// @Description 按用户名前缀、昵称模糊匹配搜索用户，手机号/邮箱需精确匹配且对方允许被搜索，分页返回
+// @Tags 用户模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Param keyword query string true "关键词"
+// @Param page query int false "页码，默认1"
+// @Param page_size query int false "每页数量，默认20，最多50"
+// @Success 200 {object} utils.Response{data=service.UserPageDTO}
+// @Router /user/search/fuzzy [get]
+func SearchUsers(c *gin.Context) {
+	var req service.SearchUsersReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "请输入搜索关键词")
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	userID := c.GetUint("userID")
+
+	page, err := service.SearchUsers(c.Request.Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrSearchTooFrequent) {
+			utils.FailWithCode(c, http.StatusTooManyRequests, err.Error())
+		} else {
+			utils.ServerError(c, "搜索失败")
+		}
+		return
+	}
+
+	utils.Success(c, page)
+}
+
 // GetFriendList 获取好友列表
 // @Summary 获取好友列表
-// @Description 获取当前用户的所有好友列表
+// @Description 获取当前用户的所有好友列表，可按分组过滤
 // @Tags 好友模块
 // @Security ApiKeyAuth
 // @Accept json
 // @Produce json
+// @Param tag_id query int false "好友分组ID，0 表示未分组，不传返回全部"
 // @Success 200 {object} utils.Response{data=[]service.UserResponseDTO}
 // @Router /friend/list [get]
 func GetFriendList(c *gin.Context) {
+	var req service.FriendListReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
 	userID := c.GetUint("userID")
 
-	friendList, err := service.GetFriendList(c.Request.Context(), userID)
+	friendList, err := service.GetFriendList(c.Request.Context(), userID, req)
 	if err != nil {
 		utils.ServerError(c, "获取好友列表失败")
 		return
@@ -156,3 +208,341 @@ func MarkMessagesRead(c *gin.Context) {
 
 	utils.SuccessWithMsg(c, "标记成功", nil)
 }
+
+// BlockUser 拉黑用户
+// @Summary 拉黑用户
+// @Description 拉黑后对方无法给我发消息、发送好友申请，也看不到我的在线状态
+// @Tags 好友模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body service.BlockUserReq true "拉黑参数"
+// @Success 200 {object} utils.Response
+// @Router /friend/block [post]
+func BlockUser(c *gin.Context) {
+	var req service.BlockUserReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	if err := service.BlockUser(c.Request.Context(), userID, req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMsg(c, "已拉黑", nil)
+}
+
+// UnblockUser 解除拉黑
+// @Summary 解除拉黑
+// @Description 将用户移出黑名单，拉黑前是好友的恢复好友关系
+// @Tags 好友模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body service.BlockUserReq true "解除拉黑参数"
+// @Success 200 {object} utils.Response
+// @Router /friend/unblock [post]
+func UnblockUser(c *gin.Context) {
+	var req service.BlockUserReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	if err := service.UnblockUser(c.Request.Context(), userID, req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMsg(c, "已解除拉黑", nil)
+}
+
+// GetBlockedList 获取黑名单
+// @Summary 获取黑名单
+// @Description 获取当前用户拉黑的用户列表
+// @Tags 好友模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]service.UserResponseDTO}
+// @Router /friend/blocked [get]
+func GetBlockedList(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	users, err := service.GetBlockedList(c.Request.Context(), userID)
+	if err != nil {
+		utils.ServerError(c, "获取黑名单失败")
+		return
+	}
+
+	utils.Success(c, users)
+}
+
+// DeleteFriend 删除好友
+// @Summary 删除好友
+// @Description 解除双向好友关系，并通过 WebSocket 通知对方
+// @Tags 好友模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body service.DeleteFriendReq true "删除好友参数"
+// @Success 200 {object} utils.Response
+// @Router /friend/delete [post]
+func DeleteFriend(c *gin.Context) {
+	var req service.DeleteFriendReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	if err := service.DeleteFriend(c.Request.Context(), userID, req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMsg(c, "已删除好友", nil)
+}
+
+// UpdateFriendRemark 修改好友备注
+// @Summary 修改好友备注
+// @Description 设置好友的备注名，传空字符串清除备注
+// @Tags 好友模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body service.UpdateFriendRemarkReq true "备注参数"
+// @Success 200 {object} utils.Response
+// @Router /friend/remark [post]
+func UpdateFriendRemark(c *gin.Context) {
+	var req service.UpdateFriendRemarkReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	if err := service.UpdateFriendRemark(c.Request.Context(), userID, req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMsg(c, "备注已更新", nil)
+}
+
+// GetFriendTags 获取好友分组
+// @Summary 获取好友分组
+// @Description 获取当前用户自定义的好友分组及组内人数
+// @Tags 好友模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]service.FriendTagDTO}
+// @Router /friend/tags [get]
+func GetFriendTags(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	tags, err := service.GetFriendTags(c.Request.Context(), userID)
+	if err != nil {
+		utils.ServerError(c, "获取分组失败")
+		return
+	}
+
+	utils.Success(c, tags)
+}
+
+// CreateFriendTag 新建好友分组
+// @Summary 新建好友分组
+// @Tags 好友模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body service.FriendTagReq true "分组参数"
+// @Success 200 {object} utils.Response{data=service.FriendTagDTO}
+// @Router /friend/tags [post]
+func CreateFriendTag(c *gin.Context) {
+	var req service.FriendTagReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	tag, err := service.CreateFriendTag(c.Request.Context(), userID, req)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMsg(c, "分组已创建", tag)
+}
+
+// RenameFriendTag 重命名好友分组
+// @Summary 重命名好友分组
+// @Tags 好友模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path int true "分组ID"
+// @Param request body service.FriendTagReq true "分组参数"
+// @Success 200 {object} utils.Response
+// @Router /friend/tags/{id} [put]
+func RenameFriendTag(c *gin.Context) {
+	tagID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	var req service.FriendTagReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	if err := service.RenameFriendTag(c.Request.Context(), userID, uint(tagID), req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMsg(c, "分组已重命名", nil)
+}
+
+// DeleteFriendTag 删除好友分组
+// @Summary 删除好友分组
+// @Description 删除分组，组内好友变为未分组
+// @Tags 好友模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path int true "分组ID"
+// @Success 200 {object} utils.Response
+// @Router /friend/tags/{id} [delete]
+func DeleteFriendTag(c *gin.Context) {
+	tagID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	if err := service.DeleteFriendTag(c.Request.Context(), userID, uint(tagID)); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMsg(c, "分组已删除", nil)
+}
+
+// SetFriendTag 设置好友分组
+// @Summary 设置好友分组
+// @Description 把好友移入指定分组，tag_id 为 0 时移出分组
+// @Tags 好友模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body service.SetFriendTagReq true "分组参数"
+// @Success 200 {object} utils.Response
+// @Router /friend/set-tag [post]
+func SetFriendTag(c *gin.Context) {
+	var req service.SetFriendTagReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	if err := service.SetFriendTag(c.Request.Context(), userID, req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMsg(c, "分组已更新", nil)
+}
+
+// GetSentRequests 获取我发出的申请
+// @Summary 获取我发出的好友申请
+// @Description 获取当前用户发出的好友申请及其处理状态 (0待处理 1已同意 2已拒绝 3已撤回 4已过期)
+// @Tags 好友模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]service.SentFriendRequestDTO}
+// @Router /friend/requests/sent [get]
+func GetSentRequests(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	requests, err := service.GetSentRequests(c.Request.Context(), userID)
+	if err != nil {
+		utils.ServerError(c, "获取申请列表失败")
+		return
+	}
+
+	utils.Success(c, requests)
+}
+
+// WithdrawFriendRequest 撤回好友申请
+// @Summary 撤回好友申请
+// @Description 撤回自己发出且对方尚未处理的好友申请
+// @Tags 好友模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body service.WithdrawFriendRequestReq true "撤回参数"
+// @Success 200 {object} utils.Response
+// @Router /friend/withdraw [post]
+func WithdrawFriendRequest(c *gin.Context) {
+	var req service.WithdrawFriendRequestReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	if err := service.WithdrawFriendRequest(c.Request.Context(), userID, req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMsg(c, "申请已撤回", nil)
+}
+
+// RecommendFriends 可能认识的人
+// @Summary 可能认识的人
+// @Description 按共同好友和共同群推荐用户，已排除好友、拉黑关系和待处理的申请
+// @Tags 好友模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Param limit query int false "返回数量，默认10，最多50"
+// @Success 200 {object} utils.Response{data=[]service.RecommendUserDTO}
+// @Router /friend/recommend [get]
+func RecommendFriends(c *gin.Context) {
+	var req service.RecommendReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.FailWithCode(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+
+	userID := c.GetUint("userID")
+
+	users, err := service.RecommendFriends(c.Request.Context(), userID, req.Limit)
+	if err != nil {
+		utils.ServerError(c, "获取推荐失败")
+		return
+	}
+
+	utils.Success(c, users)
+}
@@ -6,8 +6,13 @@ import (
 	"go-chat/internal/models"
 	"go-chat/internal/pkg/utils"
 	"go-chat/internal/service"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 type UserApi struct{}
@@ -19,15 +24,56 @@ type RegisterRequest struct {
 }
 
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name" binding:"max=64"` // 设备名，显示在登录设备列表中
 }
 
-// UpdateUserRequest 更新用户信息请求
+// UpdateUserRequest 更新用户信息请求，不传的字段保持不变
 type UpdateUserRequest struct {
-	Nickname string `json:"nickname" binding:"max=64"`
-	Avatar   string `json:"avatar" binding:"max=255"`
-	Email    string `json:"email" binding:"email,max=128"`
+	Nickname *string `json:"nickname" binding:"omitempty,max=64"`
+	Avatar   *string `json:"avatar" binding:"omitempty,max=255"`
+	Email    *string `json:"email" binding:"omitempty,email,max=128"`
+	Phone    *string `json:"phone" binding:"omitempty,numeric,max=20"`
+}
+
+// ChangePasswordRequest 修改密码请求
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// VerifyEmailRequest 验证邮箱请求
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ForgotPasswordRequest 找回密码请求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 重置密码请求
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// TwoFactorCodeRequest 提交 TOTP 验证码
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorRequest 关闭两步验证请求
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP 验证码或恢复码
+}
+
+// LoginTwoFactorRequest 两步验证登录请求
+type LoginTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // TOTP 验证码或恢复码
 }
 
 // UserInfoResponse 用户信息响应
@@ -37,6 +83,15 @@ type UserInfoResponse struct {
 	Nickname string `json:"nickname"`
 	Avatar   string `json:"avatar"`
 	Email    string `json:"email"`
+	Phone    string `json:"phone"`
+
+	EmailVerified bool `json:"email_verified"` // 邮箱是否已验证
+	TOTPEnabled   bool `json:"totp_enabled"`   // 是否已开启两步验证
+}
+
+// AvatarResponse 上传头像响应
+type AvatarResponse struct {
+	Avatar string `json:"avatar"` // 新头像地址
 }
 
 // Register godoc
@@ -64,7 +119,8 @@ func (u *UserApi) Register(c *gin.Context) {
 
 // Login godoc
 // @Summary 用户登录
-// @Description 用户通过账号密码登录
+// @Description 用户通过账号密码登录，连续失败过多会被临时锁定 (429，Retry-After 为剩余秒数)，账号被禁用返回 403
+// @Description 开启了两步验证时返回 two_factor_required 和 challenge_token，需再调用 /user/login/2fa
 // @Tags 用户模块
 // @Accept json
 // @Produce json
@@ -78,18 +134,141 @@ func (u *UserApi) Login(c *gin.Context) {
 		return
 	}
 	userService := service.UserService{}
-	resp, err := userService.Login(c.Request.Context(), req.Username, req.Password)
+	device := service.DeviceInfo{
+		Name:      req.DeviceName,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	resp, err := userService.Login(c.Request.Context(), req.Username, req.Password, device)
 	if err != nil {
-		if errors.Is(err, service.ErrInvalidPassword) {
-			utils.Fail(c, "用户名或密码错误")
-		} else {
-			utils.Fail(c, err.Error())
-		}
+		loginFailed(c, err)
+		return
+	}
+	if resp.TwoFactorRequired {
+		utils.SuccessWithMsg(c, "请输入两步验证码", resp)
+		return
+	}
+	utils.SuccessWithMsg(c, "登录成功", resp)
+}
+
+// loginFailed 登录各步骤共用的错误响应
+func loginFailed(c *gin.Context, err error) {
+	var locked *service.LoginLockedError
+	switch {
+	case errors.As(err, &locked):
+		c.Header("Retry-After", strconv.Itoa(int(locked.RetryAfter.Seconds())+1))
+		utils.FailWithCode(c, http.StatusTooManyRequests, locked.Error())
+	case errors.Is(err, service.ErrUserDisabled):
+		utils.FailWithCode(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrInvalidPassword):
+		utils.Fail(c, "用户名或密码错误")
+	case errors.Is(err, service.ErrInvalidChallenge):
+		utils.Unauthorized(c, err.Error())
+	default:
+		utils.Fail(c, err.Error())
+	}
+}
+
+// LoginTwoFactor 两步验证登录
+// @Summary 两步验证登录
+// @Description 提交登录返回的 challenge_token 和验证器中的 6 位验证码 (或恢复码)，验证通过后签发 Token
+// @Tags 用户模块
+// @Accept json
+// @Produce json
+// @Param request body LoginTwoFactorRequest true "挑战 Token 和验证码"
+// @Success 200 {object} Response{data=service.LoginResponseDTO}
+// @Router /user/login/2fa [post]
+func (u *UserApi) LoginTwoFactor(c *gin.Context) {
+	var req LoginTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	resp, err := service.LoginTwoFactor(c.Request.Context(), req.ChallengeToken, req.Code)
+	if err != nil {
+		loginFailed(c, err)
 		return
 	}
 	utils.SuccessWithMsg(c, "登录成功", resp)
 }
 
+// OIDCLogin 单点登录
+// @Summary 单点登录
+// @Description 跳转到企业身份提供方 (OIDC) 登录，登录完成后回调 /user/oidc/callback
+// @Tags 用户模块
+// @Success 302
+// @Router /user/oidc/login [get]
+func (u *UserApi) OIDCLogin(c *gin.Context) {
+	authURL, err := service.OIDCLoginURL(c.Request.Context())
+	if err != nil {
+		oidcError(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback 单点登录回调
+// @Summary 单点登录回调
+// @Description 身份提供方登录完成后跳转到这里。配置了 oidc.frontend_redirect 时带着 Token 跳转到前端 (放在 URL fragment 中)，否则直接返回 JSON
+// @Tags 用户模块
+// @Produce json
+// @Param state query string true "发起登录时生成的 state"
+// @Param code query string true "授权码"
+// @Success 200 {object} Response{data=service.LoginResponseDTO}
+// @Router /user/oidc/callback [get]
+func (u *UserApi) OIDCCallback(c *gin.Context) {
+	// 身份提供方返回的错误 (如用户取消授权)
+	if errMsg := c.Query("error"); errMsg != "" {
+		oidcFailed(c, service.ErrOIDCFailed)
+		return
+	}
+
+	device := service.DeviceInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	resp, err := service.OIDCCallback(c.Request.Context(), c.Query("state"), c.Query("code"), device)
+	if err != nil {
+		oidcFailed(c, err)
+		return
+	}
+
+	if redirect := global.Config.GetString("oidc.frontend_redirect"); redirect != "" {
+		fragment := url.Values{}
+		fragment.Set("token", resp.Token)
+		fragment.Set("refresh_token", resp.RefreshToken)
+		fragment.Set("expires_in", strconv.FormatInt(resp.ExpiresIn, 10))
+		c.Redirect(http.StatusFound, redirect+"#"+fragment.Encode())
+		return
+	}
+	utils.SuccessWithMsg(c, "登录成功", resp)
+}
+
+// oidcFailed 回调失败时，配置了前端地址则带着错误信息跳回前端，否则直接返回错误
+func oidcFailed(c *gin.Context, err error) {
+	if redirect := global.Config.GetString("oidc.frontend_redirect"); redirect != "" {
+		c.Redirect(http.StatusFound, redirect+"#"+url.Values{"error": {err.Error()}}.Encode())
+		return
+	}
+	oidcError(c, err)
+}
+
+func oidcError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrOIDCDisabled):
+		utils.FailWithCode(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrOIDCUnavailable):
+		utils.FailWithCode(c, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, service.ErrOIDCSignupDenied), errors.Is(err, service.ErrOIDCEmailConflict):
+		utils.FailWithCode(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrOIDCState), errors.Is(err, service.ErrOIDCFailed), errors.Is(err, service.ErrOIDCEmailUnverified):
+		utils.Unauthorized(c, err.Error())
+	default:
+		loginFailed(c, err)
+	}
+}
+
 // GetUserInfo 获取当前登录用户信息
 // @Summary 获取当前用户信息
 // @Description 获取当前登录用户的详细信息
@@ -140,5 +319,508 @@ func (u *UserApi) GetFullUserInfo(c *gin.Context) {
 		Nickname: user.Nickname,
 		Avatar:   user.Avatar,
 		Email:    user.Email,
+		Phone:    user.Phone,
+
+		EmailVerified: user.EmailVerified,
+		TOTPEnabled:   user.TOTPEnabled,
 	})
 }
+
+// UpdateProfile 修改个人资料
+// @Summary 修改个人资料
+// @Description 修改昵称、头像地址、邮箱、手机号，不传的字段保持不变
+// @Tags 用户模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body UpdateUserRequest true "个人资料"
+// @Success 200 {object} utils.Response{data=UserInfoResponse}
+// @Router /user/profile [put]
+func (u *UserApi) UpdateProfile(c *gin.Context) {
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	userService := service.UserService{}
+	user, err := userService.UpdateProfile(c.Request.Context(), userID, req.Nickname, req.Avatar, req.Email, req.Phone)
+	if err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMsg(c, "修改成功", UserInfoResponse{
+		ID:       user.ID,
+		Username: user.Username,
+		Nickname: user.Nickname,
+		Avatar:   user.Avatar,
+		Email:    user.Email,
+		Phone:    user.Phone,
+
+		EmailVerified: user.EmailVerified,
+		TOTPEnabled:   user.TOTPEnabled,
+	})
+}
+
+// ChangePassword 修改密码
+// @Summary 修改密码
+// @Description 验证原密码后修改密码，成功后其他设备全部下线，当前设备保持登录
+// @Tags 用户模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body ChangePasswordRequest true "原密码和新密码"
+// @Success 200 {object} utils.Response
+// @Router /user/password [post]
+func (u *UserApi) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	userService := service.UserService{}
+	err := userService.ChangePassword(c.Request.Context(), userID, req.OldPassword, req.NewPassword, c.GetString("sessionID"))
+	if err != nil {
+		if errors.Is(err, service.ErrWrongOldPassword) || errors.Is(err, service.ErrSamePassword) {
+			utils.Fail(c, err.Error())
+		} else {
+			utils.ServerError(c, "修改密码失败")
+		}
+		return
+	}
+
+	utils.SuccessWithMsg(c, "密码已修改，其他设备需要重新登录", nil)
+}
+
+// UploadAvatar 上传头像
+// @Summary 上传头像
+// @Description 上传 JPEG/PNG/GIF 图片，服务端居中裁剪为正方形并缩放后保存，同时更新个人头像
+// @Tags 用户模块
+// @Security ApiKeyAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param avatar formData file true "头像图片"
+// @Success 200 {object} utils.Response{data=AvatarResponse}
+// @Router /user/avatar [post]
+func (u *UserApi) UploadAvatar(c *gin.Context) {
+	file, err := c.FormFile("avatar")
+	if err != nil {
+		utils.Fail(c, "请选择要上传的图片")
+		return
+	}
+
+	maxSize := global.Config.GetInt64("avatar.max_size")
+	if maxSize <= 0 {
+		maxSize = 5 << 20
+	}
+	if file.Size > maxSize {
+		utils.FailWithCode(c, http.StatusRequestEntityTooLarge, "图片不能超过 "+strconv.FormatInt(maxSize>>20, 10)+"MB")
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		utils.ServerError(c, "读取图片失败")
+		return
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxSize))
+	if err != nil {
+		utils.ServerError(c, "读取图片失败")
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	userService := service.UserService{}
+	url, err := userService.UploadAvatar(c.Request.Context(), userID, data)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidImage) || errors.Is(err, service.ErrImageTooLarge) {
+			utils.Fail(c, err.Error())
+		} else {
+			global.Log.Error("upload avatar failed", zap.Uint("user_id", userID), zap.Error(err))
+			utils.ServerError(c, "上传头像失败")
+		}
+		return
+	}
+
+	utils.SuccessWithMsg(c, "头像已更新", AvatarResponse{Avatar: url})
+}
+
+// GetPrivacySettings 获取隐私设置
+// @Summary 获取隐私设置
+// @Description 获取是否允许别人通过手机号/邮箱搜索到我
+// @Tags 用户模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=service.PrivacySettingsDTO}
+// @Router /user/privacy [get]
+func (u *UserApi) GetPrivacySettings(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	settings, err := service.GetPrivacySettings(c.Request.Context(), userID)
+	if err != nil {
+		utils.Fail(c, "用户不存在")
+		return
+	}
+
+	utils.Success(c, settings)
+}
+
+// UpdatePrivacySettings 修改隐私设置
+// @Summary 修改隐私设置
+// @Description 设置是否允许别人通过手机号/邮箱搜索到我，不传的字段保持不变
+// @Tags 用户模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body service.UpdatePrivacyReq true "隐私设置"
+// @Success 200 {object} utils.Response
+// @Router /user/privacy [put]
+func (u *UserApi) UpdatePrivacySettings(c *gin.Context) {
+	var req service.UpdatePrivacyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	if err := service.UpdatePrivacySettings(c.Request.Context(), userID, req); err != nil {
+		utils.Fail(c, "修改失败")
+		return
+	}
+
+	utils.SuccessWithMsg(c, "修改成功", nil)
+}
+
+// RefreshToken 刷新 Token
+// @Summary 刷新 Token
+// @Description 用 Refresh Token 换取新的 Access Token 和 Refresh Token，旧的 Refresh Token 随即失效
+// @Tags 用户模块
+// @Accept json
+// @Produce json
+// @Param request body service.RefreshTokenReq true "Refresh Token"
+// @Success 200 {object} utils.Response{data=service.LoginResponseDTO}
+// @Router /user/refresh [post]
+func (u *UserApi) RefreshToken(c *gin.Context) {
+	var req service.RefreshTokenReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	resp, err := service.RefreshToken(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) {
+			utils.Unauthorized(c, err.Error())
+		} else if errors.Is(err, service.ErrUserDisabled) {
+			utils.FailWithCode(c, http.StatusForbidden, err.Error())
+		} else {
+			utils.ServerError(c, "刷新失败")
+		}
+		return
+	}
+
+	utils.SuccessWithMsg(c, "刷新成功", resp)
+}
+
+// Logout 退出登录
+// @Summary 退出登录
+// @Description 吊销当前 Access Token (并断开用它建立的 WebSocket)，带上 Refresh Token 则一并作废
+// @Tags 用户模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body service.LogoutReq false "Refresh Token"
+// @Success 200 {object} utils.Response
+// @Router /user/logout [post]
+func (u *UserApi) Logout(c *gin.Context) {
+	var req service.LogoutReq
+	// 请求体可选
+	_ = c.ShouldBindJSON(&req)
+
+	userID := c.GetUint("userID")
+	tokenID := c.GetString("tokenID")
+	sessionID := c.GetString("sessionID")
+	expiresAt := c.GetTime("tokenExpiresAt")
+
+	if err := service.Logout(c.Request.Context(), userID, tokenID, sessionID, expiresAt, req.RefreshToken); err != nil {
+		utils.ServerError(c, "退出登录失败")
+		return
+	}
+
+	utils.SuccessWithMsg(c, "已退出登录", nil)
+}
+
+// GetSessions 获取登录设备列表
+// @Summary 获取登录设备列表
+// @Description 获取当前账号所有有效的登录会话 (设备名、IP、User-Agent、登录及最后活跃时间)
+// @Tags 用户模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=[]service.SessionDTO}
+// @Router /user/sessions [get]
+func (u *UserApi) GetSessions(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	sessions, err := service.GetSessions(c.Request.Context(), userID, c.GetString("sessionID"))
+	if err != nil {
+		utils.ServerError(c, "获取登录设备失败")
+		return
+	}
+
+	utils.Success(c, sessions)
+}
+
+// RevokeSession 下线指定设备
+// @Summary 下线指定设备
+// @Description 吊销指定登录会话，该设备的 Token 立即失效，WebSocket 以 1008 断开
+// @Tags 用户模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path string true "会话ID"
+// @Success 200 {object} utils.Response
+// @Router /user/sessions/{id} [delete]
+func (u *UserApi) RevokeSession(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	if err := service.RevokeSession(c.Request.Context(), userID, c.Param("id")); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			utils.FailWithCode(c, http.StatusNotFound, err.Error())
+		} else {
+			utils.ServerError(c, "下线失败")
+		}
+		return
+	}
+
+	utils.SuccessWithMsg(c, "已下线该设备", nil)
+}
+
+// RevokeOtherSessions 下线其他所有设备
+// @Summary 下线其他所有设备
+// @Description 吊销除当前设备外的所有登录会话
+// @Tags 用户模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=int} "下线的设备数量"
+// @Router /user/sessions/revoke-others [post]
+func (u *UserApi) RevokeOtherSessions(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	count, err := service.RevokeOtherSessions(c.Request.Context(), userID, c.GetString("sessionID"))
+	if err != nil {
+		utils.ServerError(c, "下线失败")
+		return
+	}
+
+	utils.SuccessWithMsg(c, "已下线其他设备", count)
+}
+
+// SendEmailVerification 发送邮箱验证邮件
+// @Summary 发送邮箱验证邮件
+// @Description 向当前绑定的邮箱发送验证链接 (注册和修改邮箱后会自动发送一次)
+// @Tags 用户模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response
+// @Router /user/email/verify/send [post]
+func (u *UserApi) SendEmailVerification(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	err := service.SendEmailVerification(c.Request.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMailTooFrequent):
+			utils.FailWithCode(c, http.StatusTooManyRequests, err.Error())
+		case errors.Is(err, service.ErrNoEmail), errors.Is(err, service.ErrEmailVerified):
+			utils.Fail(c, err.Error())
+		default:
+			global.Log.Error("send verification email failed", zap.Uint("user_id", userID), zap.Error(err))
+			utils.ServerError(c, "邮件发送失败")
+		}
+		return
+	}
+
+	utils.SuccessWithMsg(c, "验证邮件已发送", nil)
+}
+
+// VerifyEmail 验证邮箱
+// @Summary 验证邮箱
+// @Description 使用邮件中的 Token 完成邮箱验证，Token 只能使用一次
+// @Tags 用户模块
+// @Accept json
+// @Produce json
+// @Param request body VerifyEmailRequest true "验证 Token"
+// @Success 200 {object} utils.Response
+// @Router /user/email/verify [post]
+func (u *UserApi) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	if err := service.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		if errors.Is(err, service.ErrInvalidMailToken) || errors.Is(err, service.ErrEmailChanged) {
+			utils.Fail(c, err.Error())
+		} else {
+			utils.ServerError(c, "验证失败")
+		}
+		return
+	}
+
+	utils.SuccessWithMsg(c, "邮箱验证成功", nil)
+}
+
+// ForgotPassword 找回密码
+// @Summary 找回密码
+// @Description 向已验证的邮箱发送重置密码链接，无论邮箱是否存在都返回成功
+// @Tags 用户模块
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "邮箱"
+// @Success 200 {object} utils.Response
+// @Router /user/password/forgot [post]
+func (u *UserApi) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	if err := service.ForgotPassword(c.Request.Context(), req.Email, c.ClientIP()); err != nil {
+		if errors.Is(err, service.ErrMailTooFrequent) {
+			utils.FailWithCode(c, http.StatusTooManyRequests, err.Error())
+		} else {
+			utils.ServerError(c, "请求失败")
+		}
+		return
+	}
+
+	utils.SuccessWithMsg(c, "如果该邮箱已绑定并验证，重置密码邮件将很快送达", nil)
+}
+
+// ResetPassword 重置密码
+// @Summary 重置密码
+// @Description 使用邮件中的 Token 设置新密码，Token 只能使用一次，成功后所有设备下线
+// @Tags 用户模块
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Token 和新密码"
+// @Success 200 {object} utils.Response
+// @Router /user/password/reset [post]
+func (u *UserApi) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	if err := service.ResetPassword(c.Request.Context(), req.Token, req.NewPassword); err != nil {
+		if errors.Is(err, service.ErrInvalidMailToken) {
+			utils.Fail(c, err.Error())
+		} else {
+			utils.ServerError(c, "重置密码失败")
+		}
+		return
+	}
+
+	utils.SuccessWithMsg(c, "密码已重置，请重新登录", nil)
+}
+
+// SetupTwoFactor 获取两步验证密钥
+// @Summary 获取两步验证密钥
+// @Description 生成新的 TOTP 密钥和 otpauth:// 地址 (用于生成二维码)，10 分钟内调用 /user/2fa/enable 确认后生效
+// @Tags 用户模块
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} utils.Response{data=service.TOTPSetupDTO}
+// @Router /user/2fa/setup [post]
+func (u *UserApi) SetupTwoFactor(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	setup, err := service.SetupTOTP(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrTOTPAlreadyEnabled) {
+			utils.Fail(c, err.Error())
+		} else {
+			utils.ServerError(c, "生成密钥失败")
+		}
+		return
+	}
+
+	utils.Success(c, setup)
+}
+
+// EnableTwoFactor 开启两步验证
+// @Summary 开启两步验证
+// @Description 提交验证器中的验证码确认密钥，开启两步验证并返回恢复码 (只显示这一次)
+// @Tags 用户模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorCodeRequest true "验证码"
+// @Success 200 {object} utils.Response{data=service.RecoveryCodesDTO}
+// @Router /user/2fa/enable [post]
+func (u *UserApi) EnableTwoFactor(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	codes, err := service.EnableTOTP(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTOTPCode) || errors.Is(err, service.ErrTOTPSetupExpired) ||
+			errors.Is(err, service.ErrTOTPAlreadyEnabled) {
+			utils.Fail(c, err.Error())
+		} else {
+			utils.ServerError(c, "开启两步验证失败")
+		}
+		return
+	}
+
+	utils.SuccessWithMsg(c, "两步验证已开启，请妥善保存恢复码", service.RecoveryCodesDTO{Codes: codes})
+}
+
+// DisableTwoFactor 关闭两步验证
+// @Summary 关闭两步验证
+// @Description 验证密码和验证码 (或恢复码) 后关闭两步验证，恢复码全部作废
+// @Tags 用户模块
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param request body DisableTwoFactorRequest true "密码和验证码"
+// @Success 200 {object} utils.Response
+// @Router /user/2fa/disable [post]
+func (u *UserApi) DisableTwoFactor(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.Fail(c, err.Error())
+		return
+	}
+
+	userID := c.GetUint("userID")
+
+	if err := service.DisableTOTP(c.Request.Context(), userID, req.Password, req.Code); err != nil {
+		if errors.Is(err, service.ErrTOTPNotEnabled) || errors.Is(err, service.ErrWrongPassword) ||
+			errors.Is(err, service.ErrInvalidTOTPCode) {
+			utils.Fail(c, err.Error())
+		} else {
+			utils.ServerError(c, "关闭两步验证失败")
+		}
+		return
+	}
+
+	utils.SuccessWithMsg(c, "两步验证已关闭", nil)
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"fmt"
+	"go-chat/global"
+	"go-chat/internal/pkg/jwk"
+	"go-chat/internal/pkg/jwtkeys"
+	"go-chat/internal/pkg/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// JWKS 公钥集合
+// @Summary JWT 公钥集合 (JWKS)
+// @Description 供其他服务验证 go-chat 签发的 Token：按 Token 头部的 kid 选择公钥，并校验 iss=go-chat。使用 HS256 时为空
+// @Tags 公共
+// @Produce json
+// @Success 200 {object} jwk.Set
+// @Router /.well-known/jwks.json [get]
+func JWKS(c *gin.Context) {
+	keys, err := utils.JWTKeys()
+	if err != nil {
+		global.Log.Error("load jwt keys failed", zap.Error(err))
+		utils.ServerError(c, "获取公钥失败")
+		return
+	}
+	var set jwk.Set
+	if set, err = keys.JWKS(); err != nil {
+		global.Log.Error("encode jwks failed", zap.Error(err))
+		utils.ServerError(c, "获取公钥失败")
+		return
+	}
+
+	// JWKS 按标准格式直接返回，不包在统一响应结构里
+	// 缓存时长与密钥的提前发布时间对应：新密钥启用前，缓存的 JWKS 都已过期
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwtkeys.JWKSMaxAge.Seconds())))
+	c.JSON(http.StatusOK, set)
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"go-chat/internal/pkg/utils"
+	"go-chat/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth 管理员鉴权，须放在 JWTAuth 之后
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !service.IsAdmin(c.GetUint("userID")) {
+			utils.FailWithCode(c, http.StatusForbidden, "无权访问")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
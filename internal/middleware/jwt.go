@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"go-chat/internal/pkg/utils"
+	"go-chat/internal/service"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -40,9 +41,23 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
+		// 已退出登录 / 被吊销的 Token
+		if service.IsTokenRevoked(c.Request.Context(), claims.ID, claims.SessionID) {
+			utils.Unauthorized(c, "Token 已失效，请重新登录")
+			c.Abort()
+			return
+		}
+
 		// 3. 将用户信息存入上下文，供后续 API 使用
 		c.Set("userID", claims.UserID)
 		c.Set("username", claims.Username)
+		c.Set("tokenID", claims.ID)
+		c.Set("sessionID", claims.SessionID)
+		if claims.ExpiresAt != nil {
+			c.Set("tokenExpiresAt", claims.ExpiresAt.Time)
+		}
+
+		service.TouchSession(c.Request.Context(), claims.SessionID)
 
 		c.Next() // 放行
 	}
@@ -9,10 +9,11 @@ type FriendRequest struct {
 	// 关联关系 (数据库里没有这一列，这是给 GORM 用的)
 	// gorm:"foreignKey:SenderID" 的意思是：
 	// "Sender 这个字段对应的 User模型，是通过本表的 SenderID 字段关联的"
-	Sender User `gorm:"foreignKey:SenderID" json:"-"`
+	Sender   User `gorm:"foreignKey:SenderID" json:"-"`
+	Receiver User `gorm:"foreignKey:ReceiverID" json:"-"`
 
 	Remark string `gorm:"size:255" json:"remark"`  // 申请附言
-	Status int    `gorm:"default:0" json:"status"` // 0:待处理, 1:已同意, 2:已拒绝
+	Status int    `gorm:"default:0" json:"status"` // 0:待处理, 1:已同意, 2:已拒绝, 3:已撤回, 4:已过期
 }
 
 func (FriendRequest) TableName() string {
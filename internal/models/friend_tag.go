@@ -0,0 +1,13 @@
+package models
+
+// FriendTag 好友分组 (用户自定义)
+// 好友通过 Relation.TagID 归入分组，一个好友同时只属于一个分组
+type FriendTag struct {
+	Model
+	OwnerID uint   `gorm:"index;not null" json:"owner_id"` // 分组所属用户
+	Name    string `gorm:"size:32;not null" json:"name"`   // 分组名称
+}
+
+func (FriendTag) TableName() string {
+	return "friend_tags"
+}
@@ -0,0 +1,15 @@
+package models
+
+// LoginAudit 登录失败审计记录
+type LoginAudit struct {
+	Model
+	Username  string `gorm:"size:64;index" json:"username"` // 尝试登录的用户名
+	UserID    uint   `gorm:"index" json:"user_id"`          // 用户不存在时为 0
+	IP        string `gorm:"size:64;index" json:"ip"`
+	UserAgent string `gorm:"size:255" json:"user_agent"`
+	Reason    string `gorm:"size:32" json:"reason"` // 失败原因，见 service.LoginFail*
+}
+
+func (*LoginAudit) TableName() string {
+	return "login_audits"
+}
@@ -0,0 +1,39 @@
+package models
+
+// 消息媒体类型 (Message.Media / Media.Kind)
+const (
+	MediaText  = 1 // 文本
+	MediaImage = 2 // 图片
+	MediaAudio = 3 // 音频
+	MediaVideo = 4 // 视频
+	MediaFile  = 5 // 文件
+)
+
+// Media 上传的附件，消息通过 MediaID 引用
+type Media struct {
+	Model
+	UserID     uint             `gorm:"index" json:"user_id"`               // 上传者
+	Kind       int              `json:"kind"`                               // 媒体类型: 2图片 3音频 4视频 5文件
+	StorageKey string           `gorm:"size:255" json:"-"`                  // 存储后端中的 key
+	FileName   string           `gorm:"size:255" json:"file_name"`          // 原始文件名 (仅用于展示)
+	MimeType   string           `gorm:"size:128" json:"mime_type"`          // 根据文件内容识别出的类型
+	Size       int64            `json:"size"`                               // 字节数
+	Width      int              `json:"width"`                              // 图片/视频宽度 (像素)
+	Height     int              `json:"height"`                             // 图片/视频高度 (像素)
+	DurationMs int64            `json:"duration_ms"`                        // 音视频时长 (毫秒)
+	SHA256     string           `gorm:"size:64;column:sha256" json:"-"`     // 内容摘要
+	Blurhash   string           `gorm:"size:64" json:"blurhash"`            // 图片的模糊占位图编码
+	Thumbnails []MediaThumbnail `gorm:"type:text;serializer:json" json:"-"` // 图片缩略图，按尺寸从小到大
+}
+
+// MediaThumbnail 图片缩略图，以 JSON 存在 Media.Thumbnails 中
+type MediaThumbnail struct {
+	StorageKey string `json:"key"`
+	MimeType   string `json:"mime_type,omitempty"` // 缩略图的编码格式 (JPEG 原图为 image/jpeg，其余为 image/png)
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+}
+
+func (*Media) TableName() string {
+	return "media"
+}
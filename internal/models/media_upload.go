@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// 分片上传任务状态 (MediaUpload.Status)
+const (
+	UploadPending    = 0 // 上传中
+	UploadAssembling = 1 // 合并中
+	UploadCompleted  = 2 // 已完成
+)
+
+// MediaUpload 分片上传任务，全部分片上传后合并为 Media
+type MediaUpload struct {
+	Model
+	UploadID    string    `gorm:"size:64;uniqueIndex" json:"upload_id"` // 对外的任务标识 (随机字符串)
+	UserID      uint      `gorm:"index" json:"user_id"`
+	FileName    string    `gorm:"size:255" json:"file_name"`
+	Size        int64     `json:"size"`       // 文件总字节数
+	ChunkSize   int64     `json:"chunk_size"` // 分片大小，最后一片可以更小
+	TotalChunks int       `json:"total_chunks"`
+	SHA256      string    `gorm:"size:64;column:sha256" json:"-"` // 客户端声明的整个文件摘要，为空时不校验
+	AsFile      bool      `json:"as_file"`                        // 作为普通文件发送
+	Status      int       `json:"status"`                         // 0上传中 1合并中 2已完成
+	MediaID     uint      `json:"media_id"`                       // 完成后生成的附件
+	ExpiresAt   time.Time `gorm:"index" json:"expires_at"`        // 超过该时间未完成的任务会被清理，每上传一片顺延
+}
+
+func (*MediaUpload) TableName() string {
+	return "media_uploads"
+}
+
+// MediaUploadChunk 已上传的分片，内容存放在存储后端
+type MediaUploadChunk struct {
+	ID         uint `gorm:"primarykey"`
+	UploadID   uint `gorm:"uniqueIndex:idx_upload_chunk"` // MediaUpload.ID
+	ChunkIndex int  `gorm:"uniqueIndex:idx_upload_chunk"` // 从 0 开始
+	Size       int64
+	SHA256     string `gorm:"size:64;column:sha256"`
+}
+
+func (*MediaUploadChunk) TableName() string {
+	return "media_upload_chunks"
+}
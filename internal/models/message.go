@@ -1,13 +1,34 @@
 package models
 
+// 消息状态 (Message.Status)
+const (
+	MessageNormal  = 0 // 正常
+	MessageHeld    = 1 // 内容审核中，暂不投递给接收者
+	MessageRemoved = 2 // 人工审核未通过
+)
+
 // Message 存储在数据库中的消息记录
 type Message struct {
 	Model
-	FromUserID uint   `gorm:"index" json:"from_user_id"` // 发送者
-	ToUserID   uint   `gorm:"index" json:"to_user_id"`   // 接收者
-	Content    string `gorm:"type:text" json:"content"`  // 内容 (文本或文件URL)
-	Type       int    `json:"type"`                      // TypeHeartbeat = 0 ,TypeLogin = 1 ,TypeSingleMsg = 2 ,TypeGroupMsg  = 3
-	Media      int    `json:"media"`                     // 媒体类型: 1文本 2图片 3音频
+	FromUserID uint   `gorm:"index" json:"from_user_id"`             // 发送者
+	ToUserID   uint   `gorm:"index" json:"to_user_id"`               // 接收者
+	Content    string `gorm:"type:text" json:"content"`              // 文本内容，媒体消息为附带的说明文字
+	Type       int    `json:"type"`                                  // TypeHeartbeat = 0 ,TypeLogin = 1 ,TypeSingleMsg = 2 ,TypeGroupMsg  = 3
+	Media      int    `json:"media"`                                 // 媒体类型: 1文本 2图片 3音频 4视频 5文件
+	MediaID    uint   `gorm:"index" json:"media_id,omitempty"`       // 引用的附件 (models.Media)，文本消息为 0
+	IsRequest  bool   `gorm:"default:false;index" json:"is_request"` // 陌生人消息请求 (非好友发送，进入对方的消息请求箱)
+	Status     int    `gorm:"default:0;index" json:"status"`         // 0正常 1审核中 2审核未通过，非正常状态的消息不出现在记录、搜索和未读数中
+
+	LinkPreview *LinkPreview `gorm:"type:text;serializer:json" json:"link_preview,omitempty"` // 文本中第一个链接的预览，发送后异步生成
+}
+
+// LinkPreview 链接预览 (网页的 Open Graph 信息)
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
 }
 
 func (*Message) TableName() string {
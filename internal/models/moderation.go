@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// 审核记录的人工处理状态 (ModerationHit.ReviewStatus)
+const (
+	ReviewNone     = 0 // 无需人工处理 (已屏蔽或已拒绝)
+	ReviewPending  = 1 // 待审核，消息暂不投递
+	ReviewApproved = 2 // 审核通过，消息已投递
+	ReviewRejected = 3 // 审核未通过
+)
+
+// ModerationHit 消息命中审核规则的记录，供管理员查看和处理
+type ModerationHit struct {
+	Model
+	UserID       uint              `gorm:"index" json:"user_id"`                     // 发送者
+	TargetID     uint              `json:"target_id"`                                // 接收者
+	MessageID    uint              `gorm:"index" json:"message_id"`                  // 入库的消息，被拒绝发送的为 0
+	Content      string            `gorm:"type:text" json:"content"`                 // 原始内容 (屏蔽处理前)
+	Action       string            `gorm:"size:16;index" json:"action"`              // 最终判定: mask / review / reject
+	Reason       string            `gorm:"size:255" json:"reason"`                   // 告知发送者的原因
+	Matches      []ModerationMatch `gorm:"type:text;serializer:json" json:"matches"` // 各过滤器的命中详情
+	ReviewStatus int               `gorm:"default:0;index" json:"review_status"`     // 0无需处理 1待审核 2通过 3未通过
+	ReviewerID   uint              `json:"reviewer_id,omitempty"`                    // 处理的管理员
+	ReviewNote   string            `gorm:"size:255" json:"review_note,omitempty"`    // 审核未通过时告知发送者的说明
+	ReviewedAt   *time.Time        `json:"reviewed_at,omitempty"`
+}
+
+// ModerationMatch 单个过滤器的命中
+type ModerationMatch struct {
+	Filter string `json:"filter"`
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+	Match  string `json:"match,omitempty"` // 命中的关键词、规则等
+}
+
+func (*ModerationHit) TableName() string {
+	return "moderation_hits"
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// RecoveryCode 两步验证恢复码，丢失验证器时代替验证码使用，每个只能用一次
+type RecoveryCode struct {
+	Model
+	UserID   uint       `gorm:"index" json:"user_id"`
+	CodeHash string     `gorm:"size:64" json:"-"` // 恢复码的 SHA-256 摘要
+	UsedAt   *time.Time `json:"used_at"`          // 使用时间，空表示未使用
+}
+
+func (*RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
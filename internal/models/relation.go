@@ -4,13 +4,15 @@ package models
 // 我们采用简单设计：两条记录代表双向好友
 // UserID=1, TargetID=2 (1的好友是2)
 // UserID=2, TargetID=1 (2的好友是1)
+// 同一 (OwnerID, TargetID) 只允许一条记录，好友/拉黑通过 Type 切换，删除一律物理删除
 type Relation struct {
 	Model
-	OwnerID        uint   `gorm:"index;not null" json:"owner_id"`                  // 谁的关系
-	TargetID       uint   `gorm:"index;not null" json:"target_id"`                 // 对应的好友ID
-	Type           int    `json:"type"`                                           // 1=好友, 2=拉黑
-	Desc           string `json:"desc"`                                           // 备注名
-	LastReadMsgID  uint   `gorm:"default:0;index" json:"last_read_msg_id"`        // 该用户在该会话中已读的最后一条消息ID
+	OwnerID       uint   `gorm:"uniqueIndex:idx_relation_owner_target;not null" json:"owner_id"`        // 谁的关系
+	TargetID      uint   `gorm:"uniqueIndex:idx_relation_owner_target;index;not null" json:"target_id"` // 对应的好友ID
+	Type          int    `json:"type"`                                                                  // 1=好友, 2=拉黑
+	Desc          string `json:"desc"`                                                                  // 备注名
+	LastReadMsgID uint   `gorm:"default:0;index" json:"last_read_msg_id"`                               // 该用户在该会话中已读的最后一条消息ID
+	TagID         uint   `gorm:"default:0;index" json:"tag_id"`                                         // 所属好友分组，0 = 未分组
 }
 
 func (Relation) TableName() string {
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Session 登录会话，每次登录 (一台设备) 对应一条记录
+type Session struct {
+	Model
+	SessionID  string     `gorm:"size:64;uniqueIndex" json:"session_id"` // 会话ID，写入 JWT 的 sid
+	UserID     uint       `gorm:"index" json:"user_id"`                  // 所属用户
+	DeviceName string     `gorm:"size:64" json:"device_name"`            // 客户端上报的设备名
+	IP         string     `gorm:"size:64" json:"ip"`                     // 登录 IP
+	UserAgent  string     `gorm:"size:255" json:"user_agent"`            // 登录时的 User-Agent
+	LastSeenAt time.Time  `json:"last_seen_at"`                          // 最后活跃时间
+	RevokedAt  *time.Time `gorm:"index" json:"revoked_at"`               // 退出登录或被踢下线的时间，空表示有效
+}
+
+func (*Session) TableName() string {
+	return "sessions"
+}
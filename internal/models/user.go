@@ -4,14 +4,23 @@ import "time"
 
 type User struct {
 	Model
-	Username  string     `gorm:"size:64;uniqueIndex" json:"username"` // 用户名，唯一
-	Password  string     `gorm:"size:255" json:"-"`                   // 密码，json化时不返回
-	Nickname  string     `gorm:"size:64" json:"nickname"`             // 昵称
-	Avatar    string     `gorm:"size:255" json:"avatar"`              // 头像URL
-	Email     string     `gorm:"size:128" json:"email"`               // 邮箱
-	Phone     string     `gorm:"size:20;index" json:"phone"`          // 手机号
-	Status    int        `gorm:"default:1" json:"status"`             // 1:正常 2:禁用 (后台管理用)
-	LastLogin *time.Time `json:"last_login"`                          // 最后登录时间
+	Username      string     `gorm:"size:64;uniqueIndex" json:"username"` // 用户名，唯一
+	Password      string     `gorm:"size:255" json:"-"`                   // 密码，json化时不返回
+	Nickname      string     `gorm:"size:64" json:"nickname"`             // 昵称
+	Avatar        string     `gorm:"size:255" json:"avatar"`              // 头像URL
+	Email         string     `gorm:"size:128;index" json:"email"`         // 邮箱
+	EmailVerified bool       `gorm:"default:false" json:"email_verified"` // 邮箱是否已验证，修改邮箱后重置
+	Phone         string     `gorm:"size:20;index" json:"phone"`          // 手机号
+	Status        int        `gorm:"default:1" json:"status"`             // 1:正常 2:禁用 (后台管理用)
+	LastLogin     *time.Time `json:"last_login"`                          // 最后登录时间
+
+	// 两步验证 (TOTP)
+	TOTPSecret  string `gorm:"column:totp_secret;size:64" json:"-"`                   // TOTP 密钥，不返回给前端
+	TOTPEnabled bool   `gorm:"column:totp_enabled;default:false" json:"totp_enabled"` // 是否已开启两步验证
+
+	// 隐私设置：是否允许别人通过手机号/邮箱搜索到我 (默认不允许)
+	PhoneSearchable bool `gorm:"default:false" json:"phone_searchable"`
+	EmailSearchable bool `gorm:"default:false" json:"email_searchable"`
 }
 
 // TableName 指定表名
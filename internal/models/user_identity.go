@@ -0,0 +1,14 @@
+package models
+
+// UserIdentity 第三方登录 (OIDC) 身份与本地用户的绑定关系
+type UserIdentity struct {
+	Model
+	UserID   uint   `gorm:"index" json:"user_id"`
+	Provider string `gorm:"size:191;uniqueIndex:idx_provider_subject" json:"provider"` // 身份提供方 (issuer)
+	Subject  string `gorm:"size:191;uniqueIndex:idx_provider_subject" json:"subject"`  // 身份提供方中的用户唯一标识 (sub)
+	Email    string `gorm:"size:128" json:"email"`                                     // 绑定时身份提供方返回的邮箱
+}
+
+func (*UserIdentity) TableName() string {
+	return "user_identities"
+}
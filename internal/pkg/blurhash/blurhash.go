@@ -0,0 +1,137 @@
+// Package blurhash 生成 BlurHash 占位图编码 (https://blurha.sh)
+// 客户端在原图/缩略图加载完成前用这段很短的字符串渲染模糊的预览
+package blurhash
+
+import (
+	"errors"
+	"image"
+	"math"
+	"strings"
+)
+
+var ErrComponents = errors.New("blurhash: components must be between 1 and 9")
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// Encode 按 xComponents x yComponents 个余弦分量编码 (各 1-9，常用 4x3)
+// 计算量与像素数成正比，传入的图片应先缩小到几十像素；透明像素按白色背景合成
+func Encode(img image.Image, xComponents, yComponents int) (string, error) {
+	if xComponents < 1 || xComponents > 9 || yComponents < 1 || yComponents > 9 {
+		return "", ErrComponents
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return "", errors.New("blurhash: empty image")
+	}
+
+	// 先把每个像素转换到线性色彩空间
+	linear := make([][3]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, bl, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			bg := 0xFFFF - a // RGBA() 返回预乘值，补上白色背景
+			linear[y*w+x] = [3]float64{
+				srgbToLinear(r + bg),
+				srgbToLinear(g + bg),
+				srgbToLinear(bl + bg),
+			}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			factors = append(factors, basisFactor(linear, w, h, i, j))
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(encode83((xComponents-1)+(yComponents-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+	maxValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			for _, v := range f {
+				actualMax = math.Max(actualMax, math.Abs(v))
+			}
+		}
+		quantised := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantised+1) / 166
+		sb.WriteString(encode83(quantised, 1))
+	} else {
+		sb.WriteString(encode83(0, 1))
+	}
+
+	sb.WriteString(encode83(encodeDC(dc), 4))
+	for _, f := range ac {
+		sb.WriteString(encode83(encodeAC(f, maxValue), 2))
+	}
+	return sb.String(), nil
+}
+
+// basisFactor 第 (i, j) 个余弦分量的系数
+func basisFactor(linear [][3]float64, w, h, i, j int) [3]float64 {
+	var r, g, b float64
+	for y := 0; y < h; y++ {
+		cy := math.Cos(math.Pi * float64(j) * float64(y) / float64(h))
+		for x := 0; x < w; x++ {
+			basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) * cy
+			p := linear[y*w+x]
+			r += basis * p[0]
+			g += basis * p[1]
+			b += basis * p[2]
+		}
+	}
+	norm := 2.0
+	if i == 0 && j == 0 {
+		norm = 1
+	}
+	scale := norm / float64(w*h)
+	return [3]float64{r * scale, g * scale, b * scale}
+}
+
+func encodeDC(v [3]float64) int {
+	return linearToSRGB(v[0])<<16 | linearToSRGB(v[1])<<8 | linearToSRGB(v[2])
+}
+
+func encodeAC(v [3]float64, maxValue float64) int {
+	quant := func(c float64) int {
+		return int(math.Max(0, math.Min(18, math.Floor(signPow(c/maxValue, 0.5)*9+9.5))))
+	}
+	return quant(v[0])*19*19 + quant(v[1])*19 + quant(v[2])
+}
+
+// srgbToLinear 输入为 16 位颜色分量
+func srgbToLinear(c uint32) float64 {
+	v := float64(c) / 0xFFFF
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB 输出为 0-255
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
+
+func encode83(v, length int) string {
+	buf := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		buf[i] = base83Chars[v%83]
+		v /= 83
+	}
+	return string(buf)
+}
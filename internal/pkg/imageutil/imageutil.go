@@ -0,0 +1,200 @@
+package imageutil
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	_ "image/gif" // 注册 GIF 解码器
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported image format")
+	ErrTooLarge    = errors.New("image dimensions too large")
+)
+
+// Decode 解码图片，先读取尺寸，超过 maxPixels 的直接拒绝，防止解压炸弹
+// 支持 JPEG / PNG / GIF (取第一帧)
+func Decode(data []byte, maxPixels int) (image.Image, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupported
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || (maxPixels > 0 && cfg.Width*cfg.Height > maxPixels) {
+		return nil, "", ErrTooLarge
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupported
+	}
+	return img, format, nil
+}
+
+// CropSquare 居中裁剪为正方形
+func CropSquare(img image.Image) image.Image {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+	rect := image.Rect(x, y, x+side, y+side)
+
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
+
+// Fit 等比缩放到不超过 maxW x maxH，本身更小则原样返回
+func Fit(img image.Image, maxW, maxH int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxW && h <= maxH {
+		return img
+	}
+	if w*maxH > h*maxW {
+		h = h * maxW / w
+		w = maxW
+	} else {
+		w = w * maxH / h
+		h = maxH
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return Resize(img, w, h)
+}
+
+// Resize 双线性插值缩放到 w x h
+func Resize(img image.Image, w, h int) *image.RGBA {
+	src := toRGBA(img)
+	sb := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	// 缩小倍数较大时先按整数倍做区域平均，避免双线性插值丢细节产生锯齿
+	for sb.Dx() >= w*2 && sb.Dy() >= h*2 {
+		src = halve(src)
+		sb = src.Bounds()
+	}
+
+	sx := float64(sb.Dx()) / float64(w)
+	sy := float64(sb.Dy()) / float64(h)
+	for y := 0; y < h; y++ {
+		fy := (float64(y)+0.5)*sy - 0.5
+		for x := 0; x < w; x++ {
+			fx := (float64(x)+0.5)*sx - 0.5
+			dst.SetRGBA(x, y, bilinear(src, fx, fy))
+		}
+	}
+	return dst
+}
+
+// Encode 按格式编码，jpeg 以外一律输出 PNG (保留透明度)
+func Encode(w io.Writer, img image.Image, format string) error {
+	if format == "jpeg" {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	}
+	return png.Encode(w, img)
+}
+
+// ContentType 与 Encode 的输出格式对应
+func ContentType(format string) string {
+	if format == "jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// Ext 与 Encode 的输出格式对应的扩展名
+func Ext(format string) string {
+	if format == "jpeg" {
+		return ".jpg"
+	}
+	return ".png"
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// halve 2x2 区域平均缩小一半
+func halve(src *image.RGBA) *image.RGBA {
+	sb := src.Bounds()
+	w, h := sb.Dx()/2, sb.Dy()/2
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var r, g, b, a uint32
+			for dy := 0; dy < 2; dy++ {
+				for dx := 0; dx < 2; dx++ {
+					c := src.RGBAAt(x*2+dx, y*2+dy)
+					r += uint32(c.R)
+					g += uint32(c.G)
+					b += uint32(c.B)
+					a += uint32(c.A)
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / 4), G: uint8(g / 4), B: uint8(b / 4), A: uint8(a / 4)})
+		}
+	}
+	return dst
+}
+
+func bilinear(src *image.RGBA, fx, fy float64) color.RGBA {
+	b := src.Bounds()
+	x0, y0 := clamp(int(fx), b.Dx()-1), clamp(int(fy), b.Dy()-1)
+	if fx < 0 {
+		x0 = 0
+	}
+	if fy < 0 {
+		y0 = 0
+	}
+	x1, y1 := clamp(x0+1, b.Dx()-1), clamp(y0+1, b.Dy()-1)
+	tx, ty := fx-float64(x0), fy-float64(y0)
+	if tx < 0 {
+		tx = 0
+	}
+	if ty < 0 {
+		ty = 0
+	}
+
+	c00, c10 := src.RGBAAt(x0, y0), src.RGBAAt(x1, y0)
+	c01, c11 := src.RGBAAt(x0, y1), src.RGBAAt(x1, y1)
+	lerp := func(a, b, c, d uint8) uint8 {
+		top := float64(a)*(1-tx) + float64(b)*tx
+		bottom := float64(c)*(1-tx) + float64(d)*tx
+		return uint8(top*(1-ty) + bottom*ty + 0.5)
+	}
+	return color.RGBA{
+		R: lerp(c00.R, c10.R, c01.R, c11.R),
+		G: lerp(c00.G, c10.G, c01.G, c11.G),
+		B: lerp(c00.B, c10.B, c01.B, c11.B),
+		A: lerp(c00.A, c10.A, c01.A, c11.A),
+	}
+}
+
+func clamp(v, max int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,207 @@
+package imageutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// StripMetadata 无损去掉图片中的 EXIF (含 GPS 位置)、XMP、IPTC、注释等元数据，像素数据原样保留
+// JPEG 的方向信息会以只含 Orientation 的最小 EXIF 写回，保证客户端显示方向不变；
+// 同时返回方向值 (1-8，没有时为 1)，供生成缩略图时旋转
+// GIF 没有标准的 EXIF，原样返回
+func StripMetadata(data []byte, format string) ([]byte, int, error) {
+	switch format {
+	case "jpeg":
+		return stripJPEG(data)
+	case "png":
+		out, err := stripPNG(data)
+		return out, 1, err
+	}
+	return data, 1, nil
+}
+
+// stripJPEG 先读出方向，再逐段复制 SOS 之前的段并丢弃元数据段；SOS 之后是压缩数据，整体复制
+func stripJPEG(data []byte) ([]byte, int, error) {
+	orientation := 1
+	if _, err := walkJPEG(data, func(marker byte, seg, payload []byte) {
+		if marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			orientation = exifOrientation(payload[6:])
+		}
+	}); err != nil {
+		return nil, 0, err
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	wroteExif := orientation == 1 // 默认方向不需要 EXIF
+	sos, _ := walkJPEG(data, func(marker byte, seg, payload []byte) {
+		// APP0 (JFIF) 按规范必须紧跟 SOI，方向信息写在它之后
+		if !wroteExif && marker != 0xE0 {
+			out = append(out, minimalExif(orientation)...)
+			wroteExif = true
+		}
+		if keepJPEGSegment(marker, payload) {
+			out = append(out, seg...)
+		}
+	})
+	if !wroteExif {
+		out = append(out, minimalExif(orientation)...)
+	}
+	out = append(out, data[sos:]...)
+	return out, orientation, nil
+}
+
+// walkJPEG 依次遍历 SOS 之前的各段 (seg 含标记和长度，payload 为段内容)，返回 SOS 段的起始位置
+func walkJPEG(data []byte, fn func(marker byte, seg, payload []byte)) (int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0, ErrUnsupported
+	}
+	for pos := 2; ; {
+		if pos+4 > len(data) || data[pos] != 0xFF {
+			return 0, ErrUnsupported
+		}
+		marker := data[pos+1]
+		if marker == 0xFF { // 填充字节
+			pos++
+			continue
+		}
+		if marker == 0xDA {
+			return pos, nil
+		}
+		segLen := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if segLen < 2 || pos+2+segLen > len(data) {
+			return 0, ErrUnsupported
+		}
+		fn(marker, data[pos:pos+2+segLen], data[pos+4:pos+2+segLen])
+		pos += 2 + segLen
+	}
+}
+
+// keepJPEGSegment 保留解码和色彩相关的段：APP0 (JFIF)、APP2 中的 ICC 配置、APP14 (Adobe 色彩变换) 及所有非 APP 段
+// 丢弃 APP1 (EXIF/XMP)、APP13 (IPTC)、COM 注释以及其余 APPn (可能带有含 EXIF 的预览图)
+func keepJPEGSegment(marker byte, payload []byte) bool {
+	switch {
+	case marker == 0xE0, marker == 0xEE:
+		return true
+	case marker == 0xE2:
+		return bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00"))
+	case marker >= 0xE1 && marker <= 0xEF, marker == 0xFE:
+		return false
+	}
+	return true
+}
+
+// minimalExif 只包含 IFD0 Orientation 一个条目的 APP1 段 (大端序)
+func minimalExif(orientation int) []byte {
+	seg := []byte{
+		0xFF, 0xE1, 0x00, 0x22, // APP1，长度 34
+		'E', 'x', 'i', 'f', 0x00, 0x00,
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, // TIFF 头，IFD0 偏移 8
+		0x00, 0x01, // 1 个条目
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, // Orientation SHORT x1
+		0x00, 0x00, 0x00, 0x00, // 没有下一个 IFD
+	}
+	seg[28], seg[29] = 0x00, byte(orientation)
+	return seg
+}
+
+// exifOrientation 从 TIFF 结构的 IFD0 中读取 Orientation (0x0112)，读不到时为 1
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			if v := int(order.Uint16(tiff[entry+8 : entry+10])); v >= 1 && v <= 8 {
+				return v
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// stripPNG 去掉 eXIf、tEXt、zTXt、iTXt、tIME 块，其余块原样保留
+func stripPNG(data []byte) ([]byte, error) {
+	const sig = "\x89PNG\r\n\x1a\n"
+	if len(data) < len(sig) || string(data[:len(sig)]) != sig {
+		return nil, ErrUnsupported
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:len(sig)]...)
+	for pos := len(sig); pos < len(data); {
+		if pos+8 > len(data) {
+			return nil, ErrUnsupported
+		}
+		n := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		end := pos + 12 + n // 长度 + 类型 + 数据 + CRC
+		if n < 0 || end > len(data) {
+			return nil, ErrUnsupported
+		}
+		switch string(data[pos+4 : pos+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+	return out, nil
+}
+
+// Orient 按 EXIF 方向值旋转/翻转图片，使像素方向与显示方向一致
+func Orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	src := toRGBA(img)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // 水平翻转
+				sx, sy = w-1-x, y
+			case 3: // 旋转 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				sx, sy = x, h-1-y
+			case 5: // 沿主对角线翻转
+				sx, sy = y, x
+			case 6: // 顺时针 90°
+				sx, sy = y, h-1-x
+			case 7: // 沿副对角线翻转
+				sx, sy = w-1-y, h-1-x
+			case 8: // 逆时针 90°
+				sx, sy = w-1-y, x
+			}
+			dst.SetRGBA(x, y, src.RGBAAt(sx, sy))
+		}
+	}
+	return dst
+}
@@ -6,7 +6,6 @@ import (
 	"os"
 	"time"
 
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -14,7 +13,7 @@ import (
 )
 
 func InitDB() {
-	dsn := viper.GetString("mysql.dsn")
+	dsn := global.Config.GetString("mysql.dsn")
 	if dsn == "" {
 		panic("mysql dsn is empty")
 	}
@@ -0,0 +1,35 @@
+package initial
+
+import (
+	"go-chat/global"
+	"go-chat/internal/pkg/utils"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// InitJWTKeys 启动时加载签名密钥 (配置有误直接退出)，并定时检查是否需要轮换
+func InitJWTKeys() {
+	keys, err := utils.JWTKeys()
+	if err != nil {
+		global.Log.Fatal("init jwt keys failed", zap.Error(err))
+	}
+	global.Log.Info("JWT keys loaded",
+		zap.String("algorithm", keys.Algorithm()),
+		zap.String("kid", keys.Current().ID))
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			rotated, err := keys.RotateIfDue(time.Now())
+			if err != nil {
+				global.Log.Error("rotate jwt keys failed", zap.Error(err))
+				continue
+			}
+			if rotated {
+				global.Log.Info("jwt signing key rotated", zap.String("kid", keys.Current().ID))
+			}
+		}
+	}()
+}
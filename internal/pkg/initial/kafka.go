@@ -5,20 +5,19 @@ import (
 	"go-chat/global"
 
 	"github.com/IBM/sarama"
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
 func InitKafka() {
 
-	global.KAdrrs = viper.GetStringSlice("kafka.addr")
+	global.KAdrrs = global.Config.GetStringSlice("kafka.addr")
 	if len(global.KAdrrs) == 0 {
 		global.KAdrrs = []string{"localhost:9092"}
 	}
 
 	config := sarama.NewConfig()
 
-	ack := viper.GetString("kafka.ack")
+	ack := global.Config.GetString("kafka.ack")
 	switch ack {
 	case "all":
 		config.Producer.RequiredAcks = sarama.WaitForAll
@@ -32,7 +31,7 @@ func InitKafka() {
 	config.Producer.Return.Successes = true
 
 	// 配置重试次数
-	config.Producer.Retry.Max = viper.GetInt("kafka.retry")
+	config.Producer.Retry.Max = global.Config.GetInt("kafka.retry")
 
 	// 连接 Kafka
 	producer, err := sarama.NewSyncProducer(global.KAdrrs, config)
@@ -51,13 +50,13 @@ func InitKafka() {
 	}
 	defer admin.Close()
 
-	global.KTopic.ChatMsg = viper.GetString("kafka.topic.chat")
+	global.KTopic.ChatMsg = global.Config.GetString("kafka.topic.chat")
 	NewTopic(admin, global.KTopic.ChatMsg, 1, 1)
 
-	global.KTopic.Retry = viper.GetString("kafka.topic.retry")
+	global.KTopic.Retry = global.Config.GetString("kafka.topic.retry")
 	NewTopic(admin, global.KTopic.Retry, 1, 1)
 
-	global.KTopic.Dead = viper.GetString("kafka.topic.dead")
+	global.KTopic.Dead = global.Config.GetString("kafka.topic.dead")
 	NewTopic(admin, global.KTopic.Dead, 1, 1)
 
 }
@@ -0,0 +1,31 @@
+package initial
+
+import (
+	"go-chat/global"
+	"go-chat/internal/pkg/mailer"
+
+	"go.uber.org/zap"
+)
+
+// InitMailer 按 mail.driver 初始化邮件发送
+func InitMailer() {
+	driver := global.Config.GetString("mail.driver")
+	switch driver {
+	case "smtp":
+		global.Mailer = &mailer.SMTPMailer{
+			Host:     global.Config.GetString("mail.smtp.host"),
+			Port:     global.Config.GetInt("mail.smtp.port"),
+			Username: global.Config.GetString("mail.smtp.username"),
+			Password: global.Config.GetString("mail.smtp.password"),
+			From:     global.Config.GetString("mail.smtp.from"),
+		}
+	case "", "memory":
+		// 未配置 SMTP 时邮件只保存在内存中，不会真正发出
+		global.Log.Warn("mail driver is memory, emails will not be delivered")
+		global.Mailer = mailer.NewMemoryMailer()
+	default:
+		global.Log.Fatal("unknown mail driver", zap.String("driver", driver))
+	}
+
+	global.Log.Info("Mailer initialized", zap.String("driver", driver))
+}
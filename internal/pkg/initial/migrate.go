@@ -0,0 +1,88 @@
+package initial
+
+import (
+	"go-chat/global"
+	"go-chat/internal/models"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// DedupRelations 清理 relations 表中的重复记录，为 (owner_id, target_id) 唯一索引做准备
+// 必须在 AutoMigrate 之前执行，否则存在重复数据时建索引会失败
+// 每组重复记录保留一条：拉黑优先于好友，其次保留最早的一条；已读位置、备注、分组合并到保留的记录上
+func DedupRelations() {
+	db := global.DB
+	if !db.Migrator().HasTable(&models.Relation{}) {
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 1. 软删除的历史记录没有意义，且会占用唯一索引，直接物理删除
+		if err := tx.Unscoped().Where("deleted_at IS NOT NULL").Delete(&models.Relation{}).Error; err != nil {
+			return err
+		}
+
+		// 2. 找出重复的 (owner_id, target_id)
+		var groups []struct {
+			OwnerID  uint
+			TargetID uint
+		}
+		if err := tx.Model(&models.Relation{}).
+			Select("owner_id, target_id").
+			Group("owner_id, target_id").
+			Having("COUNT(*) > 1").
+			Scan(&groups).Error; err != nil {
+			return err
+		}
+
+		// 3. 逐组合并
+		hasTagID := tx.Migrator().HasColumn(&models.Relation{}, "TagID")
+		for _, g := range groups {
+			var rows []models.Relation
+			if err := tx.Where("owner_id = ? AND target_id = ?", g.OwnerID, g.TargetID).
+				Order("type desc, id asc").
+				Find(&rows).Error; err != nil {
+				return err
+			}
+
+			keep := rows[0]
+			removeIDs := make([]uint, 0, len(rows)-1)
+			for _, r := range rows[1:] {
+				if r.LastReadMsgID > keep.LastReadMsgID {
+					keep.LastReadMsgID = r.LastReadMsgID
+				}
+				if keep.Desc == "" {
+					keep.Desc = r.Desc
+				}
+				if keep.TagID == 0 {
+					keep.TagID = r.TagID
+				}
+				removeIDs = append(removeIDs, r.ID)
+			}
+
+			updates := map[string]interface{}{
+				"last_read_msg_id": keep.LastReadMsgID,
+				"desc":             keep.Desc,
+			}
+			// 旧表可能还没有 tag_id 列 (AutoMigrate 在此之后执行)
+			if hasTagID {
+				updates["tag_id"] = keep.TagID
+			}
+			if err := tx.Model(&models.Relation{}).Where("id = ?", keep.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Delete(&models.Relation{}, removeIDs).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(groups) > 0 {
+			global.Log.Info("duplicate relations merged", zap.Int("groups", len(groups)))
+		}
+		return nil
+	})
+	if err != nil {
+		global.Log.Fatal("dedup relations failed", zap.Error(err))
+	}
+}
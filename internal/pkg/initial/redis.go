@@ -5,14 +5,13 @@ import (
 	"go-chat/global"
 
 	"github.com/redis/go-redis/v9"
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
 func InitRedis() {
-	addr := viper.GetString("redis.addr")
-	password := viper.GetString("redis.password")
-	db := viper.GetInt("redis.db")
+	addr := global.Config.GetString("redis.addr")
+	password := global.Config.GetString("redis.password")
+	db := global.Config.GetInt("redis.db")
 
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     addr,
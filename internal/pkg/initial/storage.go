@@ -0,0 +1,48 @@
+package initial
+
+import (
+	"go-chat/global"
+	"go-chat/internal/pkg/storage"
+
+	"go.uber.org/zap"
+)
+
+// InitStorage 按 storage.driver 初始化文件存储
+func InitStorage() {
+	driver := global.Config.GetString("storage.driver")
+	switch driver {
+	case "", "local":
+		dir := global.Config.GetString("storage.local.dir")
+		if dir == "" {
+			dir = "./data/uploads"
+		}
+		prefix := global.Config.GetString("storage.local.url_prefix")
+		if prefix == "" {
+			prefix = "/uploads"
+		}
+
+		local, err := storage.NewLocalStorage(dir, prefix)
+		if err != nil {
+			global.Log.Fatal("init local storage failed", zap.Error(err))
+		}
+		global.Storage = local
+	case "s3":
+		s3, err := storage.NewS3Storage(storage.S3Config{
+			Endpoint:  global.Config.GetString("storage.s3.endpoint"),
+			Region:    global.Config.GetString("storage.s3.region"),
+			Bucket:    global.Config.GetString("storage.s3.bucket"),
+			AccessKey: global.Config.GetString("storage.s3.access_key"),
+			SecretKey: global.Config.GetString("storage.s3.secret_key"),
+			PathStyle: global.Config.GetBool("storage.s3.path_style"),
+			PublicURL: global.Config.GetString("storage.s3.public_url"),
+		}, nil)
+		if err != nil {
+			global.Log.Fatal("init s3 storage failed", zap.Error(err))
+		}
+		global.Storage = s3
+	default:
+		global.Log.Fatal("unknown storage driver", zap.String("driver", driver))
+	}
+
+	global.Log.Info("Storage initialized", zap.String("driver", driver))
+}
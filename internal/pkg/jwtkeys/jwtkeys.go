@@ -0,0 +1,432 @@
+// Package jwtkeys 管理 JWT 签名密钥：HS256 共享密钥，或 RS256/EdDSA 非对称密钥 (按 kid 区分、定期轮换、可发布 JWKS)
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-chat/internal/pkg/jwk"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+var ErrUnknownKey = errors.New("jwtkeys: unknown key id")
+
+// 本地没有该 kid 时重新读取密钥目录的最小间隔 (其他实例可能已轮换)
+const reloadInterval = 10 * time.Second
+
+// JWKSMaxAge 验签方可以缓存 JWKS 的时长 (Cache-Control: max-age)
+const JWKSMaxAge = 5 * time.Minute
+
+// publishAhead 轮换出的新密钥先只发布到 JWKS，过了这段时间才用于签名
+// 这样缓存了旧 JWKS 的验签方在新 kid 的 Token 出现前已经刷新过一次，其他实例也已重新读取过目录
+const publishAhead = JWKSMaxAge + reloadInterval
+
+// Options 密钥配置
+type Options struct {
+	Algorithm      string        // HS256 / RS256 / EdDSA
+	Secret         string        // HS256 共享密钥
+	Dir            string        // 非对称密钥的保存目录，多实例部署时需共享
+	RotateInterval time.Duration // 签名密钥使用多久后轮换，0 表示不自动轮换
+	Retain         time.Duration // 被替换的密钥继续用于验签的时长，应不短于 Access Token 有效期
+	RSABits        int
+}
+
+// Key 一个签名密钥
+type Key struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	ActiveAt  time.Time // 开始用于签名的时间，之前只发布在 JWKS 中
+
+	private interface{} // []byte / *rsa.PrivateKey / ed25519.PrivateKey
+	public  interface{} // []byte / *rsa.PublicKey / ed25519.PublicKey
+	path    string
+}
+
+// SigningMethod 对应的 jwt 签名算法
+func (k *Key) SigningMethod() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// SignKey 签名用的密钥
+func (k *Key) SignKey() interface{} {
+	return k.private
+}
+
+// VerifyKey 验签用的密钥
+func (k *Key) VerifyKey() interface{} {
+	return k.public
+}
+
+// KeySet 当前可用的密钥，已启用的最新一个用于签名，其余在保留期内仍可验签
+type KeySet struct {
+	opts Options
+
+	mu           sync.RWMutex
+	keys         []*Key    // 按启用时间倒序
+	lastReloadAt time.Time // 上次按需 (未知 kid、发布 JWKS) 重新读取目录的时间
+}
+
+// New 加载密钥；非对称算法下目录中没有可用密钥时自动生成一个
+func New(opts Options) (*KeySet, error) {
+	s := &KeySet{opts: opts}
+
+	switch opts.Algorithm {
+	case HS256:
+		if opts.Secret == "" {
+			return nil, errors.New("jwtkeys: HS256 requires a secret")
+		}
+		secret := []byte(opts.Secret)
+		s.keys = []*Key{{ID: "hs256", Algorithm: HS256, private: secret, public: secret}}
+		return s, nil
+	case RS256, EdDSA:
+	default:
+		return nil, fmt.Errorf("jwtkeys: unsupported algorithm %q", opts.Algorithm)
+	}
+
+	if opts.Dir == "" {
+		return nil, errors.New("jwtkeys: key dir is required")
+	}
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, err
+	}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	if _, err := s.RotateIfDue(time.Now()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Algorithm 签名算法
+func (s *KeySet) Algorithm() string {
+	return s.opts.Algorithm
+}
+
+// Current 用于签名的密钥：已到启用时间的最新密钥
+func (s *KeySet) Current() *Key {
+	return s.currentAt(time.Now())
+}
+
+func (s *KeySet) currentAt(now time.Time) *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if !k.ActiveAt.After(now) {
+			return k
+		}
+	}
+	// 都未到启用时间 (如目录中的密钥均由其他实例刚刚生成)，使用最早启用的一个
+	return s.keys[len(s.keys)-1]
+}
+
+// Lookup 按 kid 查找验签密钥，找不到时重新读取密钥目录
+func (s *KeySet) Lookup(kid string) (*Key, error) {
+	if k := s.find(kid); k != nil {
+		return k, nil
+	}
+	if s.opts.Algorithm == HS256 {
+		return nil, ErrUnknownKey
+	}
+
+	reloaded, err := s.reloadThrottled()
+	if err != nil {
+		return nil, err
+	}
+	if reloaded {
+		if k := s.find(kid); k != nil {
+			return k, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// reloadThrottled 距上次读取超过 reloadInterval 时重新读取密钥目录，返回是否读取了
+func (s *KeySet) reloadThrottled() (bool, error) {
+	s.mu.Lock()
+	due := time.Since(s.lastReloadAt) >= reloadInterval
+	if due {
+		s.lastReloadAt = time.Now()
+	}
+	s.mu.Unlock()
+	if !due {
+		return false, nil
+	}
+	return true, s.reload()
+}
+
+func (s *KeySet) find(kid string) *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if k.ID == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+// Keys 当前所有可验签的密钥
+func (s *KeySet) Keys() []*Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*Key(nil), s.keys...)
+}
+
+// JWKS 公钥集合，供其他服务验证本服务签发的 Token；HS256 下为空
+// 包含尚未启用的新密钥，发布前先读取目录，以便带上其他实例刚轮换出的密钥
+func (s *KeySet) JWKS() (jwk.Set, error) {
+	set := jwk.Set{Keys: []jwk.Key{}}
+	if s.opts.Algorithm == HS256 {
+		return set, nil
+	}
+	if _, err := s.reloadThrottled(); err != nil {
+		return set, err
+	}
+	for _, k := range s.Keys() {
+		key, err := jwk.NewKey(k.ID, k.Algorithm, k.public)
+		if err != nil {
+			return set, err
+		}
+		set.Keys = append(set.Keys, key)
+	}
+	return set, nil
+}
+
+// RotateIfDue 签名密钥超过轮换周期时生成新密钥，并删除保留期已过的旧密钥；返回是否生成了新密钥
+func (s *KeySet) RotateIfDue(now time.Time) (bool, error) {
+	if s.opts.Algorithm == HS256 {
+		return false, nil
+	}
+	// 先读取目录，其他实例可能已经轮换过
+	if err := s.reload(); err != nil {
+		return false, err
+	}
+
+	rotated := false
+	s.mu.RLock()
+	due := len(s.keys) == 0 ||
+		(s.opts.RotateInterval > 0 && now.Sub(s.keys[0].CreatedAt) >= s.opts.RotateInterval)
+	s.mu.RUnlock()
+	if due {
+		if err := s.Rotate(now); err != nil {
+			return false, err
+		}
+		rotated = true
+	}
+	return rotated, s.prune(now)
+}
+
+// Rotate 生成新的签名密钥并立即发布到 JWKS，publishAhead 之后才用于签名 (首个密钥立即启用)
+// 旧密钥在新密钥启用后的保留期内仍可验签
+func (s *KeySet) Rotate(now time.Time) error {
+	if s.opts.Algorithm == HS256 {
+		return errors.New("jwtkeys: HS256 secret cannot be rotated automatically")
+	}
+
+	k, err := s.generate(now)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	if len(s.keys) > 0 {
+		k.ActiveAt = now.Add(publishAhead)
+	}
+	s.mu.RUnlock()
+	if err := s.save(k); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.keys = append([]*Key{k}, s.keys...)
+	s.mu.Unlock()
+	return nil
+}
+
+// prune 删除已被替换且超过保留期的密钥：密钥在下一个密钥启用时被替换
+func (s *KeySet) prune(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.keys[:1]
+	for i := 1; i < len(s.keys); i++ {
+		replacedAt := s.keys[i-1].ActiveAt
+		if now.Sub(replacedAt) < s.opts.Retain {
+			kept = append(kept, s.keys[i])
+			continue
+		}
+		if err := os.Remove(s.keys[i].path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	s.keys = kept
+	return nil
+}
+
+func (s *KeySet) generate(now time.Time) (*Key, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	k := &Key{
+		ID:        now.UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix),
+		Algorithm: s.opts.Algorithm,
+		CreatedAt: now,
+		ActiveAt:  now,
+	}
+
+	switch s.opts.Algorithm {
+	case RS256:
+		bits := s.opts.RSABits
+		if bits < 2048 {
+			bits = 2048
+		}
+		priv, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, err
+		}
+		k.private, k.public = priv, &priv.PublicKey
+	case EdDSA:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		k.private, k.public = priv, pub
+	}
+	return k, nil
+}
+
+// save 以 PEM 保存私钥，kid / 算法 / 创建和启用时间放在 PEM 头中
+func (s *KeySet) save(k *Key) error {
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return err
+	}
+	block := &pem.Block{
+		Type: "PRIVATE KEY",
+		Headers: map[string]string{
+			"Kid":     k.ID,
+			"Alg":     k.Algorithm,
+			"Created": k.CreatedAt.UTC().Format(time.RFC3339),
+			"Active":  k.ActiveAt.UTC().Format(time.RFC3339),
+		},
+		Bytes: der,
+	}
+
+	k.path = filepath.Join(s.opts.Dir, k.ID+".pem")
+	f, err := os.OpenFile(k.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(f, block); err != nil {
+		f.Close()
+		os.Remove(k.path)
+		return err
+	}
+	return f.Close()
+}
+
+// reload 重新读取密钥目录，只加载与当前算法一致的密钥
+func (s *KeySet) reload() error {
+	entries, err := os.ReadDir(s.opts.Dir)
+	if err != nil {
+		return err
+	}
+
+	var keys []*Key
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".pem") {
+			continue
+		}
+		k, err := loadKey(filepath.Join(s.opts.Dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		if k.Algorithm == s.opts.Algorithm {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].ActiveAt.Equal(keys[j].ActiveAt) {
+			return keys[i].ActiveAt.After(keys[j].ActiveAt)
+		}
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.After(keys[j].CreatedAt)
+		}
+		return keys[i].ID > keys[j].ID
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 目录为空 (首次启动) 时保留内存中的密钥，由调用方决定是否生成
+	if len(keys) > 0 || len(s.keys) == 0 {
+		s.keys = keys
+	}
+	return nil
+}
+
+func loadKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("jwtkeys: %s is not a PEM private key", path)
+	}
+	created, err := time.Parse(time.RFC3339, block.Headers["Created"])
+	if err != nil {
+		return nil, fmt.Errorf("jwtkeys: %s: invalid Created header", path)
+	}
+	// 旧版本生成的密钥没有 Active 头，创建即启用
+	active := created
+	if v := block.Headers["Active"]; v != "" {
+		if active, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("jwtkeys: %s: invalid Active header", path)
+		}
+	}
+	priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("jwtkeys: %s: %w", path, err)
+	}
+
+	k := &Key{ID: block.Headers["Kid"], Algorithm: block.Headers["Alg"], CreatedAt: created, ActiveAt: active, path: path}
+	if k.ID == "" {
+		return nil, fmt.Errorf("jwtkeys: %s: missing Kid header", path)
+	}
+	switch p := priv.(type) {
+	case *rsa.PrivateKey:
+		if k.Algorithm != RS256 {
+			return nil, fmt.Errorf("jwtkeys: %s: RSA key with alg %q", path, k.Algorithm)
+		}
+		k.private, k.public = p, &p.PublicKey
+	case ed25519.PrivateKey:
+		if k.Algorithm != EdDSA {
+			return nil, fmt.Errorf("jwtkeys: %s: Ed25519 key with alg %q", path, k.Algorithm)
+		}
+		k.private, k.public = p, p.Public()
+	default:
+		return nil, fmt.Errorf("jwtkeys: %s: unsupported key type %T", path, priv)
+	}
+	return k, nil
+}
@@ -0,0 +1,85 @@
+package jwtkeys
+
+import (
+	"testing"
+	"time"
+)
+
+func jwksKids(t *testing.T, s *KeySet) map[string]bool {
+	t.Helper()
+	set, err := s.JWKS()
+	if err != nil {
+		t.Fatalf("jwks: %v", err)
+	}
+	kids := make(map[string]bool, len(set.Keys))
+	for _, k := range set.Keys {
+		kids[k.Kid] = true
+	}
+	return kids
+}
+
+// 轮换出的新密钥先发布到 JWKS，缓存过期之后才用于签名
+func TestRotatePublishesBeforeSigning(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(Options{Algorithm: EdDSA, Dir: dir, Retain: time.Hour})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	old := s.Current()
+
+	now := time.Now()
+	if err := s.Rotate(now); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	next := s.Keys()[0]
+	if next.ID == old.ID {
+		t.Fatal("rotate did not create a new key")
+	}
+
+	if kids := jwksKids(t, s); !kids[old.ID] || !kids[next.ID] {
+		t.Fatalf("jwks should publish both keys right after rotation, got %v", kids)
+	}
+	if cur := s.currentAt(now.Add(JWKSMaxAge)); cur.ID != old.ID {
+		t.Fatalf("new key used for signing within the JWKS cache period")
+	}
+	if cur := s.currentAt(now.Add(publishAhead + time.Second)); cur.ID != next.ID {
+		t.Fatalf("new key not used for signing after publishAhead")
+	}
+
+	// 启用时间保存在密钥文件中，其他实例读取目录后行为一致
+	other, err := New(Options{Algorithm: EdDSA, Dir: dir, Retain: time.Hour})
+	if err != nil {
+		t.Fatalf("new from dir: %v", err)
+	}
+	if cur := other.currentAt(now.Add(JWKSMaxAge)); cur.ID != old.ID {
+		t.Fatalf("reloaded key set signs with the pending key")
+	}
+}
+
+// 旧密钥的保留期从新密钥启用时开始计算
+func TestPruneAfterActivation(t *testing.T) {
+	s, err := New(Options{Algorithm: EdDSA, Dir: t.TempDir(), Retain: time.Hour})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	old := s.Current()
+
+	now := time.Now()
+	if err := s.Rotate(now); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+
+	if err := s.prune(now.Add(publishAhead + 30*time.Minute)); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if s.find(old.ID) == nil {
+		t.Fatal("old key pruned before its retain period after activation")
+	}
+
+	if err := s.prune(now.Add(publishAhead + time.Hour + time.Second)); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if s.find(old.ID) != nil {
+		t.Fatal("old key kept after its retain period")
+	}
+}
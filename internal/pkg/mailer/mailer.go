@@ -0,0 +1,15 @@
+package mailer
+
+import "context"
+
+// Mail 一封纯文本邮件
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer 邮件发送后端
+type Mailer interface {
+	Send(ctx context.Context, mail Mail) error
+}
@@ -0,0 +1,350 @@
+// Package mediainfo 识别上传文件的真实类型，并读取图片尺寸、音视频时长等信息
+// 只读取文件头和少量元数据，不做完整解码
+package mediainfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"net/http"
+	"time"
+
+	_ "image/gif"  // 注册 GIF 解码器
+	_ "image/jpeg" // 注册 JPEG 解码器
+	_ "image/png"  // 注册 PNG 解码器
+)
+
+var ErrMalformed = errors.New("malformed media file")
+
+// Info 媒体信息，无法识别的字段为零值
+type Info struct {
+	Width    int
+	Height   int
+	Duration time.Duration
+}
+
+// Sniff 根据文件内容判断 MIME 类型，不信任客户端声明的类型和扩展名
+func Sniff(r io.ReaderAt) string {
+	head := make([]byte, 512)
+	n, _ := r.ReadAt(head, 0)
+	head = head[:n]
+
+	// http.DetectContentType 不认识的几种音视频容器
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		switch string(head[8:12]) {
+		case "M4A ", "M4B ", "M4P ":
+			return "audio/mp4"
+		case "qt  ":
+			return "video/quicktime"
+		}
+		return "video/mp4"
+	}
+	if isMP3Frame(head) {
+		return "audio/mpeg"
+	}
+
+	mime := http.DetectContentType(head)
+	switch mime {
+	case "audio/wave":
+		return "audio/wav"
+	case "application/ogg":
+		return "audio/ogg"
+	}
+	if i := bytes.IndexByte([]byte(mime), ';'); i >= 0 {
+		mime = mime[:i]
+	}
+	return mime
+}
+
+// Probe 读取媒体信息，不支持的格式返回零值
+func Probe(r io.ReaderAt, size int64, mime string) (Info, error) {
+	switch mime {
+	case "image/jpeg", "image/png", "image/gif":
+		cfg, _, err := image.DecodeConfig(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return Info{}, ErrMalformed
+		}
+		return Info{Width: cfg.Width, Height: cfg.Height}, nil
+	case "video/mp4", "audio/mp4", "video/quicktime":
+		return probeMP4(r, size)
+	case "audio/wav":
+		return probeWAV(r, size)
+	case "audio/mpeg":
+		return probeMP3(r, size)
+	}
+	return Info{}, nil
+}
+
+// probeMP4 从 moov/mvhd 读取时长，从第一个有尺寸的 trak/tkhd 读取宽高
+func probeMP4(r io.ReaderAt, size int64) (Info, error) {
+	moov, err := findBox(r, 0, size, "moov")
+	if err != nil {
+		return Info{}, err
+	}
+	// moov 只包含索引信息，通常不大；过大的直接放弃解析
+	if moov.size > 16<<20 {
+		return Info{}, nil
+	}
+	data := make([]byte, moov.size)
+	if _, err := r.ReadAt(data, moov.offset); err != nil {
+		return Info{}, ErrMalformed
+	}
+
+	var info Info
+	walkBoxes(data, func(typ string, body []byte) {
+		switch typ {
+		case "mvhd":
+			info.Duration = mvhdDuration(body)
+		case "trak":
+			if info.Width > 0 {
+				return
+			}
+			walkBoxes(body, func(typ string, body []byte) {
+				if typ == "tkhd" {
+					info.Width, info.Height = tkhdSize(body)
+				}
+			})
+		}
+	})
+	return info, nil
+}
+
+type boxRange struct {
+	offset int64 // box 内容的起始位置 (不含头)
+	size   int64 // box 内容长度
+}
+
+// findBox 在 [start, end) 范围内查找指定类型的顶层 box
+func findBox(r io.ReaderAt, start, end int64, want string) (boxRange, error) {
+	hdr := make([]byte, 16)
+	for pos := start; pos+8 <= end; {
+		if _, err := r.ReadAt(hdr[:8], pos); err != nil {
+			return boxRange{}, ErrMalformed
+		}
+		boxSize := int64(binary.BigEndian.Uint32(hdr[:4]))
+		typ := string(hdr[4:8])
+		headerLen := int64(8)
+		switch boxSize {
+		case 0: // 一直到文件结尾
+			boxSize = end - pos
+		case 1: // 64 位长度
+			if _, err := r.ReadAt(hdr[8:16], pos+8); err != nil {
+				return boxRange{}, ErrMalformed
+			}
+			boxSize = int64(binary.BigEndian.Uint64(hdr[8:16]))
+			headerLen = 16
+		}
+		if boxSize < headerLen || pos+boxSize > end {
+			return boxRange{}, ErrMalformed
+		}
+		if typ == want {
+			return boxRange{offset: pos + headerLen, size: boxSize - headerLen}, nil
+		}
+		pos += boxSize
+	}
+	return boxRange{}, ErrMalformed
+}
+
+// walkBoxes 遍历内存中的一层 box
+func walkBoxes(data []byte, fn func(typ string, body []byte)) {
+	for len(data) >= 8 {
+		boxSize := uint64(binary.BigEndian.Uint32(data[:4]))
+		typ := string(data[4:8])
+		headerLen := uint64(8)
+		switch boxSize {
+		case 0:
+			boxSize = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return
+			}
+			boxSize = binary.BigEndian.Uint64(data[8:16])
+			headerLen = 16
+		}
+		if boxSize < headerLen || boxSize > uint64(len(data)) {
+			return
+		}
+		fn(typ, data[headerLen:boxSize])
+		data = data[boxSize:]
+	}
+}
+
+func mvhdDuration(b []byte) time.Duration {
+	if len(b) < 4 {
+		return 0
+	}
+	var timescale, duration uint64
+	if b[0] == 1 {
+		// version(1) flags(3) creation(8) modification(8) timescale(4) duration(8)
+		if len(b) < 32 {
+			return 0
+		}
+		timescale = uint64(binary.BigEndian.Uint32(b[20:24]))
+		duration = binary.BigEndian.Uint64(b[24:32])
+	} else {
+		// version(1) flags(3) creation(4) modification(4) timescale(4) duration(4)
+		if len(b) < 20 {
+			return 0
+		}
+		timescale = uint64(binary.BigEndian.Uint32(b[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(b[16:20]))
+	}
+	if timescale == 0 || duration == 0xFFFFFFFF || duration == 0xFFFFFFFFFFFFFFFF {
+		return 0
+	}
+	return time.Duration(duration * uint64(time.Second) / timescale)
+}
+
+func tkhdSize(b []byte) (int, int) {
+	// 宽高是 tkhd 最后 8 个字节 (16.16 定点数)
+	need := 84
+	if len(b) > 0 && b[0] == 1 {
+		need = 96
+	}
+	if len(b) < need {
+		return 0, 0
+	}
+	w := binary.BigEndian.Uint32(b[need-8:need-4]) >> 16
+	h := binary.BigEndian.Uint32(b[need-4:need]) >> 16
+	return int(w), int(h)
+}
+
+// probeWAV 时长 = data 块长度 / fmt 块中的每秒字节数
+func probeWAV(r io.ReaderAt, size int64) (Info, error) {
+	hdr := make([]byte, 12)
+	if _, err := r.ReadAt(hdr, 0); err != nil || string(hdr[0:4]) != "RIFF" || string(hdr[8:12]) != "WAVE" {
+		return Info{}, ErrMalformed
+	}
+
+	var byteRate uint32
+	chunk := make([]byte, 8)
+	for pos := int64(12); pos+8 <= size; {
+		if _, err := r.ReadAt(chunk, pos); err != nil {
+			return Info{}, ErrMalformed
+		}
+		id := string(chunk[:4])
+		n := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		switch id {
+		case "fmt ":
+			fmtBody := make([]byte, 12)
+			if _, err := r.ReadAt(fmtBody, pos+8); err != nil {
+				return Info{}, ErrMalformed
+			}
+			byteRate = binary.LittleEndian.Uint32(fmtBody[8:12])
+		case "data":
+			if byteRate == 0 {
+				return Info{}, ErrMalformed
+			}
+			if pos+8+n > size {
+				n = size - pos - 8
+			}
+			return Info{Duration: time.Duration(n) * time.Second / time.Duration(byteRate)}, nil
+		}
+		pos += 8 + n + n%2 // 块按偶数字节对齐
+	}
+	return Info{}, ErrMalformed
+}
+
+var (
+	mp3Bitrates = [2][16]int{ // kbps，[MPEG-1, MPEG-2/2.5] Layer III
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	}
+	mp3SampleRates = [4][3]int{ // [version][index]
+		{11025, 12000, 8000},  // MPEG-2.5
+		{0, 0, 0},             // 保留
+		{22050, 24000, 16000}, // MPEG-2
+		{44100, 48000, 32000}, // MPEG-1
+	}
+)
+
+// isMP3Frame 是否以 ID3 标签或 MPEG Layer III 帧头开头
+func isMP3Frame(b []byte) bool {
+	if len(b) >= 3 && string(b[:3]) == "ID3" {
+		return true
+	}
+	_, ok := parseMP3Header(b)
+	return ok
+}
+
+type mp3Header struct {
+	version    int // 3: MPEG-1, 2: MPEG-2, 0: MPEG-2.5
+	channels   int
+	bitrate    int // kbps
+	sampleRate int
+}
+
+func parseMP3Header(b []byte) (mp3Header, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return mp3Header{}, false
+	}
+	version := int(b[1]>>3) & 0x03
+	layer := int(b[1]>>1) & 0x03
+	bitrateIdx := int(b[2] >> 4)
+	rateIdx := int(b[2]>>2) & 0x03
+	if version == 1 || layer != 1 || bitrateIdx == 0 || bitrateIdx == 15 || rateIdx == 3 {
+		return mp3Header{}, false
+	}
+
+	h := mp3Header{version: version, channels: 2, sampleRate: mp3SampleRates[version][rateIdx]}
+	if b[3]>>6 == 3 {
+		h.channels = 1
+	}
+	if version == 3 {
+		h.bitrate = mp3Bitrates[0][bitrateIdx]
+	} else {
+		h.bitrate = mp3Bitrates[1][bitrateIdx]
+	}
+	return h, true
+}
+
+// probeMP3 有 Xing/Info 头时按总帧数计算 (VBR)，否则按第一帧的码率估算 (CBR)
+func probeMP3(r io.ReaderAt, size int64) (Info, error) {
+	start := int64(0)
+	id3 := make([]byte, 10)
+	if _, err := r.ReadAt(id3, 0); err == nil && string(id3[:3]) == "ID3" {
+		// ID3v2 长度为 4 个 7 位字节
+		tagLen := int64(id3[6])<<21 | int64(id3[7])<<14 | int64(id3[8])<<7 | int64(id3[9])
+		start = 10 + tagLen
+		if id3[5]&0x10 != 0 {
+			start += 10 // footer
+		}
+	}
+
+	frame := make([]byte, 200)
+	n, _ := r.ReadAt(frame, start)
+	frame = frame[:n]
+	h, ok := parseMP3Header(frame)
+	if !ok {
+		return Info{}, ErrMalformed
+	}
+
+	samplesPerFrame := 1152
+	sideInfo := 32
+	if h.version != 3 {
+		samplesPerFrame = 576
+		sideInfo = 17
+	}
+	if h.channels == 1 {
+		if h.version == 3 {
+			sideInfo = 17
+		} else {
+			sideInfo = 9
+		}
+	}
+
+	// Xing / Info 头位于第一帧 side info 之后
+	xing := 4 + sideInfo
+	if len(frame) >= xing+12 && (string(frame[xing:xing+4]) == "Xing" || string(frame[xing:xing+4]) == "Info") {
+		flags := binary.BigEndian.Uint32(frame[xing+4 : xing+8])
+		if flags&0x01 != 0 {
+			frames := int64(binary.BigEndian.Uint32(frame[xing+8 : xing+12]))
+			return Info{Duration: time.Duration(frames*int64(samplesPerFrame)) * time.Second / time.Duration(h.sampleRate)}, nil
+		}
+	}
+
+	audioBytes := size - start
+	return Info{Duration: time.Duration(audioBytes*8) * time.Millisecond / time.Duration(h.bitrate)}, nil
+}
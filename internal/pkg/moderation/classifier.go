@@ -0,0 +1,108 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// 外部服务响应体的读取上限
+const maxClassifierResponse = 64 << 10
+
+// ClassifierOptions 外部分类服务配置
+//
+// 请求：POST URL，{"user_id": 1, "target_id": 2, "content": "..."}
+// 响应：{"action": "allow|mask|review|reject", "reason": "...", "label": "...", "content": "屏蔽后的内容 (mask 时)"}
+type ClassifierOptions struct {
+	URL        string
+	Token      string        // 非空时以 Bearer 方式放在 Authorization 头中
+	Timeout    time.Duration // 默认 2 秒，超时即视为不可用
+	FailAction Action        // 服务不可用或响应无效时的处理，默认放行
+	FailReason string
+}
+
+// ClassifierFilter 调用外部 HTTP 分类服务 (如自建模型或第三方内容安全接口)
+type ClassifierFilter struct {
+	opts   ClassifierOptions
+	client *http.Client
+}
+
+func NewClassifierFilter(opts ClassifierOptions) *ClassifierFilter {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Second
+	}
+	if opts.FailReason == "" {
+		opts.FailReason = "内容审核服务暂不可用"
+	}
+	return &ClassifierFilter{opts: opts, client: &http.Client{Timeout: opts.Timeout}}
+}
+
+func (f *ClassifierFilter) Name() string { return "classifier" }
+
+type classifierRequest struct {
+	UserID   uint   `json:"user_id"`
+	TargetID uint   `json:"target_id"`
+	Content  string `json:"content"`
+}
+
+type classifierResponse struct {
+	Action  Action `json:"action"`
+	Reason  string `json:"reason"`
+	Label   string `json:"label"`
+	Content string `json:"content"`
+}
+
+func (f *ClassifierFilter) Check(ctx context.Context, in Input) (Verdict, error) {
+	resp, err := f.classify(ctx, in)
+	if err != nil {
+		v := Verdict{Action: f.opts.FailAction}
+		if v.Action != Allow {
+			v.Reason, v.Match = f.opts.FailReason, "unavailable"
+		}
+		return v, err
+	}
+
+	v := Verdict{Action: resp.Action, Reason: resp.Reason, Match: resp.Label}
+	if resp.Action == Mask {
+		if resp.Content == "" {
+			// 没有给出屏蔽后的内容，无法屏蔽，交给人工处理
+			v.Action = Review
+		}
+		v.Content = resp.Content
+	}
+	return v, nil
+}
+
+func (f *ClassifierFilter) classify(ctx context.Context, in Input) (*classifierResponse, error) {
+	body, err := json.Marshal(classifierRequest{UserID: in.UserID, TargetID: in.TargetID, Content: in.Content})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if f.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.opts.Token)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("moderation: classifier returned %s", resp.Status)
+	}
+
+	var out classifierResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxClassifierResponse)).Decode(&out); err != nil {
+		return nil, fmt.Errorf("moderation: decode classifier response: %w", err)
+	}
+	return &out, nil
+}
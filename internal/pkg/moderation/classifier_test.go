@@ -0,0 +1,92 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClassifierFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		delay       time.Duration
+		wantAction  Action
+		wantReason  string
+		wantMatch   string
+		wantContent string
+		wantErr     bool
+	}{
+		{name: "allow", status: 200, body: `{"action":"allow"}`, wantAction: Allow},
+		{name: "reject", status: 200, body: `{"action":"reject","reason":"涉政","label":"politics"}`,
+			wantAction: Reject, wantReason: "涉政", wantMatch: "politics"},
+		{name: "mask", status: 200, body: `{"action":"mask","reason":"敏感词","content":"**"}`,
+			wantAction: Mask, wantReason: "敏感词", wantContent: "**"},
+		// 没有给出屏蔽后的内容，转人工
+		{name: "mask without content", status: 200, body: `{"action":"mask","reason":"敏感词"}`,
+			wantAction: Review, wantReason: "敏感词"},
+		// 以下均视为服务不可用，按 FailAction 处理
+		{name: "unknown action", status: 200, body: `{"action":"block"}`,
+			wantAction: Review, wantReason: "审核服务不可用", wantMatch: "unavailable", wantErr: true},
+		{name: "invalid json", status: 200, body: `not json`,
+			wantAction: Review, wantReason: "审核服务不可用", wantMatch: "unavailable", wantErr: true},
+		{name: "non-200", status: 503, body: `{"action":"allow"}`,
+			wantAction: Review, wantReason: "审核服务不可用", wantMatch: "unavailable", wantErr: true},
+		{name: "timeout", status: 200, body: `{"action":"allow"}`, delay: 500 * time.Millisecond,
+			wantAction: Review, wantReason: "审核服务不可用", wantMatch: "unavailable", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req classifierRequest
+				if r.Header.Get("Authorization") != "Bearer token" || json.NewDecoder(r.Body).Decode(&req) != nil ||
+					req.UserID != 1 || req.TargetID != 2 || req.Content != "hello" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if tt.delay > 0 {
+					select {
+					case <-time.After(tt.delay):
+					case <-r.Context().Done():
+					}
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			f := NewClassifierFilter(ClassifierOptions{
+				URL:        srv.URL,
+				Token:      "token",
+				Timeout:    100 * time.Millisecond,
+				FailAction: Review,
+				FailReason: "审核服务不可用",
+			})
+			v, err := f.Check(context.Background(), Input{UserID: 1, TargetID: 2, Content: "hello"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if v.Action != tt.wantAction || v.Reason != tt.wantReason || v.Match != tt.wantMatch || v.Content != tt.wantContent {
+				t.Fatalf("got %+v, want action=%s reason=%q match=%q content=%q",
+					v, tt.wantAction, tt.wantReason, tt.wantMatch, tt.wantContent)
+			}
+		})
+	}
+}
+
+// 默认 FailAction 为放行，不可用时不带原因
+func TestClassifierFilterFailOpen(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	v, err := NewClassifierFilter(ClassifierOptions{URL: srv.URL}).Check(context.Background(), Input{Content: "hello"})
+	if err == nil || v != (Verdict{Action: Allow}) {
+		t.Fatalf("got %+v, %v", v, err)
+	}
+}
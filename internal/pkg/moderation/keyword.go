@@ -0,0 +1,100 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// 记录到 Match 中的命中词数量上限
+const maxMatchesRecorded = 10
+
+// KeywordRule 一组关键词/正则及命中后的处理方式
+type KeywordRule struct {
+	Action   Action
+	Reason   string
+	Words    []string // 关键词，不区分大小写
+	Patterns []string // 正则表达式 (RE2 语法)
+}
+
+type keywordRule struct {
+	action Action
+	reason string
+	re     *regexp.Regexp
+}
+
+// KeywordFilter 关键词和正则过滤
+type KeywordFilter struct {
+	rules []keywordRule
+}
+
+// NewKeywordFilter 每条规则的关键词和正则合并编译为一个正则，规则有误时返回错误
+func NewKeywordFilter(rules []KeywordRule) (*KeywordFilter, error) {
+	f := &KeywordFilter{}
+	for i, r := range rules {
+		if r.Action == Allow {
+			continue
+		}
+		var alts []string
+		for _, w := range r.Words {
+			if w = strings.TrimSpace(w); w != "" {
+				alts = append(alts, regexp.QuoteMeta(w))
+			}
+		}
+		for _, p := range r.Patterns {
+			if _, err := regexp.Compile(p); err != nil {
+				return nil, fmt.Errorf("moderation: keyword rule %d: %w", i, err)
+			}
+			alts = append(alts, "(?:"+p+")")
+		}
+		if len(alts) == 0 {
+			continue
+		}
+		re, err := regexp.Compile("(?i)(?:" + strings.Join(alts, "|") + ")")
+		if err != nil {
+			return nil, fmt.Errorf("moderation: keyword rule %d: %w", i, err)
+		}
+		f.rules = append(f.rules, keywordRule{action: r.Action, reason: r.Reason, re: re})
+	}
+	return f, nil
+}
+
+func (f *KeywordFilter) Name() string { return "keyword" }
+
+// Check 命中屏蔽规则的内容替换为等长的 *，其余规则取最严重的判定
+func (f *KeywordFilter) Check(_ context.Context, in Input) (Verdict, error) {
+	v := Verdict{Action: Allow}
+	content := in.Content
+	masked := false
+	var matches []string
+
+	for _, r := range f.rules {
+		found := r.re.FindAllString(content, -1)
+		if len(found) == 0 {
+			continue
+		}
+		for _, m := range found {
+			if len(matches) < maxMatchesRecorded {
+				matches = append(matches, m)
+			}
+		}
+		if r.action == Mask {
+			content = r.re.ReplaceAllStringFunc(content, func(m string) string {
+				return strings.Repeat("*", utf8.RuneCountInString(m))
+			})
+			masked = true
+		}
+		if r.action > v.Action {
+			v.Action = r.action
+			v.Reason = r.reason
+		}
+	}
+
+	v.Match = strings.Join(matches, ",")
+	if masked {
+		v.Content = content
+	}
+	return v, nil
+}
@@ -0,0 +1,55 @@
+package moderation
+
+import (
+	"context"
+	"testing"
+)
+
+func TestKeywordFilter(t *testing.T) {
+	f, err := NewKeywordFilter([]KeywordRule{
+		{Action: Mask, Reason: "不文明用语", Words: []string{"笨蛋", "Stupid"}},
+		{Action: Mask, Reason: "手机号", Patterns: []string{`1[3-9]\d{9}`}},
+		{Action: Review, Reason: "疑似广告", Words: []string{"加微信"}},
+		{Action: Reject, Reason: "违禁内容", Words: []string{"违禁品"}},
+		{Action: Allow, Words: []string{"被忽略"}},
+	})
+	if err != nil {
+		t.Fatalf("new keyword filter: %v", err)
+	}
+
+	tests := []struct {
+		content     string
+		wantAction  Action
+		wantReason  string
+		wantContent string
+		wantMatch   string
+	}{
+		{"你好", Allow, "", "", ""},
+		{"被忽略的词", Allow, "", "", ""},
+		// 中文按字符数替换，而不是按字节数
+		{"你这个笨蛋", Mask, "不文明用语", "你这个**", "笨蛋"},
+		{"STUPID 笨蛋 stupid", Mask, "不文明用语", "****** ** ******", "STUPID,笨蛋,stupid"},
+		{"电话13812345678", Mask, "手机号", "电话***********", "13812345678"},
+		// 屏蔽之后继续按更严重的规则判定，屏蔽后的内容保留
+		{"笨蛋，加微信", Review, "疑似广告", "**，加微信", "笨蛋,加微信"},
+		{"出售违禁品", Reject, "违禁内容", "", "违禁品"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.content, func(t *testing.T) {
+			v, err := f.Check(context.Background(), Input{Content: tt.content})
+			if err != nil {
+				t.Fatalf("check: %v", err)
+			}
+			if v.Action != tt.wantAction || v.Reason != tt.wantReason || v.Content != tt.wantContent || v.Match != tt.wantMatch {
+				t.Fatalf("got %+v, want action=%s reason=%q content=%q match=%q",
+					v, tt.wantAction, tt.wantReason, tt.wantContent, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestKeywordFilterInvalidPattern(t *testing.T) {
+	if _, err := NewKeywordFilter([]KeywordRule{{Action: Reject, Patterns: []string{"("}}}); err == nil {
+		t.Fatal("want error for invalid pattern")
+	}
+}
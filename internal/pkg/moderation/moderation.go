@@ -0,0 +1,130 @@
+// Package moderation 消息内容审核
+// 消息依次经过各个过滤器 (关键词、垃圾消息、外部分类服务等)，
+// 每个过滤器给出放行、屏蔽、人工审核或拒绝的判定，最终按最严重的判定处理
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Action 审核判定，数值越大越严重
+type Action int
+
+const (
+	Allow  Action = iota // 放行
+	Mask                 // 替换命中的内容后放行
+	Review               // 暂不投递，等待人工审核
+	Reject               // 拒绝发送
+)
+
+var actionNames = []string{"allow", "mask", "review", "reject"}
+
+func (a Action) String() string {
+	if a < 0 || int(a) >= len(actionNames) {
+		return fmt.Sprintf("action(%d)", int(a))
+	}
+	return actionNames[a]
+}
+
+// ParseAction 解析配置和外部服务返回的判定名称
+func ParseAction(s string) (Action, error) {
+	for i, name := range actionNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return Action(i), nil
+		}
+	}
+	return Allow, fmt.Errorf("moderation: unknown action %q", s)
+}
+
+func (a Action) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Action) UnmarshalText(text []byte) error {
+	v, err := ParseAction(string(text))
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Input 待审核的消息
+type Input struct {
+	UserID   uint   // 发送者
+	TargetID uint   // 接收者
+	Content  string // 文本内容 (媒体消息为说明文字)
+}
+
+// Verdict 单个过滤器的判定
+type Verdict struct {
+	Action  Action
+	Reason  string // 可以展示给发送者的原因
+	Match   string // 命中的关键词、规则等，只给管理员看
+	Content string // Action 为 Mask 时替换后的内容
+}
+
+// Filter 审核过滤器
+// 出错时可以同时返回判定 (如外部服务不可用时按配置放行或转人工)，Pipeline 会照常采用
+type Filter interface {
+	Name() string
+	Check(ctx context.Context, in Input) (Verdict, error)
+}
+
+// Hit 过滤器的一次命中
+type Hit struct {
+	Filter string `json:"filter"`
+	Action Action `json:"action"`
+	Reason string `json:"reason,omitempty"`
+	Match  string `json:"match,omitempty"`
+}
+
+// Result 审核结果
+type Result struct {
+	Action  Action
+	Content string // 最终内容，可能已被屏蔽处理
+	Reason  string // 最严重的那次命中的原因
+	Hits    []Hit
+}
+
+// Pipeline 按顺序执行的过滤器链，可并发使用
+type Pipeline struct {
+	filters []Filter
+}
+
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{filters: filters}
+}
+
+// Check 依次执行过滤器：屏蔽后的内容交给后面的过滤器继续检查，遇到拒绝立即结束
+// 返回的 error 只用于记录日志，结果总是可用的
+func (p *Pipeline) Check(ctx context.Context, in Input) (Result, error) {
+	res := Result{Action: Allow, Content: in.Content}
+	var errs []error
+	for _, f := range p.filters {
+		v, err := f.Check(ctx, in)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Name(), err))
+		}
+		if v.Action == Allow {
+			continue
+		}
+
+		res.Hits = append(res.Hits, Hit{Filter: f.Name(), Action: v.Action, Reason: v.Reason, Match: v.Match})
+		if v.Action == Mask && v.Content != "" {
+			res.Content = v.Content
+			in.Content = v.Content
+		}
+		if v.Action > res.Action {
+			res.Action = v.Action
+			res.Reason = v.Reason
+		}
+		if res.Action == Reject {
+			break
+		}
+	}
+	return res, errors.Join(errs...)
+}
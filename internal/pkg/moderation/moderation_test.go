@@ -0,0 +1,151 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// stubFilter 返回固定判定，并记录收到的内容
+type stubFilter struct {
+	name    string
+	verdict Verdict
+	err     error
+	seen    *[]string
+}
+
+func (f stubFilter) Name() string { return f.name }
+
+func (f stubFilter) Check(_ context.Context, in Input) (Verdict, error) {
+	if f.seen != nil {
+		*f.seen = append(*f.seen, f.name+":"+in.Content)
+	}
+	return f.verdict, f.err
+}
+
+func TestPipelineCheck(t *testing.T) {
+	allow := Verdict{Action: Allow}
+	mask := Verdict{Action: Mask, Reason: "敏感词", Content: "** world"}
+	review := Verdict{Action: Review, Reason: "疑似广告"}
+	reject := Verdict{Action: Reject, Reason: "违规"}
+
+	tests := []struct {
+		name        string
+		verdicts    []Verdict
+		wantAction  Action
+		wantReason  string
+		wantContent string
+		wantSeen    []string
+		wantHits    int
+	}{
+		{
+			name:        "all allow",
+			verdicts:    []Verdict{allow, allow},
+			wantAction:  Allow,
+			wantContent: "hi world",
+			wantSeen:    []string{"f0:hi world", "f1:hi world"},
+		},
+		{
+			name:        "most severe wins",
+			verdicts:    []Verdict{review, mask, allow},
+			wantAction:  Review,
+			wantReason:  "疑似广告",
+			wantContent: "** world",
+			wantSeen:    []string{"f0:hi world", "f1:hi world", "f2:** world"},
+			wantHits:    2,
+		},
+		{
+			name:        "later filters see masked content",
+			verdicts:    []Verdict{mask, review},
+			wantAction:  Review,
+			wantReason:  "疑似广告",
+			wantContent: "** world",
+			wantSeen:    []string{"f0:hi world", "f1:** world"},
+			wantHits:    2,
+		},
+		{
+			name:        "reject stops the pipeline",
+			verdicts:    []Verdict{mask, reject, review},
+			wantAction:  Reject,
+			wantReason:  "违规",
+			wantContent: "** world",
+			wantSeen:    []string{"f0:hi world", "f1:** world"},
+			wantHits:    2,
+		},
+		{
+			name:        "less severe verdict keeps the earlier reason",
+			verdicts:    []Verdict{review, {Action: Mask, Reason: "敏感词"}},
+			wantAction:  Review,
+			wantReason:  "疑似广告",
+			wantContent: "hi world", // 没有给出屏蔽后的内容时保持原样
+			wantSeen:    []string{"f0:hi world", "f1:hi world"},
+			wantHits:    2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen []string
+			var filters []Filter
+			for i, v := range tt.verdicts {
+				filters = append(filters, stubFilter{name: "f" + string(rune('0'+i)), verdict: v, seen: &seen})
+			}
+
+			res, err := NewPipeline(filters...).Check(context.Background(), Input{Content: "hi world"})
+			if err != nil {
+				t.Fatalf("check: %v", err)
+			}
+			if res.Action != tt.wantAction || res.Reason != tt.wantReason || res.Content != tt.wantContent {
+				t.Fatalf("got action=%s reason=%q content=%q, want %s %q %q",
+					res.Action, res.Reason, res.Content, tt.wantAction, tt.wantReason, tt.wantContent)
+			}
+			if len(res.Hits) != tt.wantHits {
+				t.Fatalf("hits = %+v, want %d", res.Hits, tt.wantHits)
+			}
+			if len(seen) != len(tt.wantSeen) {
+				t.Fatalf("filters saw %q, want %q", seen, tt.wantSeen)
+			}
+			for i := range seen {
+				if seen[i] != tt.wantSeen[i] {
+					t.Fatalf("filters saw %q, want %q", seen, tt.wantSeen)
+				}
+			}
+		})
+	}
+}
+
+// 过滤器出错时仍采用它给出的判定，错误汇总返回
+func TestPipelineFilterError(t *testing.T) {
+	errDown := errors.New("down")
+	p := NewPipeline(
+		stubFilter{name: "classifier", verdict: Verdict{Action: Review, Reason: "不可用"}, err: errDown},
+		stubFilter{name: "keyword", verdict: Verdict{Action: Allow}},
+	)
+	res, err := p.Check(context.Background(), Input{Content: "hi"})
+	if !errors.Is(err, errDown) {
+		t.Fatalf("want joined error, got %v", err)
+	}
+	if res.Action != Review || len(res.Hits) != 1 || res.Hits[0].Filter != "classifier" {
+		t.Fatalf("got %+v", res)
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Action
+		wantErr bool
+	}{
+		{"allow", Allow, false},
+		{"Mask", Mask, false},
+		{" REVIEW ", Review, false},
+		{"reject", Reject, false},
+		{"block", Allow, true},
+		{"", Allow, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAction(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseAction(%q) = %s, %v", tt.in, got, err)
+		}
+	}
+}
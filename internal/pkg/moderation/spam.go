@@ -0,0 +1,105 @@
+package moderation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 短于该长度的内容 (如 "好的"、"哈哈") 重复发送很正常，不参与重复检测
+const minDuplicateRunes = 5
+
+// Counter 计数器，统计一段时间内的次数，由调用方基于 Redis 等实现以便多实例共享
+type Counter interface {
+	// Incr 计数加一并返回当前值，首次计数时开始 window 的有效期
+	Incr(ctx context.Context, key string, window time.Duration) (int64, error)
+}
+
+// SpamOptions 垃圾消息规则，为 0 的项不检查
+type SpamOptions struct {
+	Action          Action // 命中后的处理，默认 Review
+	Reason          string
+	MaxLinks        int           // 单条消息最多包含的链接数
+	MaxRepeat       int           // 同一字符最多连续出现的次数
+	DuplicateLimit  int           // 窗口内发送相同内容的次数上限 (不区分接收者)
+	DuplicateWindow time.Duration // 默认 1 分钟
+	Counter         Counter       // 重复检测使用，为 nil 时不检查
+}
+
+// SpamFilter 垃圾消息启发式规则：链接过多、字符刷屏、短时间内群发相同内容
+type SpamFilter struct {
+	opts SpamOptions
+}
+
+func NewSpamFilter(opts SpamOptions) *SpamFilter {
+	if opts.Action == Allow {
+		opts.Action = Review
+	}
+	if opts.Reason == "" {
+		opts.Reason = "疑似垃圾消息"
+	}
+	if opts.DuplicateWindow <= 0 {
+		opts.DuplicateWindow = time.Minute
+	}
+	return &SpamFilter{opts: opts}
+}
+
+func (f *SpamFilter) Name() string { return "spam" }
+
+var linkPattern = regexp.MustCompile(`(?i)https?://`)
+
+func (f *SpamFilter) Check(ctx context.Context, in Input) (Verdict, error) {
+	hit := func(match string) (Verdict, error) {
+		return Verdict{Action: f.opts.Action, Reason: f.opts.Reason, Match: match}, nil
+	}
+
+	if f.opts.MaxLinks > 0 {
+		if n := len(linkPattern.FindAllStringIndex(in.Content, -1)); n > f.opts.MaxLinks {
+			return hit(fmt.Sprintf("links=%d", n))
+		}
+	}
+	if f.opts.MaxRepeat > 0 {
+		if n := longestRun(in.Content); n > f.opts.MaxRepeat {
+			return hit(fmt.Sprintf("repeat=%d", n))
+		}
+	}
+	if f.opts.DuplicateLimit > 0 && f.opts.Counter != nil {
+		normalized := strings.ToLower(strings.Join(strings.Fields(in.Content), " "))
+		if utf8.RuneCountInString(normalized) < minDuplicateRunes {
+			return Verdict{Action: Allow}, nil
+		}
+		sum := sha256.Sum256([]byte(normalized))
+		key := fmt.Sprintf("%d:%s", in.UserID, hex.EncodeToString(sum[:12]))
+		n, err := f.opts.Counter.Incr(ctx, key, f.opts.DuplicateWindow)
+		if err != nil {
+			return Verdict{Action: Allow}, err // 计数失败时放行
+		}
+		if n > int64(f.opts.DuplicateLimit) {
+			return hit(fmt.Sprintf("duplicate=%d", n))
+		}
+	}
+	return Verdict{Action: Allow}, nil
+}
+
+// longestRun 同一字符 (不含空白) 最长的连续出现次数
+func longestRun(s string) int {
+	longest, run := 0, 0
+	var prev rune = -1
+	for _, r := range s {
+		if r == prev && r != ' ' && r != '\n' && r != '\t' {
+			run++
+		} else {
+			run = 1
+		}
+		prev = r
+		if run > longest {
+			longest = run
+		}
+	}
+	return longest
+}
@@ -0,0 +1,123 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// memCounter 进程内计数器，记录每次计数的窗口
+type memCounter struct {
+	counts  map[string]int64
+	windows []time.Duration
+	err     error
+}
+
+func (c *memCounter) Incr(_ context.Context, key string, window time.Duration) (int64, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	if c.counts == nil {
+		c.counts = make(map[string]int64)
+	}
+	c.windows = append(c.windows, window)
+	c.counts[key]++
+	return c.counts[key], nil
+}
+
+func TestLongestRun(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"abc", 1},
+		{"aab", 2},
+		{"abbbbc", 4},
+		{"哈哈哈哈哈", 5},
+		{"!!!???!!!!", 4},
+		{"a     b", 1}, // 空白不计
+		{"\n\n\n\t\t", 1},
+	}
+	for _, tt := range tests {
+		if got := longestRun(tt.in); got != tt.want {
+			t.Errorf("longestRun(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSpamFilterLinksAndRepeat(t *testing.T) {
+	f := NewSpamFilter(SpamOptions{MaxLinks: 2, MaxRepeat: 5})
+	tests := []struct {
+		content    string
+		wantAction Action
+		wantMatch  string
+	}{
+		{"看看 https://a.com 和 http://b.com", Allow, ""},
+		{"https://a.com http://b.com HTTPS://c.com", Review, "links=3"},
+		{"哈哈哈哈哈", Allow, ""},
+		{"哈哈哈哈哈哈", Review, "repeat=6"},
+		{"a      b", Allow, ""},
+	}
+	for _, tt := range tests {
+		v, err := f.Check(context.Background(), Input{Content: tt.content})
+		if err != nil {
+			t.Fatalf("check: %v", err)
+		}
+		if v.Action != tt.wantAction || v.Match != tt.wantMatch {
+			t.Errorf("%q: got %s %q, want %s %q", tt.content, v.Action, v.Match, tt.wantAction, tt.wantMatch)
+		}
+		if v.Action != Allow && v.Reason != "疑似垃圾消息" {
+			t.Errorf("%q: default reason = %q", tt.content, v.Reason)
+		}
+	}
+}
+
+// 窗口内相同内容超过 DuplicateLimit 次才命中；大小写、空白不同视为相同内容，短内容不检查
+func TestSpamFilterDuplicate(t *testing.T) {
+	counter := &memCounter{}
+	f := NewSpamFilter(SpamOptions{Action: Reject, DuplicateLimit: 2, Counter: counter})
+	ctx := context.Background()
+
+	tests := []struct {
+		userID     uint
+		content    string
+		wantAction Action
+	}{
+		{1, "Buy cheap stuff", Allow},
+		{1, "buy  CHEAP\nstuff", Allow},
+		{1, "buy cheap stuff", Reject}, // 第 3 次
+		{2, "buy cheap stuff", Allow},  // 按发送者分别计数
+		{1, "好的", Allow},               // 短内容
+		{1, "好的", Allow},
+		{1, "好的", Allow},
+		{1, "  好  的  ", Allow},
+	}
+	for i, tt := range tests {
+		v, err := f.Check(ctx, Input{UserID: tt.userID, Content: tt.content})
+		if err != nil {
+			t.Fatalf("#%d check: %v", i, err)
+		}
+		if v.Action != tt.wantAction {
+			t.Errorf("#%d user %d %q: got %s, want %s", i, tt.userID, tt.content, v.Action, tt.wantAction)
+		}
+	}
+	if len(counter.windows) != 4 {
+		t.Fatalf("counter called %d times, want 4 (short content skipped)", len(counter.windows))
+	}
+	if counter.windows[0] != time.Minute {
+		t.Fatalf("default window = %s, want 1m", counter.windows[0])
+	}
+}
+
+// 计数失败时放行并返回错误
+func TestSpamFilterCounterError(t *testing.T) {
+	errRedis := errors.New("redis down")
+	f := NewSpamFilter(SpamOptions{DuplicateLimit: 1, Counter: &memCounter{err: errRedis}})
+	v, err := f.Check(context.Background(), Input{Content: "hello world"})
+	if v.Action != Allow || !errors.Is(err, errRedis) {
+		t.Fatalf("got %s, %v", v.Action, err)
+	}
+}
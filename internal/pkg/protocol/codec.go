@@ -0,0 +1,87 @@
+package protocol
+
+import (
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket 子协议名称，客户端通过 Sec-WebSocket-Protocol 头协商编码格式
+const (
+	SubprotocolJSON     = "gochat.json"  // JSON 文本帧 (默认)
+	SubprotocolProtobuf = "gochat.proto" // Protobuf 二进制帧，定义见 protocol.proto
+)
+
+// Codec 消息编解码器
+// JSON 与 Protobuf 共用同一套 Envelope 语义，只是线上格式不同
+type Codec interface {
+	// Name 返回对应的子协议名称
+	Name() string
+	// FrameType 返回发送时使用的 WebSocket 帧类型
+	FrameType() int
+	// Decode 解析客户端发来的帧
+	Decode(data []byte) (*Envelope, error)
+	// Encode 编码发送给客户端的帧
+	Encode(env *Envelope) ([]byte, error)
+}
+
+var (
+	JSONCodec     Codec = jsonCodec{}
+	ProtobufCodec Codec = protobufCodec{}
+)
+
+// Subprotocols 服务端支持的子协议列表
+// 顺序即优先级：客户端同时声明多个时优先选用二进制格式
+func Subprotocols() []string {
+	return []string{SubprotocolProtobuf, SubprotocolJSON}
+}
+
+// CodecFor 根据握手协商出的子协议选择编解码器
+// 未声明子协议的老客户端继续使用 JSON
+func CodecFor(subprotocol string) Codec {
+	switch subprotocol {
+	case SubprotocolProtobuf:
+		return ProtobufCodec
+	default:
+		return JSONCodec
+	}
+}
+
+// jsonCodec JSON 文本帧编解码
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return SubprotocolJSON }
+
+func (jsonCodec) FrameType() int { return websocket.TextMessage }
+
+// jsonFrame 同时兼容新版信封和旧版平铺的 {type,target_id,content}
+type jsonFrame struct {
+	Envelope
+	TargetID uint   `json:"target_id"`
+	Content  string `json:"content"`
+}
+
+func (jsonCodec) Decode(data []byte) (*Envelope, error) {
+	var frame jsonFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return nil, err
+	}
+
+	env := frame.Envelope
+	if env.Version == 0 {
+		// 旧版客户端：消息字段平铺在顶层
+		env.Message = &Message{TargetID: frame.TargetID, Content: frame.Content}
+	}
+	if env.Message != nil {
+		env.Message.Type = env.Type
+	}
+	return &env, nil
+}
+
+func (jsonCodec) Encode(env *Envelope) ([]byte, error) {
+	// 旧版客户端只认识扁平的 Reply
+	if env.Version == 0 && env.Reply != nil {
+		return json.Marshal(env.Reply)
+	}
+	return json.Marshal(env)
+}
@@ -0,0 +1,179 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 每种负载各一个信封，Reply 带上附件和多个缩略图
+var testEnvelopes = []struct {
+	name string
+	env  *Envelope
+}{
+	{"msg", &Envelope{Version: Version, RequestID: "req-1", Type: TypeSingleMsg,
+		Message: &Message{Type: TypeSingleMsg, TargetID: 42, Content: "你好 hello", MediaID: 7}}},
+	{"reply", &Envelope{Version: Version, Type: TypeSingleMsg, Reply: &Reply{
+		MsgID: 1001, FromID: 3, Type: TypeSingleMsg, Content: "看图", SendTime: 1700000000, Media: 2,
+		Attachment: &Attachment{
+			ID: 9, Kind: 2, URL: "https://cdn.example.com/a.jpg?sig=1", ExpiresAt: 1700003600,
+			MimeType: "image/jpeg", Size: 123456, Width: 1920, Height: 1080, DurationMs: 0,
+			FileName: "照片.jpg", Blurhash: "LEHV6nWB2yk8pyo0adR*.7kCMdnj",
+			Thumbnails: []Thumbnail{
+				{URL: "https://cdn.example.com/a_200.jpg", Width: 200, Height: 113},
+				{URL: "https://cdn.example.com/a_800.jpg", Width: 800, Height: 450},
+			},
+		},
+	}}},
+	{"ack", &Envelope{Version: Version, RequestID: "req-2", Type: TypeAck,
+		Ack: &Ack{MsgID: 1002, SendTime: 1700000001, Status: AckStatusHeld, Content: "**", Reason: "敏感词"}}},
+	{"error", &Envelope{Version: Version, RequestID: "req-3", Type: TypeError,
+		Error: &Error{Code: ErrCodeNotFriend, Message: "对方不是你的好友"}}},
+	{"event", &Envelope{Version: Version, Type: TypeEvent,
+		Event: &Event{Name: EventMessageApproved, Data: json.RawMessage(`{"msg_id":1003}`)}}},
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec, ProtobufCodec} {
+		for _, tt := range testEnvelopes {
+			t.Run(codec.Name()+"/"+tt.name, func(t *testing.T) {
+				data, err := codec.Encode(tt.env)
+				if err != nil {
+					t.Fatalf("encode: %v", err)
+				}
+				got, err := codec.Decode(data)
+				if err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.env) {
+					t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", got, tt.env)
+				}
+			})
+		}
+	}
+}
+
+// 旧版客户端的平铺格式
+func TestJSONDecodeLegacy(t *testing.T) {
+	env, err := JSONCodec.Decode([]byte(`{"type":2,"target_id":42,"content":"hi"}`))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := &Message{Type: TypeSingleMsg, TargetID: 42, Content: "hi"}
+	if env.Version != 0 || !reflect.DeepEqual(env.Message, want) {
+		t.Fatalf("legacy frame decoded as %+v, msg %+v", env, env.Message)
+	}
+
+	// 旧版客户端收到的是扁平的 Reply
+	data, err := JSONCodec.Encode(&Envelope{Type: TypeSingleMsg, Reply: &Reply{FromID: 1, Type: TypeSingleMsg, Content: "hi"}})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	var reply Reply
+	if err := json.Unmarshal(data, &reply); err != nil || reply.FromID != 1 || reply.Content != "hi" {
+		t.Fatalf("legacy reply = %s, err %v", data, err)
+	}
+}
+
+// 不认识的字段 (包括嵌套消息中的) 被跳过，其余字段照常解析
+func TestProtobufSkipsUnknownFields(t *testing.T) {
+	for _, tt := range testEnvelopes {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := ProtobufCodec.Encode(tt.env)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			var unknown []byte
+			unknown = protowire.AppendTag(unknown, 99, protowire.VarintType)
+			unknown = protowire.AppendVarint(unknown, 12345)
+			unknown = protowire.AppendTag(unknown, 100, protowire.BytesType)
+			unknown = protowire.AppendString(unknown, "future")
+			unknown = protowire.AppendTag(unknown, 101, protowire.Fixed64Type)
+			unknown = protowire.AppendFixed64(unknown, 1)
+
+			got, err := ProtobufCodec.Decode(append(unknown, data...))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.env) {
+				t.Fatalf("mismatch:\n got %+v\nwant %+v", got, tt.env)
+			}
+		})
+	}
+
+	// 嵌套消息中的未知字段
+	var msg []byte
+	msg = protowire.AppendTag(msg, 50, protowire.BytesType)
+	msg = protowire.AppendString(msg, "x")
+	msg = protowire.AppendTag(msg, 3, protowire.BytesType)
+	msg = protowire.AppendString(msg, "hi")
+	var data []byte
+	data = protowire.AppendTag(data, 4, protowire.BytesType)
+	data = protowire.AppendBytes(data, msg)
+	env, err := ProtobufCodec.Decode(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if env.Message == nil || env.Message.Content != "hi" {
+		t.Fatalf("nested unknown field: got msg %+v", env.Message)
+	}
+}
+
+// 截断在任意位置的帧都应返回错误而不是 panic (截断恰好落在字段边界时是合法的帧)
+func TestProtobufTruncated(t *testing.T) {
+	for _, tt := range testEnvelopes {
+		data, err := ProtobufCodec.Encode(tt.env)
+		if err != nil {
+			t.Fatalf("%s: encode: %v", tt.name, err)
+		}
+		boundaries := topLevelBoundaries(t, data)
+		for i := 1; i < len(data); i++ {
+			_, err := ProtobufCodec.Decode(data[:i])
+			if !boundaries[i] && err == nil {
+				t.Errorf("%s: truncated at %d/%d: want error", tt.name, i, len(data))
+			}
+		}
+	}
+}
+
+// topLevelBoundaries 信封顶层字段的结束位置
+func topLevelBoundaries(t *testing.T, data []byte) map[int]bool {
+	t.Helper()
+	boundaries := make(map[int]bool)
+	for off := 0; off < len(data); {
+		_, _, n := protowire.ConsumeField(data[off:])
+		if n < 0 {
+			t.Fatalf("invalid frame at %d", off)
+		}
+		off += n
+		boundaries[off] = true
+	}
+	return boundaries
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec Codec
+		data  []byte
+	}{
+		{"json/invalid", JSONCodec, []byte(`{"v":1,"type":`)},
+		{"json/wrong type", JSONCodec, []byte(`{"v":"1"}`)},
+		{"json/not object", JSONCodec, []byte(`[1,2]`)},
+		{"pb/field number 0", ProtobufCodec, []byte{0x00, 0x01}},
+		{"pb/varint overflow", ProtobufCodec, []byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"pb/length past end", ProtobufCodec, []byte{0x22, 0x10, 0x08}},
+		{"pb/nested length past end", ProtobufCodec, []byte{0x2a, 0x02, 0x32, 0x05}},
+		{"pb/reserved wire type", ProtobufCodec, []byte{0x0f}},
+		{"pb/unterminated group", ProtobufCodec, []byte{0x0b}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.codec.Decode(tt.data); err == nil {
+				t.Fatalf("decode %x: want error", tt.data)
+			}
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package protocol
+
+import (
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protobufCodec Protobuf 二进制帧编解码
+// 消息结构很小，直接用 protowire 按 protocol.proto 的字段号读写，省去代码生成
+// 二进制客户端一律使用信封格式，没有旧版兼容分支
+// 上下行的负载都能解码，Go 编写的客户端 (压测工具等) 可以直接复用
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string { return SubprotocolProtobuf }
+
+func (protobufCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (protobufCodec) Decode(data []byte) (*Envelope, error) {
+	env := &Envelope{}
+	err := walkFields(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			env.Version = int(int32(v))
+			return n, nil
+		case num == 2 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			env.RequestID = v
+			return n, nil
+		case num == 3 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			env.Type = int(int32(v))
+			return n, nil
+		case num == 4 && typ == protowire.BytesType:
+			return consumeMessage(b, func(v []byte) (err error) {
+				env.Message, err = decodeMessage(v)
+				return err
+			})
+		case num == 5 && typ == protowire.BytesType:
+			return consumeMessage(b, func(v []byte) (err error) {
+				env.Reply, err = decodeReply(v)
+				return err
+			})
+		case num == 6 && typ == protowire.BytesType:
+			return consumeMessage(b, func(v []byte) (err error) {
+				env.Ack, err = decodeAck(v)
+				return err
+			})
+		case num == 7 && typ == protowire.BytesType:
+			return consumeMessage(b, func(v []byte) (err error) {
+				env.Error, err = decodeError(v)
+				return err
+			})
+		case num == 8 && typ == protowire.BytesType:
+			return consumeMessage(b, func(v []byte) (err error) {
+				env.Event, err = decodeEvent(v)
+				return err
+			})
+		}
+		// 不认识的字段直接跳过，保证前向兼容
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if env.Message != nil {
+		env.Message.Type = env.Type
+	}
+	return env, nil
+}
+
+func (protobufCodec) Encode(env *Envelope) ([]byte, error) {
+	var b []byte
+	// proto3 语义：零值字段不写入
+	b = appendVarint(b, 1, uint64(int32(env.Version)))
+	b = appendString(b, 2, env.RequestID)
+	b = appendVarint(b, 3, uint64(int32(env.Type)))
+	if env.Message != nil {
+		b = appendMessage(b, 4, encodeMessage(env.Message))
+	}
+	if env.Reply != nil {
+		b = appendMessage(b, 5, encodeReply(env.Reply))
+	}
+	if env.Ack != nil {
+		b = appendMessage(b, 6, encodeAck(env.Ack))
+	}
+	if env.Error != nil {
+		b = appendMessage(b, 7, encodeError(env.Error))
+	}
+	if env.Event != nil {
+		b = appendMessage(b, 8, encodeEvent(env.Event))
+	}
+	return b, nil
+}
+
+func decodeMessage(data []byte) (*Message, error) {
+	msg := &Message{}
+	err := walkFields(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			msg.Type = int(int32(v))
+			return n, nil
+		case num == 2 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			msg.TargetID = uint(v)
+			return n, nil
+		case num == 3 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			msg.Content = v
+			return n, nil
+		case num == 4 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			msg.MediaID = uint(v)
+			return n, nil
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	return msg, err
+}
+
+func encodeMessage(msg *Message) []byte {
+	var b []byte
+	b = appendVarint(b, 1, uint64(int32(msg.Type)))
+	b = appendVarint(b, 2, uint64(msg.TargetID))
+	b = appendString(b, 3, msg.Content)
+	b = appendVarint(b, 4, uint64(msg.MediaID))
+	return b
+}
+
+func decodeReply(data []byte) (*Reply, error) {
+	reply := &Reply{}
+	err := walkFields(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { reply.FromID = uint(v) }), nil
+		case num == 2 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { reply.Type = int(int32(v)) }), nil
+		case num == 3 && typ == protowire.BytesType:
+			return consumeString(b, func(v string) { reply.Content = v }), nil
+		case num == 4 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { reply.SendTime = int64(v) }), nil
+		case num == 5 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { reply.Media = int(int32(v)) }), nil
+		case num == 6 && typ == protowire.BytesType:
+			return consumeMessage(b, func(v []byte) (err error) {
+				reply.Attachment, err = decodeAttachment(v)
+				return err
+			})
+		case num == 7 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { reply.MsgID = uint(v) }), nil
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	return reply, err
+}
+
+func encodeReply(reply *Reply) []byte {
+	var b []byte
+	b = appendVarint(b, 1, uint64(reply.FromID))
+	b = appendVarint(b, 2, uint64(int32(reply.Type)))
+	b = appendString(b, 3, reply.Content)
+	b = appendVarint(b, 4, uint64(reply.SendTime))
+	b = appendVarint(b, 5, uint64(int32(reply.Media)))
+	if reply.Attachment != nil {
+		b = appendMessage(b, 6, encodeAttachment(reply.Attachment))
+	}
+	b = appendVarint(b, 7, uint64(reply.MsgID))
+	return b
+}
+
+func decodeAttachment(data []byte) (*Attachment, error) {
+	a := &Attachment{}
+	err := walkFields(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { a.ID = uint(v) }), nil
+		case num == 2 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { a.Kind = int(int32(v)) }), nil
+		case num == 3 && typ == protowire.BytesType:
+			return consumeString(b, func(v string) { a.URL = v }), nil
+		case num == 4 && typ == protowire.BytesType:
+			return consumeString(b, func(v string) { a.MimeType = v }), nil
+		case num == 5 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { a.Size = int64(v) }), nil
+		case num == 6 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { a.Width = int(int32(v)) }), nil
+		case num == 7 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { a.Height = int(int32(v)) }), nil
+		case num == 8 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { a.DurationMs = int64(v) }), nil
+		case num == 9 && typ == protowire.BytesType:
+			return consumeString(b, func(v string) { a.FileName = v }), nil
+		case num == 10 && typ == protowire.BytesType:
+			return consumeString(b, func(v string) { a.Blurhash = v }), nil
+		case num == 11 && typ == protowire.BytesType:
+			return consumeMessage(b, func(v []byte) error {
+				t, err := decodeThumbnail(v)
+				if err != nil {
+					return err
+				}
+				a.Thumbnails = append(a.Thumbnails, t)
+				return nil
+			})
+		case num == 12 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { a.ExpiresAt = int64(v) }), nil
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	return a, err
+}
+
+func encodeAttachment(a *Attachment) []byte {
+	var b []byte
+	b = appendVarint(b, 1, uint64(a.ID))
+	b = appendVarint(b, 2, uint64(int32(a.Kind)))
+	b = appendString(b, 3, a.URL)
+	b = appendString(b, 4, a.MimeType)
+	b = appendVarint(b, 5, uint64(a.Size))
+	b = appendVarint(b, 6, uint64(int32(a.Width)))
+	b = appendVarint(b, 7, uint64(int32(a.Height)))
+	b = appendVarint(b, 8, uint64(a.DurationMs))
+	b = appendString(b, 9, a.FileName)
+	b = appendString(b, 10, a.Blurhash)
+	for _, t := range a.Thumbnails {
+		b = appendMessage(b, 11, encodeThumbnail(t))
+	}
+	b = appendVarint(b, 12, uint64(a.ExpiresAt))
+	return b
+}
+
+func decodeThumbnail(data []byte) (Thumbnail, error) {
+	var t Thumbnail
+	err := walkFields(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, func(v string) { t.URL = v }), nil
+		case num == 2 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { t.Width = int(int32(v)) }), nil
+		case num == 3 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { t.Height = int(int32(v)) }), nil
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	return t, err
+}
+
+func encodeThumbnail(t Thumbnail) []byte {
+	var b []byte
+	b = appendString(b, 1, t.URL)
+	b = appendVarint(b, 2, uint64(int32(t.Width)))
+	b = appendVarint(b, 3, uint64(int32(t.Height)))
+	return b
+}
+
+func decodeAck(data []byte) (*Ack, error) {
+	ack := &Ack{}
+	err := walkFields(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { ack.MsgID = uint(v) }), nil
+		case num == 2 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { ack.SendTime = int64(v) }), nil
+		case num == 3 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { ack.Status = int(int32(v)) }), nil
+		case num == 4 && typ == protowire.BytesType:
+			return consumeString(b, func(v string) { ack.Content = v }), nil
+		case num == 5 && typ == protowire.BytesType:
+			return consumeString(b, func(v string) { ack.Reason = v }), nil
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	return ack, err
+}
+
+func encodeAck(ack *Ack) []byte {
+	var b []byte
+	b = appendVarint(b, 1, uint64(ack.MsgID))
+	b = appendVarint(b, 2, uint64(ack.SendTime))
+	b = appendVarint(b, 3, uint64(int32(ack.Status)))
+	b = appendString(b, 4, ack.Content)
+	b = appendString(b, 5, ack.Reason)
+	return b
+}
+
+func decodeError(data []byte) (*Error, error) {
+	e := &Error{}
+	err := walkFields(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			return consumeVarint(b, func(v uint64) { e.Code = int(int32(v)) }), nil
+		case num == 2 && typ == protowire.BytesType:
+			return consumeString(b, func(v string) { e.Message = v }), nil
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	return e, err
+}
+
+func encodeError(e *Error) []byte {
+	var b []byte
+	b = appendVarint(b, 1, uint64(int32(e.Code)))
+	b = appendString(b, 2, e.Message)
+	return b
+}
+
+func decodeEvent(data []byte) (*Event, error) {
+	e := &Event{}
+	err := walkFields(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			return consumeString(b, func(v string) { e.Name = v }), nil
+		case num == 2 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n >= 0 {
+				// 不能引用帧的缓冲区，调用方可能复用它
+				e.Data = append([]byte(nil), v...)
+			}
+			return n, nil
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	return e, err
+}
+
+func encodeEvent(e *Event) []byte {
+	var b []byte
+	b = appendString(b, 1, e.Name)
+	if len(e.Data) > 0 {
+		b = appendMessage(b, 2, e.Data)
+	}
+	return b
+}
+
+// walkFields 依次遍历 data 中的每个字段
+// fn 返回该字段值消费的字节数，负数表示解析失败
+func walkFields(data []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		n, err := fn(num, typ, data)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// consumeVarint 读取一个 varint 字段值，成功时交给 set，返回消费的字节数
+func consumeVarint(b []byte, set func(v uint64)) int {
+	v, n := protowire.ConsumeVarint(b)
+	if n >= 0 {
+		set(v)
+	}
+	return n
+}
+
+// consumeString 读取一个字符串字段值
+func consumeString(b []byte, set func(v string)) int {
+	v, n := protowire.ConsumeString(b)
+	if n >= 0 {
+		set(v)
+	}
+	return n
+}
+
+// consumeMessage 读取一个嵌套消息字段，交给 decode 解析
+func consumeMessage(b []byte, decode func(v []byte) error) (int, error) {
+	v, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return n, nil
+	}
+	return n, decode(v)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendMessage(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
@@ -1,24 +1,130 @@
 package protocol
 
+import "encoding/json"
+
+// Version 当前信封协议版本
+// 0 表示旧版客户端：上行消息字段平铺在顶层，下行直接收到 Reply
+const Version = 1
+
 // 消息类型
 const (
 	TypeHeartbeat = 0 // 心跳
 	TypeLogin     = 1 // 登录/上线通知
 	TypeSingleMsg = 2 // 单聊消息
 	TypeGroupMsg  = 3 // 群聊消息
+	TypeAck       = 4 // 服务端回执 (消息已入库)
+	TypeError     = 5 // 错误帧
+	TypeEvent     = 6 // 服务端事件通知
+)
+
+// 事件名称
+const (
+	EventFriendDeleted = "friend.deleted" // 被对方删除好友
+
+	EventFriendRequestNew       = "friend_request.new"       // 收到新的好友申请
+	EventFriendRequestAccepted  = "friend_request.accepted"  // 我发出的申请被同意
+	EventFriendRequestRejected  = "friend_request.rejected"  // 我发出的申请被拒绝
+	EventFriendRequestWithdrawn = "friend_request.withdrawn" // 收到的申请被对方撤回
+
+	EventMessagePreview  = "message.preview"  // 消息的链接预览已生成
+	EventMessageApproved = "message.approved" // 我发出的消息审核通过，已投递
+	EventMessageRemoved  = "message.removed"  // 我发出的消息审核未通过
+)
+
+// 回执中的消息状态
+const (
+	AckStatusDelivered = 0 // 已投递
+	AckStatusHeld      = 1 // 内容审核中，通过后才投递给对方
+)
+
+// 错误帧错误码
+const (
+	ErrCodeBadFrame    = 4000 // 帧无法解析 (JSON/Protobuf 格式错误)
+	ErrCodeBadVersion  = 4001 // 不支持的协议版本
+	ErrCodeUnknownType = 4002 // 未知的消息类型
+	ErrCodeNotFriend   = 4003 // 不是好友，无法发送
+	ErrCodeNoTarget    = 4005 // 目标不存在
+	ErrCodeBlocked     = 4006 // 被对方拉黑或已拉黑对方
+	ErrCodeRequestCap  = 4007 // 陌生人消息请求已达上限
+	ErrCodeBadMedia    = 4008 // 附件不存在、不属于发送者或类型/大小不符合要求
+	ErrCodeModerated   = 4009 // 内容违规，拒绝发送
+	ErrCodeRateLimited = 4029 // 发送过于频繁
+	ErrCodeInternal    = 5000 // 服务端内部错误
 )
 
+// Envelope 版本化的消息信封，上下行共用
+// 客户端上行时填写 id，服务端的 Ack / Error 会原样带回，用于请求与响应的对应
+// 按 Type 只会填充其中一个负载字段
+type Envelope struct {
+	Version   int      `json:"v"`               // 协议版本
+	RequestID string   `json:"id,omitempty"`    // 客户端请求ID
+	Type      int      `json:"type"`            // 消息类型
+	Message   *Message `json:"msg,omitempty"`   // 上行消息 (TypeSingleMsg / TypeGroupMsg)
+	Reply     *Reply   `json:"reply,omitempty"` // 下行推送
+	Ack       *Ack     `json:"ack,omitempty"`   // TypeAck
+	Error     *Error   `json:"error,omitempty"` // TypeError
+	Event     *Event   `json:"event,omitempty"` // TypeEvent
+}
+
 // Message 客户端发送给服务器的消息结构
 type Message struct {
-	Type     int    `json:"type"`      // 消息类型
-	TargetID uint   `json:"target_id"` // 接收者ID (如果是群聊则是Group ID)
-	Content  string `json:"content"`   // 消息内容
+	Type     int    `json:"type"`               // 消息类型
+	TargetID uint   `json:"target_id"`          // 接收者ID (如果是群聊则是Group ID)
+	Content  string `json:"content"`            // 消息内容 (媒体消息为说明文字，可为空)
+	MediaID  uint   `json:"media_id,omitempty"` // 附件ID (先通过 /api/media/upload 上传)
 }
 
 // Reply 服务器推送给客户端的消息结构
 type Reply struct {
-	FromID   uint   `json:"from_id"`   // 发送者ID
-	Type     int    `json:"type"`      // 消息类型
-	Content  string `json:"content"`   // 内容
-	SendTime int64  `json:"send_time"` // 发送时间戳
+	MsgID      uint        `json:"msg_id,omitempty"`     // 消息ID
+	FromID     uint        `json:"from_id"`              // 发送者ID
+	Type       int         `json:"type"`                 // 消息类型
+	Content    string      `json:"content"`              // 内容
+	SendTime   int64       `json:"send_time"`            // 发送时间戳
+	Media      int         `json:"media,omitempty"`      // 媒体类型: 1文本 2图片 3音频 4视频 5文件
+	Attachment *Attachment `json:"attachment,omitempty"` // 媒体消息的附件
+}
+
+// Attachment 媒体消息的附件信息
+type Attachment struct {
+	ID         uint        `json:"id"`
+	Kind       int         `json:"kind"`                  // 媒体类型
+	URL        string      `json:"url"`                   // 签名下载地址
+	ExpiresAt  int64       `json:"expires_at,omitempty"`  // 地址过期时间 (Unix 秒)
+	MimeType   string      `json:"mime_type"`             // 文件类型
+	Size       int64       `json:"size"`                  // 字节数
+	Width      int         `json:"width,omitempty"`       // 图片/视频宽度
+	Height     int         `json:"height,omitempty"`      // 图片/视频高度
+	DurationMs int64       `json:"duration_ms,omitempty"` // 音视频时长 (毫秒)
+	FileName   string      `json:"file_name,omitempty"`   // 原始文件名
+	Blurhash   string      `json:"blurhash,omitempty"`    // 图片的模糊占位图
+	Thumbnails []Thumbnail `json:"thumbnails,omitempty"`  // 图片缩略图，按尺寸从小到大
+}
+
+// Thumbnail 图片缩略图
+type Thumbnail struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Ack 消息发送成功的回执
+type Ack struct {
+	MsgID    uint   `json:"msg_id"`            // 入库后的消息ID
+	SendTime int64  `json:"send_time"`         // 服务端时间戳
+	Status   int    `json:"status,omitempty"`  // 见 AckStatus* 常量
+	Content  string `json:"content,omitempty"` // 内容被屏蔽处理时，实际保存的内容
+	Reason   string `json:"reason,omitempty"`  // 内容被屏蔽或转人工审核的原因
+}
+
+// Error 错误帧负载
+type Error struct {
+	Code    int    `json:"code"`    // 错误码，见 ErrCode*
+	Message string `json:"message"` // 可展示给用户的错误描述
+}
+
+// Event 服务端主动推送的事件通知
+type Event struct {
+	Name string          `json:"name"`           // 事件名称，见 Event* 常量
+	Data json.RawMessage `json:"data,omitempty"` // 事件数据 (JSON)
 }
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Config S3 及兼容服务 (MinIO 等) 的连接配置
+type S3Config struct {
+	Endpoint  string // 如 https://s3.us-east-1.amazonaws.com、http://127.0.0.1:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool   // 使用 endpoint/bucket/key 形式的地址 (MinIO 等通常需要)
+	PublicURL string // 对外访问地址前缀 (如 CDN)，为空时使用对象地址
+}
+
+// S3Storage 通过 S3 REST API 存取对象，请求使用 SigV4 签名
+type S3Storage struct {
+	config   S3Config
+	endpoint *url.URL
+	signer   SigV4
+	client   *http.Client
+}
+
+func NewS3Storage(config S3Config, client *http.Client) (*S3Storage, error) {
+	if config.Bucket == "" {
+		return nil, errors.New("s3: bucket is required")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	endpoint, err := url.Parse(strings.TrimRight(config.Endpoint, "/"))
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", config.Endpoint)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+
+	return &S3Storage{
+		config:   config,
+		endpoint: endpoint,
+		signer:   SigV4{AccessKey: config.AccessKey, SecretKey: config.SecretKey, Region: config.Region, Service: "s3"},
+		client:   client,
+	}, nil
+}
+
+// objectURL 对象的 REST 地址
+func (s *S3Storage) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	key = strings.TrimLeft(key, "/")
+	if s.config.PathStyle {
+		u.Path = u.Path + "/" + s.config.Bucket + "/" + key
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+		u.Path = u.Path + "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	return &u
+}
+
+func (s *S3Storage) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.signer.Sign(req, payloadHash, time.Now())
+	return s.client.Do(req)
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), r)
+	if err != nil {
+		return err
+	}
+	// 需要明确的长度，S3 不接受分块编码的 PUT
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req, UnsignedPayload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("put", resp)
+	}
+	return nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req, EmptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, s3Error("get", resp)
+	}
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req, EmptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return s3Error("delete", resp)
+	}
+}
+
+func (s *S3Storage) URL(key string) string {
+	if s.config.PublicURL != "" {
+		return strings.TrimRight(s.config.PublicURL, "/") + "/" + uriEncode(strings.TrimLeft(key, "/"), false)
+	}
+	return s.objectURL(key).String()
+}
+
+// PresignGet 生成预签名下载地址，使用对象地址而不是 PublicURL (CDN 无法校验 S3 签名)
+func (s *S3Storage) PresignGet(key string, expires time.Duration, params url.Values) (string, error) {
+	if expires <= 0 || expires > 7*24*time.Hour {
+		return "", fmt.Errorf("s3: presign expires must be between 1s and 7 days, got %s", expires)
+	}
+	u := s.objectURL(key)
+	u.RawQuery = params.Encode()
+	return s.signer.Presign(http.MethodGet, u, expires, time.Now()), nil
+}
+
+// s3Error 读取 S3 返回的错误信息 (XML)，只截取开头一段用于日志
+func s3Error(op string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("s3 %s: %s: %s", op, resp.Status, strings.TrimSpace(string(body)))
+}
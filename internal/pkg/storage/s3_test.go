@@ -0,0 +1,158 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go-chat/internal/pkg/storage"
+	"go-chat/internal/pkg/storage/s3test"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 需要转义的 key：空格、中文、保留字符和 %
+var s3TestKeys = []string{
+	"avatars/1/a.jpg",
+	"media/2/文件 名 (1).txt",
+	"media/3/a+b=c&d;e,f@g$h!.bin",
+	"media/4/100%~ok?#frag.txt",
+}
+
+func newS3TestStorage(t *testing.T) (*storage.S3Storage, *s3test.Server) {
+	t.Helper()
+	srv := s3test.NewServer("bucket", "access", "secret")
+	t.Cleanup(srv.Close)
+
+	s, err := storage.NewS3Storage(storage.S3Config{
+		Endpoint:  srv.Endpoint(),
+		Bucket:    "bucket",
+		AccessKey: "access",
+		SecretKey: "secret",
+		PathStyle: true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("new s3 storage: %v", err)
+	}
+	return s, srv
+}
+
+func TestS3PutGetDelete(t *testing.T) {
+	s, srv := newS3TestStorage(t)
+	ctx := context.Background()
+
+	for _, key := range s3TestKeys {
+		t.Run(key, func(t *testing.T) {
+			data := []byte("content of " + key)
+			if err := s.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "text/plain"); err != nil {
+				t.Fatalf("put: %v", err)
+			}
+			if got, contentType, ok := srv.Object(key); !ok || !bytes.Equal(got, data) || contentType != "text/plain" {
+				t.Fatalf("stored object: ok=%v data=%q content-type=%q", ok, got, contentType)
+			}
+
+			r, err := s.Get(ctx, key)
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			got, err := io.ReadAll(r)
+			r.Close()
+			if err != nil || !bytes.Equal(got, data) {
+				t.Fatalf("get: data=%q err=%v", got, err)
+			}
+
+			if err := s.Delete(ctx, key); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			if _, err := s.Get(ctx, key); !errors.Is(err, storage.ErrNotFound) {
+				t.Fatalf("get after delete: want ErrNotFound, got %v", err)
+			}
+			// 删除不存在的对象不报错
+			if err := s.Delete(ctx, key); err != nil {
+				t.Fatalf("delete again: %v", err)
+			}
+		})
+	}
+}
+
+func TestS3PutEmptyObject(t *testing.T) {
+	s, srv := newS3TestStorage(t)
+	if err := s.Put(context.Background(), "empty.txt", bytes.NewReader(nil), 0, ""); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if data, _, ok := srv.Object("empty.txt"); !ok || len(data) != 0 {
+		t.Fatalf("stored object: ok=%v len=%d", ok, len(data))
+	}
+}
+
+func TestS3WrongCredentials(t *testing.T) {
+	srv := s3test.NewServer("bucket", "access", "secret")
+	defer srv.Close()
+	s, err := storage.NewS3Storage(storage.S3Config{
+		Endpoint: srv.Endpoint(), Bucket: "bucket", AccessKey: "access", SecretKey: "wrong", PathStyle: true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(context.Background(), "a.txt", strings.NewReader("x"), 1, ""); err == nil {
+		t.Fatal("put with wrong secret should fail")
+	}
+}
+
+func TestS3PresignGet(t *testing.T) {
+	s, _ := newS3TestStorage(t)
+	ctx := context.Background()
+
+	for _, key := range s3TestKeys {
+		t.Run(key, func(t *testing.T) {
+			data := []byte("presigned " + key)
+			if err := s.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "application/octet-stream"); err != nil {
+				t.Fatalf("put: %v", err)
+			}
+
+			params := url.Values{"response-content-disposition": {`attachment; filename="a b.txt"`}}
+			signed, err := s.PresignGet(key, time.Minute, params)
+			if err != nil {
+				t.Fatalf("presign: %v", err)
+			}
+			resp, err := http.Get(signed)
+			if err != nil {
+				t.Fatalf("get presigned: %v", err)
+			}
+			got, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || !bytes.Equal(got, data) {
+				t.Fatalf("get presigned: status=%d body=%q", resp.StatusCode, got)
+			}
+			if cd := resp.Header.Get("Content-Disposition"); cd != params.Get("response-content-disposition") {
+				t.Fatalf("Content-Disposition = %q", cd)
+			}
+
+			// 改动签名覆盖的任何参数都应被拒绝
+			tampered, _ := url.Parse(signed)
+			q := tampered.Query()
+			q.Set("response-content-disposition", "inline")
+			tampered.RawQuery = q.Encode()
+			resp, err = http.Get(tampered.String())
+			if err != nil {
+				t.Fatalf("get tampered: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusForbidden {
+				t.Fatalf("tampered url: want 403, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestS3PresignExpiresRange(t *testing.T) {
+	s, _ := newS3TestStorage(t)
+	for _, expires := range []time.Duration{0, 8 * 24 * time.Hour} {
+		if _, err := s.PresignGet("a.txt", expires, nil); err == nil {
+			t.Errorf("presign with expires %s should fail", expires)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Package s3test 提供一个内存中的 S3 兼容服务 (仅 path-style 的 PUT/GET/DELETE，支持预签名 GET)，用于测试和本地联调
+package s3test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-chat/internal/pkg/storage"
+)
+
+type object struct {
+	data        []byte
+	contentType string
+}
+
+// Server 校验 SigV4 签名后在内存中存取对象
+type Server struct {
+	Server    *httptest.Server // 由 NewServer 启动时非空
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Region    string
+
+	mu      sync.Mutex
+	objects map[string]object
+}
+
+// New 创建服务，需自行用 http.Server 挂载
+func New(bucket, accessKey, secretKey string) *Server {
+	return &Server{
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Region:    "us-east-1",
+		objects:   map[string]object{},
+	}
+}
+
+// NewServer 在随机端口上启动服务，用完需调用 Close
+func NewServer(bucket, accessKey, secretKey string) *Server {
+	s := New(bucket, accessKey, secretKey)
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Endpoint 填入 storage.s3.endpoint (需配合 path_style: true)
+func (s *Server) Endpoint() string {
+	return s.Server.URL
+}
+
+func (s *Server) Close() {
+	if s.Server != nil {
+		s.Server.Close()
+	}
+}
+
+// Object 读取已存储的对象，供测试断言
+func (s *Server) Object(key string) ([]byte, string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[key]
+	return obj.data, obj.contentType, ok
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.verify(r) {
+		writeError(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != s.Bucket {
+		writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "InvalidRequest")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(data)) != r.ContentLength {
+			writeError(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		s.mu.Lock()
+		s.objects[key] = object{data: data, contentType: r.Header.Get("Content-Type")}
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		data, contentType, ok := s.Object(key)
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		// 预签名地址可以用 response-* 参数覆盖响应头
+		for param, header := range map[string]string{
+			"response-content-type":        "Content-Type",
+			"response-content-disposition": "Content-Disposition",
+			"response-cache-control":       "Cache-Control",
+		} {
+			if v := r.URL.Query().Get(param); v != "" {
+				w.Header().Set(header, v)
+			}
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		s.mu.Lock()
+		delete(s.objects, key)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// verify 用同样的时间和参数重新签名，比较 Authorization 是否一致
+func (s *Server) verify(r *http.Request) bool {
+	if r.URL.Query().Has("X-Amz-Signature") {
+		return s.verifyPresigned(r)
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.Contains(auth, "Credential="+s.AccessKey+"/") {
+		return false
+	}
+	t, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil || time.Since(t) > 15*time.Minute || time.Until(t) > 15*time.Minute {
+		return false
+	}
+
+	clone := r.Clone(r.Context())
+	clone.URL.Host = r.Host
+	signer := storage.SigV4{AccessKey: s.AccessKey, SecretKey: s.SecretKey, Region: s.Region, Service: "s3"}
+	signer.Sign(clone, r.Header.Get("X-Amz-Content-Sha256"), t)
+	return clone.Header.Get("Authorization") == auth
+}
+
+// verifyPresigned 校验预签名地址：未过期，且按查询参数中的时间和有效期重新签名后一致
+func (s *Server) verifyPresigned(r *http.Request) bool {
+	q := r.URL.Query()
+	if !strings.HasPrefix(q.Get("X-Amz-Credential"), s.AccessKey+"/") || r.Method != http.MethodGet {
+		return false
+	}
+	t, err := time.Parse("20060102T150405Z", q.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+	seconds, err := strconv.Atoi(q.Get("X-Amz-Expires"))
+	if err != nil || time.Now().After(t.Add(time.Duration(seconds)*time.Second)) {
+		return false
+	}
+
+	u := *r.URL
+	u.Host = r.Host
+	signer := storage.SigV4{AccessKey: s.AccessKey, SecretKey: s.SecretKey, Region: s.Region, Service: "s3"}
+	signed, err := url.Parse(signer.Presign(r.Method, &u, time.Duration(seconds)*time.Second, t))
+	return err == nil && signed.Query().Get("X-Amz-Signature") == q.Get("X-Amz-Signature")
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	io.WriteString(w, "<Error><Code>"+code+"</Code></Error>")
+}
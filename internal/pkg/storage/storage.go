@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Storage 文件存储后端
+// key 为形如 avatars/1/xxx.jpg 的相对路径，由调用方生成，保证唯一
+type Storage interface {
+	// Put 写入对象，已存在则覆盖
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 读取对象，不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// URL 返回对象的访问地址
+	URL(key string) string
+}
+
+// Presigner 能生成临时下载地址的存储后端 (S3)，客户端可直接从存储下载而不经过本服务中转
+type Presigner interface {
+	// PresignGet 返回有效期为 expires 的下载地址，params 可带 response-content-disposition 等覆盖响应头的参数
+	PresignGet(key string, expires time.Duration, params url.Values) (string, error)
+}
@@ -0,0 +1,44 @@
+package unfurl
+
+import (
+	"net"
+	"net/netip"
+)
+
+// 除 netip 自带判断 (本机、私有、链路本地、组播、未指定) 外需要拒绝的地址段
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // 本网络
+	netip.MustParsePrefix("100.64.0.0/10"),   // 运营商级 NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF 协议分配
+	netip.MustParsePrefix("192.0.2.0/24"),    // 文档示例
+	netip.MustParsePrefix("198.18.0.0/15"),   // 基准测试
+	netip.MustParsePrefix("198.51.100.0/24"), // 文档示例
+	netip.MustParsePrefix("203.0.113.0/24"),  // 文档示例
+	netip.MustParsePrefix("240.0.0.0/4"),     // 保留 (含广播地址)
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64，可能映射到内网 IPv4
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // 本地 NAT64
+	netip.MustParsePrefix("100::/64"),        // 丢弃
+	netip.MustParsePrefix("2001:db8::/32"),   // 文档示例
+	netip.MustParsePrefix("2002::/16"),       // 6to4，内嵌 IPv4
+	netip.MustParsePrefix("fec0::/10"),       // 站点本地 (已废弃)
+}
+
+// IsPublicIP 是否为可以从公网访问的单播地址
+func IsPublicIP(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap() // ::ffff:10.0.0.1 按 IPv4 判断
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package unfurl
+
+import (
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	maxTitleLen       = 200
+	maxDescriptionLen = 300
+	maxImageURLLen    = 2048
+)
+
+// parseHTML 只解析 <head> 中的 <title> 和 <meta>，遇到 <body> 即停止
+func parseHTML(r io.Reader, base *url.URL) *Preview {
+	meta := map[string]string{}
+	var title strings.Builder
+	inTitle, titleDone := false, false
+
+	z := html.NewTokenizer(r)
+loop:
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			break loop // 读完或达到大小上限
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Body:
+				break loop
+			case atom.Title:
+				inTitle = !titleDone
+			case atom.Meta:
+				if !hasAttr {
+					continue
+				}
+				var key, content string
+				for {
+					k, v, more := z.TagAttr()
+					switch string(k) {
+					case "property", "name":
+						if key == "" {
+							key = strings.ToLower(strings.TrimSpace(string(v)))
+						}
+					case "content":
+						content = string(v)
+					}
+					if !more {
+						break
+					}
+				}
+				// 同名的 meta 以第一个为准
+				if _, ok := meta[key]; key != "" && !ok {
+					meta[key] = content
+				}
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				inTitle, titleDone = false, true
+			case atom.Head:
+				break loop
+			}
+		case html.TextToken:
+			if inTitle {
+				title.Write(z.Text())
+			}
+		}
+	}
+
+	p := &Preview{
+		Title:       clean(first(meta, "og:title", "twitter:title"), maxTitleLen),
+		Description: clean(first(meta, "og:description", "twitter:description", "description"), maxDescriptionLen),
+		SiteName:    clean(first(meta, "og:site_name", "application-name"), maxTitleLen),
+		Image:       resolveImage(base, first(meta, "og:image", "og:image:url", "og:image:secure_url", "twitter:image", "twitter:image:src")),
+	}
+	if p.Title == "" {
+		p.Title = clean(title.String(), maxTitleLen)
+	}
+	if p.SiteName == "" && base != nil {
+		p.SiteName = base.Hostname()
+	}
+	return p
+}
+
+func first(meta map[string]string, keys ...string) string {
+	for _, k := range keys {
+		if v := strings.TrimSpace(meta[k]); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+var spaces = regexp.MustCompile(`\s+`)
+
+// clean 合并空白并按字符数截断
+func clean(s string, max int) string {
+	s = strings.TrimSpace(spaces.ReplaceAllString(strings.ToValidUTF8(s, ""), " "))
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max]) + "…"
+}
+
+// resolveImage 相对地址按页面地址解析，只接受 http/https
+func resolveImage(base *url.URL, ref string) string {
+	if ref == "" || base == nil {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User != nil {
+		return ""
+	}
+	s := u.String()
+	if len(s) > maxImageURLLen {
+		return ""
+	}
+	return s
+}
+
+// 只匹配 ASCII 字符，紧跟在链接后面的中文不会被当作链接的一部分
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[a-z0-9\-._~:/?#\[\]@!$&'()*+,;=%]+`)
+
+// ExtractURL 返回文本中的第一个 http/https 链接，没有则返回空串
+// 去掉结尾的标点和不成对的右括号 (如 "(见 https://example.com/a)")
+func ExtractURL(text string) string {
+	for _, m := range urlPattern.FindAllString(text, -1) {
+		for len(m) > 0 {
+			last := m[len(m)-1]
+			if strings.IndexByte(".,;:!?'\"", last) >= 0 ||
+				(last == ')' && strings.Count(m, "(") < strings.Count(m, ")")) ||
+				(last == ']' && strings.Count(m, "[") < strings.Count(m, "]")) {
+				m = m[:len(m)-1]
+				continue
+			}
+			break
+		}
+		u, err := url.Parse(m)
+		if err == nil && checkURL(u) == nil {
+			return m
+		}
+	}
+	return ""
+}
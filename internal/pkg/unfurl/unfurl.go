@@ -0,0 +1,158 @@
+// Package unfurl 抓取网页的 Open Graph 等元数据，生成链接预览
+// 抓取的地址来自用户消息，必须防范 SSRF：只允许 http/https，不走代理，
+// 建立连接时校验实际连接的 IP 不是内网/本机等地址 (重定向和 DNS 重绑定同样受限)
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	ErrInvalidURL     = errors.New("unfurl: invalid url")
+	ErrBlockedAddress = errors.New("unfurl: destination address is not allowed")
+	ErrNotHTML        = errors.New("unfurl: response is not html")
+	ErrNoMetadata     = errors.New("unfurl: no preview metadata")
+)
+
+// Preview 链接预览
+type Preview struct {
+	URL         string `json:"url"` // 消息中的原始链接
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"` // 已转换为绝对地址
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// Options 抓取限制，零值字段使用默认值
+type Options struct {
+	Timeout      time.Duration // 整个抓取过程 (含重定向) 的超时，默认 5 秒
+	MaxBytes     int64         // 最多读取的响应体字节数 (解压后)，默认 512KB
+	MaxRedirects int           // 最多跟随的重定向次数，默认 3
+	UserAgent    string
+	AllowPrivate bool // 允许访问内网和本机地址，仅用于测试和本地联调
+}
+
+// Fetcher 链接预览抓取器，可并发使用
+type Fetcher struct {
+	opts   Options
+	client *http.Client
+}
+
+func NewFetcher(opts Options) *Fetcher {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 512 << 10
+	}
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = 3
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = "go-chat-linkpreview/1.0"
+	}
+
+	dialer := &net.Dialer{Timeout: 3 * time.Second}
+	if !opts.AllowPrivate {
+		dialer.Control = guardConnect
+	}
+	transport := &http.Transport{
+		Proxy:                 nil, // 不走代理，否则校验的是代理地址而不是目标地址
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   3 * time.Second,
+		ResponseHeaderTimeout: opts.Timeout,
+		MaxIdleConns:          16,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	f := &Fetcher{opts: opts}
+	f.client = &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return fmt.Errorf("unfurl: stopped after %d redirects", opts.MaxRedirects)
+			}
+			return checkURL(req.URL)
+		},
+	}
+	return f
+}
+
+// Fetch 抓取网页并解析预览信息，没有标题的页面返回 ErrNoMetadata
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	if err := checkURL(u); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	req.Header.Set("User-Agent", f.opts.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unfurl: unexpected status %s", resp.Status)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mt, _, _ := mime.ParseMediaType(contentType); mt != "text/html" && mt != "application/xhtml+xml" {
+		return nil, ErrNotHTML
+	}
+
+	// 按 Content-Type 或页面中的 meta 声明转换为 UTF-8 (GBK 等中文网页)
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.opts.MaxBytes), contentType)
+	if err != nil {
+		return nil, err
+	}
+	preview := parseHTML(body, resp.Request.URL)
+	if preview.Title == "" {
+		return nil, ErrNoMetadata
+	}
+	preview.URL = rawURL
+	return preview, nil
+}
+
+// checkURL 只允许 http/https，且不能带用户名密码
+func checkURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// guardConnect 在建立连接前检查解析出的 IP，拒绝内网/本机等地址
+// 在连接时而不是解析域名时检查，避免 DNS 重绑定绕过
+func guardConnect(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return ErrBlockedAddress
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicIP(ip) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
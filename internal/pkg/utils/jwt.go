@@ -1,49 +1,137 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"go-chat/global"
+	"go-chat/internal/pkg/jwtkeys"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/spf13/viper"
 )
 
-var jwtSecret = []byte(viper.GetString("jwt.secret"))
+// TokenIssuer Token 的签发人 (iss)，其他服务验签时应同时校验
+const TokenIssuer = "go-chat"
+
+var jwtKeysMu sync.Mutex
 
 // MyClaims 自定义声明结构体
 type MyClaims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
+	UserID    uint   `json:"user_id"`
+	Username  string `json:"username"`
+	SessionID string `json:"sid,omitempty"` // 登录会话ID，会话被吊销后该会话签发的 Token 全部失效
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成 Token
-func GenerateToken(userID uint, username string) (string, error) {
-	// 获取过期时间配置，如果没有则默认 24 小时
-	expireHours := viper.GetInt("jwt.expire")
-	if expireHours == 0 {
-		expireHours = 24
+// AccessTokenTTL Access Token 有效期，默认 15 分钟，过期后用 Refresh Token 换取新的
+func AccessTokenTTL() time.Duration {
+	minutes := viper.GetInt("jwt.access_expire_minutes")
+	if minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// JWTKeyOptions 从配置读取签名密钥参数
+func JWTKeyOptions() jwtkeys.Options {
+	alg := viper.GetString("jwt.algorithm")
+	if alg == "" {
+		alg = jwtkeys.HS256
+	}
+	dir := viper.GetString("jwt.key_dir")
+	if dir == "" {
+		dir = "./data/jwt-keys"
+	}
+	// 被替换的密钥至少要保留到它签发的最后一个 Token 过期
+	retain := time.Duration(viper.GetInt("jwt.key_retain_hours")) * time.Hour
+	if retain < AccessTokenTTL() {
+		retain = AccessTokenTTL()
+	}
+	return jwtkeys.Options{
+		Algorithm:      alg,
+		Secret:         viper.GetString("jwt.secret"),
+		Dir:            dir,
+		RotateInterval: time.Duration(viper.GetInt("jwt.rotate_days")) * 24 * time.Hour,
+		Retain:         retain,
+	}
+}
+
+// JWTKeys 签名密钥，第一次使用时才按配置加载 (配置在 main 中初始化，不能在包初始化时读取)
+func JWTKeys() (*jwtkeys.KeySet, error) {
+	jwtKeysMu.Lock()
+	defer jwtKeysMu.Unlock()
+	if global.JWTKeys != nil {
+		return global.JWTKeys, nil
 	}
 
+	keys, err := jwtkeys.New(JWTKeyOptions())
+	if err != nil {
+		return nil, err
+	}
+	global.JWTKeys = keys
+	return keys, nil
+}
+
+// RandomToken 生成 n 字节的随机串 (hex 编码)，用于 jti、Refresh Token 等
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GenerateToken 生成 Access Token，每个 Token 带唯一的 jti 以便单独吊销
+func GenerateToken(userID uint, username, sessionID string) (string, error) {
+	keys, err := JWTKeys()
+	if err != nil {
+		return "", err
+	}
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := MyClaims{
 		userID,
 		username,
+		sessionID,
 		jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(expireHours))), // 过期时间
-			IssuedAt:  jwt.NewNumericDate(time.Now()),                                             // 签发时间
-			Issuer:    "go-chat",                                                                  // 签发人
+			ID:        jti,                                           // Token ID
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL())), // 过期时间
+			IssuedAt:  jwt.NewNumericDate(now),                       // 签发时间
+			Issuer:    TokenIssuer,                                   // 签发人
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	key := keys.Current()
+	token := jwt.NewWithClaims(key.SigningMethod(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.SignKey())
 }
 
-// ParseToken 解析 Token
+// ParseToken 解析 Token，按头部的 kid 选择验签密钥，签名算法必须与该密钥一致
 func ParseToken(tokenString string) (*MyClaims, error) {
+	keys, err := JWTKeys()
+	if err != nil {
+		return nil, err
+	}
+
 	token, err := jwt.ParseWithClaims(tokenString, &MyClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, err := keys.Lookup(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.VerifyKey(), nil
+	}, jwt.WithIssuer(TokenIssuer), jwt.WithExpirationRequired())
 
 	if err != nil {
 		return nil, err
@@ -2,20 +2,30 @@ package routers
 
 import (
 	_ "go-chat/docs"
+	"go-chat/global"
 	"go-chat/internal/api"
 	"go-chat/internal/middleware"
+	"go-chat/internal/pkg/storage"
 	"go-chat/internal/service"
+	"path/filepath"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.uber.org/zap"
 )
 
 func InitRouter() *gin.Engine {
 	r := gin.Default()
 
+	// 只采信来自受信任代理的 X-Forwarded-For / X-Real-IP，未配置时 ClientIP 即连接的对端地址
+	// gin 默认信任所有来源，客户端可以伪造这些头绕过按 IP 的限流和登录锁定
+	if err := r.SetTrustedProxies(global.Config.GetStringSlice("server.trusted_proxies")); err != nil {
+		global.Log.Fatal("invalid server.trusted_proxies", zap.Error(err))
+	}
+
 	//注册CORS中间件
 	config := cors.Config{
 		// 允许所有来源 (开发环境用，生产环境建议指定具体域名)
@@ -33,6 +43,7 @@ func InitRouter() *gin.Engine {
 			"Accept",
 			"token",
 			"X-Requested-With",
+			"X-Chunk-SHA256",
 		},
 
 		// 暴露给前端的 Header
@@ -50,11 +61,21 @@ func InitRouter() *gin.Engine {
 	//Swagger
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// JWT 公钥，供其他服务验签
+	r.GET("/.well-known/jwks.json", api.JWKS)
+
+	// 本地存储的头像由服务自身提供访问；聊天附件不公开，只能通过 /api/media 鉴权或签名地址访问
+	if local, ok := global.Storage.(*storage.LocalStorage); ok {
+		r.Static(local.URLPrefix+"/avatars", filepath.Join(local.Root, "avatars"))
+	}
+
 	//WebSocket Manager
 	go service.Manager.Start()
 
 	userApi := api.UserApi{}
 	chatApi := api.ChatApi{}
+	mediaApi := api.MediaApi{}
+	adminApi := api.AdminApi{}
 
 	apiGroup := r.Group("/api")
 	{
@@ -63,30 +84,88 @@ func InitRouter() *gin.Engine {
 		{
 			userGroup.POST("/register", userApi.Register)
 			userGroup.POST("/login", userApi.Login)
+			userGroup.POST("/login/2fa", userApi.LoginTwoFactor)       // 两步验证登录
+			userGroup.POST("/refresh", userApi.RefreshToken)           // 刷新 Token
+			userGroup.POST("/email/verify", userApi.VerifyEmail)       // 验证邮箱
+			userGroup.POST("/password/forgot", userApi.ForgotPassword) // 找回密码
+			userGroup.POST("/password/reset", userApi.ResetPassword)   // 重置密码
+			userGroup.GET("/oidc/login", userApi.OIDCLogin)            // 单点登录
+			userGroup.GET("/oidc/callback", userApi.OIDCCallback)      // 单点登录回调
 		}
 
+		// 签名地址下载附件，签名本身就是访问凭证
+		apiGroup.GET("/media/:id/download", mediaApi.Download)
+
 		//protected route (login reqired)
 		protectGroup := apiGroup.Group("")
 		protectGroup.Use(middleware.JWTAuth()) //need verification
 		{
 			//user service
 			protectGroup.GET("/user/info", userApi.GetUserInfo)
-			protectGroup.GET("/user/profile", userApi.GetFullUserInfo) // 获取完整用户信息
+			protectGroup.GET("/user/profile", userApi.GetFullUserInfo)                     // 获取完整用户信息
+			protectGroup.PUT("/user/profile", userApi.UpdateProfile)                       // 修改个人资料
+			protectGroup.POST("/user/password", userApi.ChangePassword)                    // 修改密码
+			protectGroup.POST("/user/avatar", userApi.UploadAvatar)                        // 上传头像
+			protectGroup.POST("/user/email/verify/send", userApi.SendEmailVerification)    // 发送邮箱验证邮件
+			protectGroup.POST("/user/2fa/setup", userApi.SetupTwoFactor)                   // 获取两步验证密钥
+			protectGroup.POST("/user/2fa/enable", userApi.EnableTwoFactor)                 // 开启两步验证
+			protectGroup.POST("/user/2fa/disable", userApi.DisableTwoFactor)               // 关闭两步验证
+			protectGroup.POST("/user/logout", userApi.Logout)                              // 退出登录
+			protectGroup.GET("/user/sessions", userApi.GetSessions)                        // 登录设备列表
+			protectGroup.DELETE("/user/sessions/:id", userApi.RevokeSession)               // 下线指定设备
+			protectGroup.POST("/user/sessions/revoke-others", userApi.RevokeOtherSessions) // 下线其他所有设备
 
 			//WebSocket route
 			protectGroup.GET("/ws", chatApi.Connect)
 			protectGroup.GET("/chat/history", chatApi.GetHistory)
+			protectGroup.GET("/chat/requests", chatApi.GetMessageRequests)                    // 陌生人消息请求
+			protectGroup.GET("/chat/search", chatApi.SearchMessages)                          // 搜索聊天记录
+			protectGroup.POST("/media/upload", mediaApi.Upload)                               // 上传聊天附件
+			protectGroup.GET("/media/:id", mediaApi.Get)                                      // 下载附件
+			protectGroup.GET("/media/:id/url", mediaApi.GetURL)                               // 获取附件签名地址
+			protectGroup.POST("/media/uploads", mediaApi.InitUpload)                          // 创建分片上传任务
+			protectGroup.GET("/media/uploads/:upload_id", mediaApi.GetUpload)                 // 查询上传进度
+			protectGroup.PUT("/media/uploads/:upload_id/chunks/:index", mediaApi.UploadChunk) // 上传分片
+			protectGroup.POST("/media/uploads/:upload_id/complete", mediaApi.CompleteUpload)  // 合并分片
+			protectGroup.DELETE("/media/uploads/:upload_id", mediaApi.AbortUpload)            // 取消上传
 
 			// 搜索用户 (返回包含ID的DTO)
 			protectGroup.GET("/user/search", api.SearchUser)
+			protectGroup.GET("/user/search/fuzzy", api.SearchUsers) // 模糊搜索 (分页)
+			protectGroup.GET("/user/privacy", userApi.GetPrivacySettings)
+			protectGroup.PUT("/user/privacy", userApi.UpdatePrivacySettings)
 
 			// 好友相关
-			protectGroup.POST("/friend/request", api.SendFriendRequest)    // 发送申请
-			protectGroup.POST("/friend/handle", api.HandleFriendRequest)   // 同意/拒绝
-			protectGroup.GET("/friend/requests", api.GetPendingRequests)   // 查看列表
-			protectGroup.GET("/friend/list", api.GetFriendList)            // 查看好友列表
-			protectGroup.POST("/friend/mark-read", api.MarkMessagesRead)   // 标记消息已读
-
+			protectGroup.POST("/friend/request", api.SendFriendRequest)      // 发送申请
+			protectGroup.POST("/friend/handle", api.HandleFriendRequest)     // 同意/拒绝
+			protectGroup.GET("/friend/requests", api.GetPendingRequests)     // 查看列表
+			protectGroup.GET("/friend/requests/sent", api.GetSentRequests)   // 我发出的申请
+			protectGroup.POST("/friend/withdraw", api.WithdrawFriendRequest) // 撤回申请
+			protectGroup.GET("/friend/list", api.GetFriendList)              // 查看好友列表
+			protectGroup.POST("/friend/mark-read", api.MarkMessagesRead)     // 标记消息已读
+			protectGroup.POST("/friend/block", api.BlockUser)                // 拉黑
+			protectGroup.POST("/friend/unblock", api.UnblockUser)            // 解除拉黑
+			protectGroup.GET("/friend/blocked", api.GetBlockedList)          // 黑名单
+			protectGroup.POST("/friend/delete", api.DeleteFriend)            // 删除好友
+			protectGroup.POST("/friend/remark", api.UpdateFriendRemark)      // 修改备注
+			protectGroup.GET("/friend/tags", api.GetFriendTags)              // 好友分组列表
+			protectGroup.POST("/friend/tags", api.CreateFriendTag)           // 新建分组
+			protectGroup.PUT("/friend/tags/:id", api.RenameFriendTag)        // 重命名分组
+			protectGroup.DELETE("/friend/tags/:id", api.DeleteFriendTag)     // 删除分组
+			protectGroup.POST("/friend/set-tag", api.SetFriendTag)           // 设置好友分组
+			protectGroup.GET("/friend/recommend", api.RecommendFriends)      // 可能认识的人
+
+			// 群聊
+			protectGroup.POST("/group/invite", api.InviteGroupMember) // 邀请入群
+
+			// 管理后台 (admin.user_ids)
+			adminGroup := protectGroup.Group("/admin")
+			adminGroup.Use(middleware.AdminAuth())
+			{
+				adminGroup.GET("/moderation/hits", adminApi.ListModerationHits)                // 内容审核记录
+				adminGroup.POST("/moderation/hits/:id/approve", adminApi.ApproveModerationHit) // 审核通过
+				adminGroup.POST("/moderation/hits/:id/reject", adminApi.RejectModerationHit)   // 审核不通过
+			}
 		}
 
 	}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"go-chat/internal/pkg/utils"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidRefreshToken = errors.New("登录已失效，请重新登录")
+
+// refreshSession Refresh Token 在 Redis 中对应的内容
+type refreshSession struct {
+	UserID    uint   `json:"user_id"`
+	Username  string `json:"username"`
+	SessionID string `json:"session_id"`
+}
+
+// refreshTokenTTL Refresh Token 有效期，默认 30 天
+func refreshTokenTTL() time.Duration {
+	days := global.Config.GetInt("jwt.refresh_expire_days")
+	if days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// hashToken Redis 中只保存 Refresh Token 的摘要，避免数据泄露后被直接使用
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueTokens 签发一对新的 Access Token / Refresh Token
+func issueTokens(ctx context.Context, user models.User, sessionID string) (*LoginResponseDTO, error) {
+	token, err := utils.GenerateToken(user.ID, user.Username, sessionID)
+	if err != nil {
+		return nil, ErrGenerateToken
+	}
+
+	refreshToken, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, ErrGenerateToken
+	}
+	data, _ := json.Marshal(refreshSession{UserID: user.ID, Username: user.Username, SessionID: sessionID})
+	if err := global.RDB.Set(ctx, refreshTokenKey(hashToken(refreshToken)), data, refreshTokenTTL()).Err(); err != nil {
+		return nil, err
+	}
+
+	return &LoginResponseDTO{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(utils.AccessTokenTTL().Seconds()),
+		Username:     user.Username,
+		Nickname:     user.Nickname,
+	}, nil
+}
+
+// RefreshToken 用 Refresh Token 换取新的 Token 对
+// Refresh Token 只能使用一次，换取后旧的立即作废 (轮换)
+func RefreshToken(ctx context.Context, refreshToken string) (*LoginResponseDTO, error) {
+	data, err := global.RDB.GetDel(ctx, refreshTokenKey(hashToken(refreshToken))).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session refreshSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// 所属设备已被下线
+	if session.SessionID != "" && !isSessionActive(ctx, session.UserID, session.SessionID) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// 重新读取用户，昵称等信息可能已经变更
+	var user models.User
+	if err := global.DB.WithContext(ctx).First(&user, session.UserID).Error; err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if user.Status == 2 {
+		return nil, ErrUserDisabled
+	}
+
+	TouchSession(ctx, session.SessionID)
+	return issueTokens(ctx, user, session.SessionID)
+}
+
+// Logout 退出登录：吊销当前 Access Token 并结束所在会话，删除对应的 Refresh Token
+func Logout(ctx context.Context, userID uint, tokenID, sessionID string, expiresAt time.Time, refreshToken string) error {
+	if err := RevokeToken(ctx, tokenID, expiresAt); err != nil {
+		return err
+	}
+	if sessionID != "" {
+		if err := RevokeSession(ctx, userID, sessionID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
+	}
+	if refreshToken == "" {
+		return nil
+	}
+
+	// 只能删除属于自己的 Refresh Token
+	key := refreshTokenKey(hashToken(refreshToken))
+	data, err := global.RDB.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var session refreshSession
+	if err := json.Unmarshal(data, &session); err == nil && session.UserID == userID {
+		return global.RDB.Del(ctx, key).Err()
+	}
+	return nil
+}
+
+// RevokeToken 把 Access Token 加入吊销列表，保留到它自然过期为止，并断开用它建立的 WebSocket
+func RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	// 旧版 Token 没有 jti，无法单独吊销
+	if tokenID == "" {
+		return nil
+	}
+
+	if ttl := time.Until(expiresAt); ttl > 0 {
+		if err := global.RDB.Set(ctx, revokedTokenKey(tokenID), "1", ttl).Err(); err != nil {
+			return err
+		}
+	}
+
+	Manager.KickToken(tokenID)
+	return nil
+}
+
+// IsTokenRevoked 检查 Access Token 本身或其所属会话是否已被吊销
+// Redis 不可用时放行，只记录日志
+func IsTokenRevoked(ctx context.Context, tokenID, sessionID string) bool {
+	var keys []string
+	if tokenID != "" {
+		keys = append(keys, revokedTokenKey(tokenID))
+	}
+	if sessionID != "" {
+		keys = append(keys, revokedSessionKey(sessionID))
+	}
+	if len(keys) == 0 {
+		return false
+	}
+
+	n, err := global.RDB.Exists(ctx, keys...).Result()
+	if err != nil {
+		global.Log.Warn("check revoked token failed", zap.Error(err))
+		return false
+	}
+	return n > 0
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBlockYourself = errors.New("不能拉黑自己")
+	ErrNotBlocked    = errors.New("对方不在黑名单中")
+	ErrBlocked       = errors.New("你们之间存在拉黑关系，无法操作")
+)
+
+// BlockUser 拉黑用户
+// 把 我->对方 的关系记录改为 Type=2 (没有则新建)，对方那条记录保持不变，
+// 解除拉黑时据此判断是否恢复好友关系
+func BlockUser(ctx context.Context, userID uint, req BlockUserReq) error {
+	if userID == req.TargetID {
+		return ErrBlockYourself
+	}
+
+	var target models.User
+	if err := global.DB.WithContext(ctx).Select("id").First(&target, req.TargetID).Error; err != nil {
+		return ErrTargetNotFound
+	}
+
+	defer invalidateRecommend(ctx, userID, req.TargetID)
+
+	return global.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rel models.Relation
+		err := tx.Where("owner_id = ? AND target_id = ?", userID, req.TargetID).First(&rel).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			rel = models.Relation{OwnerID: userID, TargetID: req.TargetID, Type: 2}
+			if err := tx.Create(&rel).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			if err := tx.Model(&rel).Update("type", 2).Error; err != nil {
+				return err
+			}
+		}
+
+		// 清理双方之间还未处理的好友申请
+		return tx.Where("status = 0 AND ((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?))",
+			userID, req.TargetID, req.TargetID, userID).
+			Delete(&models.FriendRequest{}).Error
+	})
+}
+
+// UnblockUser 解除拉黑
+// 对方仍保留着与我的好友记录时恢复为好友，否则直接删除这条拉黑记录
+func UnblockUser(ctx context.Context, userID uint, req BlockUserReq) error {
+	defer invalidateRecommend(ctx, userID, req.TargetID)
+
+	return global.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rel models.Relation
+		if err := tx.Where("owner_id = ? AND target_id = ? AND type = 2", userID, req.TargetID).
+			First(&rel).Error; err != nil {
+			return ErrNotBlocked
+		}
+
+		var reverse int64
+		if err := tx.Model(&models.Relation{}).
+			Where("owner_id = ? AND target_id = ? AND type = 1", req.TargetID, userID).
+			Count(&reverse).Error; err != nil {
+			return err
+		}
+		if reverse > 0 {
+			return tx.Model(&rel).Update("type", 1).Error
+		}
+
+		// 物理删除，避免软删除记录残留影响后续重新加好友
+		return tx.Unscoped().Delete(&rel).Error
+	})
+}
+
+// GetBlockedList 获取我的黑名单
+func GetBlockedList(ctx context.Context, userID uint) ([]UserResponseDTO, error) {
+	var users []models.User
+	err := global.DB.WithContext(ctx).
+		Joins("JOIN relations ON relations.target_id = users.id AND relations.deleted_at IS NULL").
+		Where("relations.owner_id = ? AND relations.type = 2", userID).
+		Order("relations.updated_at desc").
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return ToUserDTOs(users), nil
+}
+
+// IsBlocked 双方中任意一方拉黑了另一方
+// 供好友申请、按用户名搜索等需要双向屏蔽的场景使用
+func IsBlocked(ctx context.Context, a, b uint) (bool, error) {
+	return isBlocked(global.DB.WithContext(ctx), a, b)
+}
+
+// isBlocked 同 IsBlocked，在给定的连接 (如事务) 上查询
+func isBlocked(db *gorm.DB, a, b uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.Relation{}).
+		Where("type = 2 AND ((owner_id = ? AND target_id = ?) OR (owner_id = ? AND target_id = ?))", a, b, b, a).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// blockedByIDs 拉黑了 userID 的用户集合
+func blockedByIDs(ctx context.Context, userID uint) (map[uint]struct{}, error) {
+	var ids []uint
+	err := global.DB.WithContext(ctx).Model(&models.Relation{}).
+		Where("target_id = ? AND type = 2", userID).
+		Pluck("owner_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[uint]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set, nil
+}
@@ -3,9 +3,12 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"go-chat/global"
 	"go-chat/internal/models"
+	"go-chat/internal/pkg/moderation"
 	"go-chat/internal/pkg/protocol"
+	"io"
 	"sync"
 	"time"
 
@@ -16,9 +19,10 @@ import (
 // ChatManager 管理所有 WebSocket 连接
 // 这是一个单例模式，全局只有一个 manager
 type ChatManager struct {
-	// Clients 记录所有在线用户: map[UserID] -> *Client
+	// Clients 记录所有在线连接: map[UserID] -> map[SessionID] -> *Client
+	// 同一用户可以在多台设备上同时在线，每个登录会话最多一条连接
 	// 使用 sync.RWMutex 保护并发读写安全
-	Clients map[uint]*Client
+	Clients map[uint]map[string]*Client
 	Lock    sync.RWMutex
 
 	// Register 注册连接通道
@@ -30,14 +34,27 @@ type ChatManager struct {
 
 // Client 代表一个 WebSocket 连接
 type Client struct {
-	UserID uint
-	Socket *websocket.Conn
-	Send   chan []byte // 待发送的数据管道
+	UserID    uint
+	SessionID string // 所属登录会话 (JWT sid)，设备被下线时据此断开
+	TokenID   string // 建立连接时使用的 Access Token (jti)，Token 被吊销时据此断开
+	Socket    *websocket.Conn
+	Send      chan []byte    // 待发送的数据管道，不会被关闭，连接结束以 Done 通知
+	Done      chan struct{}  // 连接结束时关闭，之后 Write 退出，SendEnvelope 直接丢弃数据
+	Codec     protocol.Codec // 握手时协商出的编解码器 (JSON / Protobuf)
+
+	// Version 客户端声明的信封版本，0 为旧版扁平格式
+	Version int
+
+	// 上行限流 (固定窗口计数)，只在 Read 协程内访问，无需加锁
+	windowStart time.Time
+	windowCount int
+
+	stopOnce sync.Once
 }
 
 // 全局 Manager 实例
 var Manager = ChatManager{
-	Clients:    make(map[uint]*Client),
+	Clients:    make(map[uint]map[string]*Client),
 	Register:   make(chan *Client),
 	Unregister: make(chan *Client),
 }
@@ -49,29 +66,99 @@ func (manager *ChatManager) Start() {
 		case conn := <-manager.Register:
 			// 建立连接
 			manager.Lock.Lock()
-			manager.Clients[conn.UserID] = conn
+			sessions, ok := manager.Clients[conn.UserID]
+			if !ok {
+				sessions = make(map[string]*Client)
+				manager.Clients[conn.UserID] = sessions
+			}
+			if old, ok := sessions[conn.SessionID]; ok && old != conn {
+				// 同一会话重复连接，关闭旧连接
+				// 旧连接的 Read 协程和持有快照的推送方可能仍在使用它，所以不关闭 Send，而是通过 Done 通知
+				old.stop()
+			}
+			sessions[conn.SessionID] = conn
 			manager.Lock.Unlock()
 
 			// 设置在线状态到 Redis
 			global.RDB.Set(context.Background(), onlineStatusKey(conn.UserID), "1", 0)
-			global.Log.Info("user online", zap.Uint("user_id", conn.UserID))
+			global.Log.Info("user online", zap.Uint("user_id", conn.UserID), zap.String("session_id", conn.SessionID))
 
 		case conn := <-manager.Unregister:
 			// 断开连接
+			// 只注销自己：旧连接被新连接顶替后，不能把新连接也移除
+			conn.stop()
+			offline := false
 			manager.Lock.Lock()
-			if _, ok := manager.Clients[conn.UserID]; ok {
-				close(conn.Send) // 关闭发送通道
-				delete(manager.Clients, conn.UserID)
-				global.Log.Info("user offline", zap.Uint("user_id", conn.UserID))
+			sessions := manager.Clients[conn.UserID]
+			if current, ok := sessions[conn.SessionID]; ok && current == conn {
+				delete(sessions, conn.SessionID)
+				if len(sessions) == 0 {
+					delete(manager.Clients, conn.UserID)
+					offline = true
+				}
 			}
 			manager.Lock.Unlock()
 
-			// 清除在线状态
-			global.RDB.Del(context.Background(), onlineStatusKey(conn.UserID))
+			// 所有设备都断开后才清除在线状态
+			if offline {
+				global.RDB.Del(context.Background(), onlineStatusKey(conn.UserID))
+				global.Log.Info("user offline", zap.Uint("user_id", conn.UserID))
+			}
 		}
 	}
 }
 
+// clientsOf 返回用户当前所有在线连接的快照
+func (manager *ChatManager) clientsOf(userID uint) []*Client {
+	manager.Lock.RLock()
+	defer manager.Lock.RUnlock()
+
+	sessions := manager.Clients[userID]
+	clients := make([]*Client, 0, len(sessions))
+	for _, client := range sessions {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// KickToken 断开使用指定 Access Token 建立的连接
+func (manager *ChatManager) KickToken(tokenID string) {
+	manager.Lock.RLock()
+	var targets []*Client
+	for _, sessions := range manager.Clients {
+		for _, client := range sessions {
+			if client.TokenID == tokenID {
+				targets = append(targets, client)
+			}
+		}
+	}
+	manager.Lock.RUnlock()
+
+	for _, client := range targets {
+		client.Kick("token revoked")
+	}
+}
+
+// KickSession 断开指定登录会话的连接 (设备被下线时调用)
+func (manager *ChatManager) KickSession(userID uint, sessionID string) {
+	manager.Lock.RLock()
+	client, ok := manager.Clients[userID][sessionID]
+	manager.Lock.RUnlock()
+
+	if ok {
+		client.Kick("session revoked")
+	}
+}
+
+// Kick 以 1008 (Policy Violation) 关闭连接，Read 随之退出并走正常的注销流程
+func (c *Client) Kick(reason string) {
+	global.Log.Info("kick websocket client", zap.Uint("user_id", c.UserID), zap.String("reason", reason))
+	c.Socket.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason),
+		time.Now().Add(time.Second))
+	c.Socket.Close()
+}
+
 // Send 向客户端发送数据
 func (c *Client) Write() {
 	defer func() {
@@ -80,16 +167,22 @@ func (c *Client) Write() {
 
 	for {
 		select {
-		case message, ok := <-c.Send:
-			if !ok {
-				c.Socket.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-			c.Socket.WriteMessage(websocket.TextMessage, message)
+		case message := <-c.Send:
+			c.Socket.WriteMessage(c.Codec.FrameType(), message)
+		case <-c.Done:
+			c.Socket.WriteMessage(websocket.CloseMessage, []byte{})
+			return
 		}
 	}
 }
 
+// stop 标记连接结束，可重复调用
+func (c *Client) stop() {
+	c.stopOnce.Do(func() {
+		close(c.Done)
+	})
+}
+
 // Read 从客户端读取数据
 func (c *Client) Read() {
 	defer func() {
@@ -99,28 +192,73 @@ func (c *Client) Read() {
 
 	for {
 		// 读取消息
-		_, messageBytes, err := c.Socket.ReadMessage()
+		messageBytes, err := c.readMessage()
 		if err != nil {
+			if errors.Is(err, websocket.ErrReadLimit) || errors.Is(err, errMessageTooBig) {
+				global.Log.Warn("websocket message too big", zap.Uint("user_id", c.UserID))
+			}
 			Manager.Unregister <- c
 			c.Socket.Close()
 			break
 		}
 
-		var msg protocol.Message
-		if err := json.Unmarshal(messageBytes, &msg); err != nil {
-			global.Log.Error("json unmarshal error", zap.Error(err))
+		env, err := c.Codec.Decode(messageBytes)
+		if err != nil {
+			global.Log.Warn("decode message error", zap.String("codec", c.Codec.Name()), zap.Error(err))
+			c.SendError("", protocol.ErrCodeBadFrame, "消息格式错误")
+			continue
+		}
+
+		if env.Version > protocol.Version {
+			c.SendError(env.RequestID, protocol.ErrCodeBadVersion, "不支持的协议版本")
 			continue
 		}
 
 		// 处理消息
-		c.HandleMessage(msg)
+		c.HandleMessage(env)
+	}
+}
+
+var errMessageTooBig = errors.New("websocket: decompressed message too big")
+
+// readMessage 读取一条完整的消息
+// SetReadLimit 只限制线上的帧长度 (超出时 gorilla 自动以 1009 关闭)，压缩帧解压后的大小不受其约束，
+// 这里按解压后的字节数再限制一次，超出时同样以 1009 关闭，避免小帧解压出超大消息耗尽内存
+func (c *Client) readMessage() ([]byte, error) {
+	_, r, err := c.Socket.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	maxSize := global.Config.GetInt64("websocket.max_message_size")
+	if maxSize <= 0 {
+		return io.ReadAll(r)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		c.Socket.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseMessageTooBig, "message too big"),
+			time.Now().Add(time.Second))
+		return nil, errMessageTooBig
 	}
+	return data, nil
 }
 
-func (c *Client) HandleMessage(msg protocol.Message) {
-	switch msg.Type {
+func (c *Client) HandleMessage(env *protocol.Envelope) {
+	switch env.Type {
 	case protocol.TypeSingleMsg:
-		c.sendSingleMessage(msg)
+		if env.Message == nil {
+			c.SendError(env.RequestID, protocol.ErrCodeBadFrame, "缺少消息内容")
+			return
+		}
+		if !c.allow() {
+			c.SendError(env.RequestID, protocol.ErrCodeRateLimited, "发送过于频繁，请稍后再试")
+			return
+		}
+		c.sendSingleMessage(env.RequestID, *env.Message)
 
 	case protocol.TypeHeartbeat:
 		// 心跳保活，不做处理
@@ -129,56 +267,230 @@ func (c *Client) HandleMessage(msg protocol.Message) {
 		// 登录/上线通知，目前已在连接时处理
 
 	default:
-		global.Log.Warn("unknown message type", zap.Int("type", msg.Type))
+		global.Log.Warn("unknown message type", zap.Int("type", env.Type))
+		c.SendError(env.RequestID, protocol.ErrCodeUnknownType, "未知的消息类型")
+	}
+}
+
+// allow 上行消息限流，窗口为 1 秒
+func (c *Client) allow() bool {
+	limit := global.Config.GetInt("websocket.rate_limit")
+	if limit <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	if now.Sub(c.windowStart) >= time.Second {
+		c.windowStart = now
+		c.windowCount = 0
 	}
+	c.windowCount++
+	return c.windowCount <= limit
 }
 
-func (c *Client) sendSingleMessage(msg protocol.Message) {
+// SendEnvelope 按客户端协商的编码格式编码后投递到发送管道
+func (c *Client) SendEnvelope(env *protocol.Envelope) {
+	data, err := c.Codec.Encode(env)
+	if err != nil {
+		global.Log.Error("encode envelope failed", zap.String("codec", c.Codec.Name()), zap.Error(err))
+		return
+	}
+	// 连接已结束时丢弃，避免阻塞在无人接收的管道上
+	select {
+	case c.Send <- data:
+	case <-c.Done:
+	}
+}
+
+// SendError 向客户端回一个错误帧，requestID 为对应的上行请求ID
+func (c *Client) SendError(requestID string, code int, message string) {
+	c.SendEnvelope(&protocol.Envelope{
+		Version:   protocol.Version,
+		RequestID: requestID,
+		Type:      protocol.TypeError,
+		Error:     &protocol.Error{Code: code, Message: message},
+	})
+}
+
+func (c *Client) sendSingleMessage(requestID string, msg protocol.Message) {
+	// 0. 校验好友关系 / 拉黑 / 目标是否存在
+	isRequest, err := CheckSingleMessage(context.Background(), c.UserID, msg.TargetID)
+	if err != nil {
+		c.SendError(requestID, messageErrorCode(err), messageErrorText(err))
+		return
+	}
+
+	// 0.1 内容审核：违规直接拒绝，命中屏蔽词的替换为 *，可疑内容先入库、人工审核通过后再投递
+	verdict := moderateMessage(context.Background(), c.UserID, msg.TargetID, msg.Content)
+	if verdict.Action == moderation.Reject {
+		recordModerationHit(context.Background(), c.UserID, msg.TargetID, msg.Content, nil, verdict)
+		c.SendError(requestID, protocol.ErrCodeModerated, verdict.Reason)
+		return
+	}
+
 	dbMsg := models.Message{
 		FromUserID: c.UserID,
 		ToUserID:   msg.TargetID,
-		Content:    msg.Content,
+		Content:    verdict.Content,
 		Type:       msg.Type,
-		Media:      1,
+		Media:      models.MediaText,
+		IsRequest:  isRequest,
+	}
+	held := verdict.Action == moderation.Review
+	if held {
+		dbMsg.Status = models.MessageHeld
+	}
+
+	// 媒体消息：附件必须是自己上传的，并按当前配置重新校验类型和大小
+	var media *models.Media
+	if msg.MediaID != 0 {
+		media, err = checkMessageMedia(context.Background(), c.UserID, msg.MediaID)
+		if err != nil {
+			c.SendError(requestID, messageErrorCode(err), messageErrorText(err))
+			return
+		}
+		dbMsg.Media = media.Kind
+		dbMsg.MediaID = media.ID
 	}
 
 	// 1. 直接入库（不使用 Kafka），GORM 会自动设置 CreatedAt
 	if err := global.DB.WithContext(context.Background()).Create(&dbMsg).Error; err != nil {
 		global.Log.Error("save message failed", zap.Error(err))
+		c.SendError(requestID, protocol.ErrCodeInternal, "消息发送失败")
+		return
+	}
+
+	if verdict.Action != moderation.Allow {
+		recordModerationHit(context.Background(), c.UserID, msg.TargetID, msg.Content, &dbMsg, verdict)
+	}
+
+	// 2. 清除相关聊天记录的 Redis 缓存 (审核中的消息不出现在聊天记录中)
+	if !held {
+		key := generateKey(dbMsg.FromUserID, dbMsg.ToUserID)
+		global.RDB.Del(context.Background(), key)
+		indexMessage(context.Background(), &dbMsg)
+	}
+
+	// 3. 回执给发送者，带回请求ID便于客户端对应；内容被屏蔽或转人工审核时一并告知原因
+	ack := &protocol.Ack{MsgID: dbMsg.ID, SendTime: dbMsg.CreatedAt.Unix()}
+	if verdict.Action != moderation.Allow {
+		ack.Reason = verdict.Reason
+	}
+	if dbMsg.Content != msg.Content {
+		ack.Content = dbMsg.Content
+	}
+	if held {
+		ack.Status = protocol.AckStatusHeld
+	}
+	c.SendEnvelope(&protocol.Envelope{
+		Version:   protocol.Version,
+		RequestID: requestID,
+		Type:      protocol.TypeAck,
+		Ack:       ack,
+	})
+
+	// 审核通过后再投递 (见 ApproveModerationHit)
+	if held {
 		return
 	}
 
-	// 2. 清除相关聊天记录的 Redis 缓存
-	key := generateKey(dbMsg.FromUserID, dbMsg.ToUserID)
-	global.RDB.Del(context.Background(), key)
+	// 4. 只推送给接收方，不推送给发送者自己
+	// 陌生人消息请求只进入对方的请求箱，不实时打扰
+	if !isRequest {
+		pushMessage(dbMsg, media)
+	}
+
+	// 5. 文本中有链接时异步生成预览，完成后再通过事件推送
+	unfurlMessage(dbMsg)
+}
 
-	// 3. 只推送给接收方，不推送给发送者自己
-	PushMessageToUser(dbMsg)
+// messageErrorCode 将发送消息时的业务错误映射为错误帧错误码
+func messageErrorCode(err error) int {
+	switch {
+	case errors.Is(err, ErrTargetNotFound):
+		return protocol.ErrCodeNoTarget
+	case errors.Is(err, ErrNotFriend):
+		return protocol.ErrCodeNotFriend
+	case errors.Is(err, ErrBlockedByTarget), errors.Is(err, ErrTargetBlocked):
+		return protocol.ErrCodeBlocked
+	case errors.Is(err, ErrMessageRequestLimit):
+		return protocol.ErrCodeRequestCap
+	case errors.Is(err, ErrMediaNotFound), errors.Is(err, ErrMediaType), errors.As(err, new(*MediaTooLargeError)):
+		return protocol.ErrCodeBadMedia
+	default:
+		return protocol.ErrCodeInternal
+	}
 }
 
-func PushMessageToUser(msg models.Message) {
-	Manager.Lock.RLock()
-	targetClient, ok := Manager.Clients[msg.ToUserID]
-	Manager.Lock.RUnlock()
+// messageErrorText 业务错误直接展示，内部错误不向客户端暴露细节
+func messageErrorText(err error) string {
+	if messageErrorCode(err) == protocol.ErrCodeInternal {
+		global.Log.Error("check message permission failed", zap.Error(err))
+		return "消息发送失败"
+	}
+	return err.Error()
+}
 
-	if ok {
-		var sendTime int64
-		if !msg.CreatedAt.IsZero() {
-			sendTime = msg.CreatedAt.Unix()
-		} else {
-			sendTime = time.Now().Unix()
-		}
-		reply := protocol.Reply{
-			FromID:   msg.FromUserID,
-			Content:  msg.Content,
-			Type:     protocol.TypeSingleMsg,
-			SendTime: sendTime,
-		}
-		replyBytes, err := json.Marshal(reply)
-		if err != nil {
-			global.Log.Error("marshal reply failed", zap.Error(err))
-			return
+// PushMessageToUser 推送给接收方的所有在线设备
+func PushMessageToUser(msg models.Message) {
+	var media *models.Media
+	if msg.MediaID != 0 && len(Manager.clientsOf(msg.ToUserID)) > 0 {
+		media = &models.Media{}
+		if err := global.DB.First(media, msg.MediaID).Error; err != nil {
+			global.Log.Error("load message media failed", zap.Uint("media_id", msg.MediaID), zap.Error(err))
+			media = nil
 		}
-		targetClient.Send <- replyBytes
+	}
+	pushMessage(msg, media)
+}
+
+// pushMessage 推送消息，media 为消息引用的附件 (文本消息为 nil)
+func pushMessage(msg models.Message, media *models.Media) {
+	targetClients := Manager.clientsOf(msg.ToUserID)
+	if len(targetClients) == 0 {
+		return
+	}
+
+	var sendTime int64
+	if !msg.CreatedAt.IsZero() {
+		sendTime = msg.CreatedAt.Unix()
+	} else {
+		sendTime = time.Now().Unix()
+	}
+	reply := protocol.Reply{
+		MsgID:    msg.ID,
+		FromID:   msg.FromUserID,
+		Content:  msg.Content,
+		Type:     protocol.TypeSingleMsg,
+		SendTime: sendTime,
+		Media:    msg.Media,
+	}
+	if media != nil {
+		reply.Attachment = ToAttachment(media)
+	}
+	for _, targetClient := range targetClients {
+		targetClient.SendEnvelope(&protocol.Envelope{
+			Version: targetClient.Version,
+			Type:    protocol.TypeSingleMsg,
+			Reply:   &reply,
+		})
+	}
+}
+
+// PushEvent 向在线用户推送事件通知
+// 不在线则直接忽略，客户端上线后通过 HTTP 接口拉取最新状态
+func PushEvent(userID uint, name string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		global.Log.Error("marshal event failed", zap.String("event", name), zap.Error(err))
+		return
+	}
+
+	for _, targetClient := range Manager.clientsOf(userID) {
+		targetClient.SendEnvelope(&protocol.Envelope{
+			Version: protocol.Version,
+			Type:    protocol.TypeEvent,
+			Event:   &protocol.Event{Name: name, Data: payload},
+		})
 	}
 }
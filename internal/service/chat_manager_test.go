@@ -0,0 +1,32 @@
+package service
+
+import (
+	"go-chat/internal/pkg/protocol"
+	"testing"
+	"time"
+)
+
+// 同一会话重连时旧连接被 stop，之后仍持有旧连接快照的推送方向它发送不能 panic 或阻塞
+func TestSendEnvelopeAfterStop(t *testing.T) {
+	c := &Client{
+		Send:  make(chan []byte),
+		Done:  make(chan struct{}),
+		Codec: protocol.CodecFor(""),
+	}
+
+	// 重连顶替时 stop 一次，旧连接的 Read 退出注销时再 stop 一次
+	c.stop()
+	c.stop()
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		c.SendEnvelope(&protocol.Envelope{Version: protocol.Version, Type: protocol.TypeHeartbeat})
+	}()
+
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("SendEnvelope blocked on a stopped client")
+	}
+}
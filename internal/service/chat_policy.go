@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 陌生人消息策略 (chat.stranger_policy)
+const (
+	StrangerPolicyDeny    = "deny"    // 只有好友之间可以发消息
+	StrangerPolicyRequest = "request" // 陌生人可发送少量消息，进入对方的消息请求箱
+)
+
+var (
+	ErrTargetNotFound      = errors.New("目标用户不存在")
+	ErrNotFriend           = errors.New("对方不是你的好友")
+	ErrBlockedByTarget     = errors.New("消息已被对方拒收")
+	ErrTargetBlocked       = errors.New("你已将对方拉黑，无法发送消息")
+	ErrMessageRequestLimit = errors.New("对方回复前无法继续发送消息")
+)
+
+// CheckSingleMessage 校验 fromID 能否给 toID 发送单聊消息
+// isRequest 为 true 表示双方不是好友，该消息按陌生人消息请求处理
+func CheckSingleMessage(ctx context.Context, fromID, toID uint) (isRequest bool, err error) {
+	// 1. 目标用户必须存在
+	var target models.User
+	if err := global.DB.WithContext(ctx).Select("id").First(&target, toID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, ErrTargetNotFound
+		}
+		return false, err
+	}
+
+	// 2. 一次查出双向关系记录
+	var relations []models.Relation
+	if err := global.DB.WithContext(ctx).
+		Where("(owner_id = ? AND target_id = ?) OR (owner_id = ? AND target_id = ?)", fromID, toID, toID, fromID).
+		Find(&relations).Error; err != nil {
+		return false, err
+	}
+
+	isFriend := false
+	for _, rel := range relations {
+		switch {
+		case rel.OwnerID == toID && rel.Type == 2:
+			return false, ErrBlockedByTarget // 对方拉黑了我
+		case rel.OwnerID == fromID && rel.Type == 2:
+			return false, ErrTargetBlocked // 我拉黑了对方
+		case rel.OwnerID == fromID && rel.Type == 1:
+			isFriend = true
+		}
+	}
+	if isFriend {
+		return false, nil
+	}
+
+	// 3. 非好友，按配置的陌生人策略处理
+	if global.Config.GetString("chat.stranger_policy") != StrangerPolicyRequest {
+		return false, ErrNotFriend
+	}
+
+	// 对方已经给我发过消息，说明会话已被接受，按普通消息处理
+	var replied int64
+	if err := global.DB.WithContext(ctx).Model(&models.Message{}).
+		Where("from_user_id = ? AND to_user_id = ? AND status = ?", toID, fromID, models.MessageNormal).
+		Limit(1).Count(&replied).Error; err != nil {
+		return false, err
+	}
+	if replied > 0 {
+		return false, nil
+	}
+
+	var sent int64
+	if err := global.DB.WithContext(ctx).Model(&models.Message{}).
+		Where("from_user_id = ? AND to_user_id = ? AND is_request = ?", fromID, toID, true).
+		Count(&sent).Error; err != nil {
+		return false, err
+	}
+	if sent >= int64(global.Config.GetInt("chat.stranger_msg_limit")) {
+		return false, ErrMessageRequestLimit
+	}
+
+	return true, nil
+}
@@ -56,7 +56,9 @@ func handleMessageWithLocalRetry(value []byte) error {
 			// 成功！后续推送逻辑...
 			key := generateKey(dbMsg.ToUserID, dbMsg.FromUserID)
 			global.RDB.Del(context.Background(), key)
+			indexMessage(context.Background(), &dbMsg)
 			PushMessageToUser(dbMsg)
+			unfurlMessage(dbMsg)
 			return nil
 		}
 		time.Sleep(100 * time.Millisecond) // 短暂避让
@@ -83,7 +85,9 @@ func handleMessageWithDelayRetry(value []byte) error {
 		// 终于成功了
 		key := generateKey(dbMsg.ToUserID, dbMsg.FromUserID)
 		global.RDB.Del(context.Background(), key)
+		indexMessage(context.Background(), &dbMsg)
 		PushMessageToUser(dbMsg)
+		unfurlMessage(dbMsg)
 		return nil
 	}
 
@@ -2,19 +2,37 @@ package service
 
 import (
 	"go-chat/internal/models"
+	"go-chat/internal/pkg/protocol"
 )
 
 // ToMessageDTO 将DB实体转换为DTO
 // DATETIME转化为int64时间戳
 func ToMessageDTO(m *models.Message) MessageDTO {
 	return MessageDTO{
-		ID:         m.ID,
-		FromUserID: m.FromUserID,
-		ToUserID:   m.ToUserID,
-		Content:    m.Content,
-		Type:       m.Type,
-		Media:      m.Media,
-		CreatedAt:  m.CreatedAt.UnixMilli(),
+		ID:          m.ID,
+		FromUserID:  m.FromUserID,
+		ToUserID:    m.ToUserID,
+		Content:     m.Content,
+		Type:        m.Type,
+		Media:       m.Media,
+		MediaID:     m.MediaID,
+		IsRequest:   m.IsRequest,
+		CreatedAt:   m.CreatedAt.UnixMilli(),
+		LinkPreview: ToLinkPreviewDTO(m.LinkPreview),
+	}
+}
+
+// ToLinkPreviewDTO 转换链接预览，没有预览时返回 nil
+func ToLinkPreviewDTO(p *models.LinkPreview) *LinkPreviewDTO {
+	if p == nil {
+		return nil
+	}
+	return &LinkPreviewDTO{
+		URL:         p.URL,
+		Title:       p.Title,
+		Description: p.Description,
+		Image:       p.Image,
+		SiteName:    p.SiteName,
 	}
 }
 
@@ -31,6 +49,67 @@ func ToMessageDTOs(msgs []models.Message) []MessageDTO {
 	return dtos
 }
 
+// ToMediaDTO 将附件实体转换为DTO
+func ToMediaDTO(m *models.Media) MediaDTO {
+	// 附件不公开，一律给出有时效的签名地址
+	var thumbs []ThumbnailDTO
+	for _, t := range m.Thumbnails {
+		u, _ := SignMediaURL(m.ID, thumbnailVariant(t))
+		thumbs = append(thumbs, ThumbnailDTO{URL: u, Width: t.Width, Height: t.Height})
+	}
+	u, expires := SignMediaURL(m.ID, "")
+	return MediaDTO{
+		ID:         m.ID,
+		Kind:       m.Kind,
+		URL:        u,
+		ExpiresAt:  expires.Unix(),
+		MimeType:   m.MimeType,
+		Size:       m.Size,
+		Width:      m.Width,
+		Height:     m.Height,
+		DurationMs: m.DurationMs,
+		FileName:   m.FileName,
+		Blurhash:   m.Blurhash,
+		Thumbnails: thumbs,
+	}
+}
+
+// ToAttachment 将附件实体转换为 WebSocket 推送中的附件信息
+func ToAttachment(m *models.Media) *protocol.Attachment {
+	dto := ToMediaDTO(m)
+	var thumbs []protocol.Thumbnail
+	for _, t := range dto.Thumbnails {
+		thumbs = append(thumbs, protocol.Thumbnail{URL: t.URL, Width: t.Width, Height: t.Height})
+	}
+	return &protocol.Attachment{
+		ID:         dto.ID,
+		Kind:       dto.Kind,
+		URL:        dto.URL,
+		ExpiresAt:  dto.ExpiresAt,
+		MimeType:   dto.MimeType,
+		Size:       dto.Size,
+		Width:      dto.Width,
+		Height:     dto.Height,
+		DurationMs: dto.DurationMs,
+		FileName:   dto.FileName,
+		Blurhash:   dto.Blurhash,
+		Thumbnails: thumbs,
+	}
+}
+
+// ToUploadSessionDTO 将分片上传任务转换为DTO
+func ToUploadSessionDTO(up *models.MediaUpload, uploaded []int) *UploadSessionDTO {
+	return &UploadSessionDTO{
+		UploadID:    up.UploadID,
+		FileName:    up.FileName,
+		Size:        up.Size,
+		ChunkSize:   up.ChunkSize,
+		TotalChunks: up.TotalChunks,
+		Uploaded:    uploaded,
+		ExpiresAt:   up.ExpiresAt.Unix(),
+	}
+}
+
 // ToUserDTO 将User实体转换为DTO
 func ToUserDTO(u models.User) UserResponseDTO {
 	return UserResponseDTO{
@@ -78,3 +157,29 @@ func ToUserDTOs(users []models.User) []UserResponseDTO {
 	}
 	return dtos
 }
+
+// ToModerationHitDTO 将审核记录转换为DTO
+func ToModerationHitDTO(h *models.ModerationHit) ModerationHitDTO {
+	matches := make([]ModerationMatchDTO, len(h.Matches))
+	for i, m := range h.Matches {
+		matches[i] = ModerationMatchDTO{Filter: m.Filter, Action: m.Action, Reason: m.Reason, Match: m.Match}
+	}
+	dto := ModerationHitDTO{
+		ID:           h.ID,
+		UserID:       h.UserID,
+		TargetID:     h.TargetID,
+		MessageID:    h.MessageID,
+		Content:      h.Content,
+		Action:       h.Action,
+		Reason:       h.Reason,
+		Matches:      matches,
+		ReviewStatus: h.ReviewStatus,
+		ReviewerID:   h.ReviewerID,
+		ReviewNote:   h.ReviewNote,
+		CreatedAt:    h.CreatedAt.UnixMilli(),
+	}
+	if h.ReviewedAt != nil {
+		dto.ReviewedAt = h.ReviewedAt.UnixMilli()
+	}
+	return dto
+}
@@ -8,23 +8,133 @@ type MessageDTO struct {
 	Content    string `json:"content"`
 	Type       int    `json:"type"`
 	Media      int    `json:"media"`
+	MediaID    uint   `json:"media_id,omitempty"`
+	IsRequest  bool   `json:"is_request,omitempty"`
 	CreatedAt  int64  `json:"created_at"`
+
+	Attachment  *MediaDTO       `json:"attachment,omitempty"`   // 媒体消息的附件
+	LinkPreview *LinkPreviewDTO `json:"link_preview,omitempty"` // 文本中链接的预览，发送后异步生成
+}
+
+// 出参：链接预览
+type LinkPreviewDTO struct {
+	URL         string `json:"url"`                   // 消息中的原始链接
+	Title       string `json:"title"`                 // 网页标题
+	Description string `json:"description,omitempty"` // 网页摘要
+	Image       string `json:"image,omitempty"`       // 预览图地址 (第三方站点)
+	SiteName    string `json:"site_name,omitempty"`   // 站点名称
+}
+
+// 出参：上传的附件
+type MediaDTO struct {
+	ID         uint           `json:"id"`
+	Kind       int            `json:"kind"`                  // 媒体类型: 2图片 3音频 4视频 5文件
+	URL        string         `json:"url"`                   // 签名下载地址，过期后通过 /media/:id/url 重新获取
+	ExpiresAt  int64          `json:"expires_at"`            // url 及缩略图地址的过期时间 (Unix 秒)
+	MimeType   string         `json:"mime_type"`             // 根据文件内容识别出的类型
+	Size       int64          `json:"size"`                  // 字节数
+	Width      int            `json:"width,omitempty"`       // 图片/视频宽度 (像素)
+	Height     int            `json:"height,omitempty"`      // 图片/视频高度 (像素)
+	DurationMs int64          `json:"duration_ms,omitempty"` // 音视频时长 (毫秒)
+	FileName   string         `json:"file_name,omitempty"`   // 原始文件名
+	Blurhash   string         `json:"blurhash,omitempty"`    // 图片的模糊占位图，加载完成前用于预览
+	Thumbnails []ThumbnailDTO `json:"thumbnails,omitempty"`  // 图片缩略图，按尺寸从小到大
+}
+
+// 入参：创建分片上传任务
+type InitUploadRequest struct {
+	FileName  string `json:"file_name" binding:"required,max=255"`
+	Size      int64  `json:"size" binding:"required,min=1"`                 // 文件总字节数
+	ChunkSize int64  `json:"chunk_size"`                                    // 期望的分片大小 (256KB-16MB)，不在范围内时使用服务端默认值
+	SHA256    string `json:"sha256" binding:"omitempty,len=64,hexadecimal"` // 整个文件的摘要，合并时校验，可不传
+	AsFile    bool   `json:"as_file"`                                       // 作为普通文件发送
+}
+
+// 出参：分片上传任务
+type UploadSessionDTO struct {
+	UploadID    string `json:"upload_id"`
+	FileName    string `json:"file_name"`
+	Size        int64  `json:"size"`
+	ChunkSize   int64  `json:"chunk_size"` // 除最后一片外每片的字节数
+	TotalChunks int    `json:"total_chunks"`
+	Uploaded    []int  `json:"uploaded"`   // 已上传的分片序号，续传时跳过
+	ExpiresAt   int64  `json:"expires_at"` // 超过该时间未完成会被清理，每上传一片顺延
+}
+
+// 出参：附件的签名下载地址
+type MediaURLDTO struct {
+	URL       string `json:"url"`
+	ExpiresAt int64  `json:"expires_at"` // 过期时间 (Unix 秒)
+}
+
+// 出参：图片缩略图
+type ThumbnailDTO struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
 }
 
 type LoginResponseDTO struct {
-	Token    string `json:"token"`
-	Username string `json:"username"`
-	Nickname string `json:"nickname"`
+	Token        string `json:"token"`         // Access Token
+	RefreshToken string `json:"refresh_token"` // 用于换取新 Token，只能使用一次
+	ExpiresIn    int64  `json:"expires_in"`    // Access Token 有效期 (秒)
+	Username     string `json:"username"`
+	Nickname     string `json:"nickname"`
+
+	// 开启了两步验证时不返回 Token，客户端需带 challenge_token 和验证码调用 /user/login/2fa
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+}
+
+// 出参：TOTP 设置信息
+type TOTPSetupDTO struct {
+	Secret string `json:"secret"` // Base32 密钥，无法扫码时手动输入
+	URI    string `json:"uri"`    // otpauth:// 地址，前端据此生成二维码
+}
+
+// 出参：两步验证恢复码
+type RecoveryCodesDTO struct {
+	Codes []string `json:"codes"` // 只显示这一次，每个只能使用一次
+}
+
+// 入参：登录设备信息
+type DeviceInfo struct {
+	Name      string // 客户端上报的设备名
+	IP        string
+	UserAgent string
+}
+
+// 出参：登录设备
+type SessionDTO struct {
+	SessionID  string `json:"session_id"`
+	DeviceName string `json:"device_name"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	Current    bool   `json:"current"`      // 是否为当前设备
+	CreatedAt  int64  `json:"created_at"`   // 登录时间 (毫秒时间戳)
+	LastSeenAt int64  `json:"last_seen_at"` // 最后活跃时间 (毫秒时间戳)
+}
+
+// 入参：刷新 Token
+type RefreshTokenReq struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// 入参：退出登录 (带上 Refresh Token 则一并作废)
+type LogoutReq struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 type UserResponseDTO struct {
-	ID            uint   `json:"id"`
-	Username      string `json:"username"`
-	Nickname      string `json:"nickname"`
-	Avatar        string `json:"avatar"`
-	Online        bool   `json:"online"`
-	UnreadCount   int    `json:"unread_count"`
-	LastMsgTime   int64  `json:"last_message_time,omitempty"`
+	ID          uint   `json:"id"`
+	Username    string `json:"username"`
+	Nickname    string `json:"nickname"`
+	Avatar      string `json:"avatar"`
+	Online      bool   `json:"online"`
+	UnreadCount int    `json:"unread_count"`
+	LastMsgTime int64  `json:"last_message_time,omitempty"`
+	Remark      string `json:"remark,omitempty"` // 好友备注名
+	TagID       uint   `json:"tag_id,omitempty"` // 所属好友分组
 }
 
 // 入参：发送申请
@@ -39,6 +149,49 @@ type HandleFriendRequestReq struct {
 	Action    int  `json:"action" binding:"required,oneof=1 2"` // 只能传 1 或 2
 }
 
+// 入参：拉黑 / 解除拉黑
+type BlockUserReq struct {
+	TargetID uint `json:"target_id" binding:"required"`
+}
+
+// 入参：邀请入群
+type InviteGroupMemberReq struct {
+	GroupID  uint `json:"group_id" binding:"required"`
+	TargetID uint `json:"target_id" binding:"required"`
+}
+
+// 入参：撤回申请
+type WithdrawFriendRequestReq struct {
+	RequestID uint `json:"request_id" binding:"required"`
+}
+
+// 入参：删除好友
+type DeleteFriendReq struct {
+	TargetID uint `json:"target_id" binding:"required"`
+}
+
+// 入参：修改好友备注
+type UpdateFriendRemarkReq struct {
+	TargetID uint   `json:"target_id" binding:"required"`
+	Remark   string `json:"remark" binding:"max=64"`
+}
+
+// 入参：获取好友列表 (可按分组过滤)
+type FriendListReq struct {
+	TagID *uint `form:"tag_id"` // 不传返回全部，0 表示未分组
+}
+
+// 入参：创建/重命名好友分组
+type FriendTagReq struct {
+	Name string `json:"name" binding:"required,max=32"`
+}
+
+// 入参：设置好友所属分组
+type SetFriendTagReq struct {
+	TargetID uint `json:"target_id" binding:"required"`
+	TagID    uint `json:"tag_id"` // 0 表示移出分组
+}
+
 // 入参：标记消息已读
 type MarkMessagesReadReq struct {
 	TargetID uint `json:"target_id" binding:"required"`
@@ -54,3 +207,162 @@ type FriendRequestDTO struct {
 	Status     int    `json:"status"`      // 状态
 	CreatedAt  string `json:"created_at"`  // 时间
 }
+
+// 出参：我发出的申请列表项
+type SentFriendRequestDTO struct {
+	ID           uint   `json:"id"`            // 申请记录ID
+	ReceiverID   uint   `json:"receiver_id"`   // 接收人ID
+	ReceiverName string `json:"receiver_name"` // 接收人用户名
+	Avatar       string `json:"avatar"`        // 接收人头像
+	Remark       string `json:"remark"`        // 附言
+	Status       int    `json:"status"`        // 状态
+	CreatedAt    string `json:"created_at"`    // 时间
+}
+
+// 入参：模糊搜索用户
+type SearchUsersReq struct {
+	Keyword  string `form:"keyword" binding:"required,max=64"`
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=50"`
+}
+
+// 出参：分页的用户列表
+type UserPageDTO struct {
+	List     []UserResponseDTO `json:"list"`
+	Total    int64             `json:"total"`
+	Page     int               `json:"page"`
+	PageSize int               `json:"page_size"`
+}
+
+// 入参：修改隐私设置 (不传的字段保持不变)
+type UpdatePrivacyReq struct {
+	PhoneSearchable *bool `json:"phone_searchable"`
+	EmailSearchable *bool `json:"email_searchable"`
+}
+
+// 出参：隐私设置
+type PrivacySettingsDTO struct {
+	PhoneSearchable bool `json:"phone_searchable"` // 允许通过手机号搜索到我
+	EmailSearchable bool `json:"email_searchable"` // 允许通过邮箱搜索到我
+}
+
+// 入参：搜索聊天记录
+type SearchMessagesReq struct {
+	Keyword  string `form:"keyword" binding:"required,max=64"`
+	PeerID   uint   `form:"peer_id"`   // 只搜与该好友的单聊
+	GroupID  uint   `form:"group_id"`  // 只搜该群
+	SenderID uint   `form:"sender_id"` // 只搜该用户发送的消息
+	Start    int64  `form:"start"`     // 起始时间 (毫秒时间戳)
+	End      int64  `form:"end"`       // 结束时间 (毫秒时间戳)
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=50"`
+}
+
+// 出参：聊天记录搜索结果
+type MessageSearchHitDTO struct {
+	Message   MessageDTO `json:"message"`
+	Highlight string     `json:"highlight"` // HTML 转义后的内容，命中的关键词用 <em> 包裹
+}
+
+// 出参：分页的聊天记录搜索结果
+type MessageSearchPageDTO struct {
+	List     []MessageSearchHitDTO `json:"list"`
+	Total    int64                 `json:"total"`
+	Page     int                   `json:"page"`
+	PageSize int                   `json:"page_size"`
+}
+
+// 入参：可能认识的人
+type RecommendReq struct {
+	Limit int `form:"limit" binding:"omitempty,min=1,max=50"`
+}
+
+// 出参：可能认识的人
+type RecommendUserDTO struct {
+	ID            uint   `json:"id"`
+	Username      string `json:"username"`
+	Nickname      string `json:"nickname"`
+	Avatar        string `json:"avatar"`
+	MutualFriends int    `json:"mutual_friends"` // 共同好友数
+	SharedGroups  int    `json:"shared_groups"`  // 共同群数
+}
+
+// 出参：好友分组
+type FriendTagDTO struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"` // 分组内好友数量
+}
+
+// 推送：好友相关事件数据
+type FriendEventDTO struct {
+	UserID uint `json:"user_id"` // 触发事件的用户
+}
+
+// 推送：消息的链接预览已生成
+type MessagePreviewEventDTO struct {
+	MsgID      uint           `json:"msg_id"`
+	FromUserID uint           `json:"from_user_id"`
+	ToUserID   uint           `json:"to_user_id"`
+	Preview    LinkPreviewDTO `json:"preview"`
+}
+
+// 推送：好友申请状态变化
+type FriendRequestEventDTO struct {
+	RequestID uint `json:"request_id"` // 申请记录ID
+	UserID    uint `json:"user_id"`    // 触发事件的用户
+	Status    int  `json:"status"`     // 申请的最新状态
+}
+
+// 入参：管理员查看审核记录
+type ModerationHitsReq struct {
+	ReviewStatus *int   `form:"review_status" binding:"omitempty,min=0,max=3"` // 0无需处理 1待审核 2通过 3未通过，不传为全部
+	Action       string `form:"action" binding:"omitempty,oneof=mask review reject"`
+	UserID       uint   `form:"user_id"` // 只看该用户发送的
+	Page         int    `form:"page" binding:"omitempty,min=1"`
+	PageSize     int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+// 入参：审核不通过
+type RejectModerationReq struct {
+	Note string `json:"note" binding:"max=255"` // 告知发送者的说明，可为空
+}
+
+// 出参：审核记录
+type ModerationHitDTO struct {
+	ID           uint                 `json:"id"`
+	UserID       uint                 `json:"user_id"`
+	TargetID     uint                 `json:"target_id"`
+	MessageID    uint                 `json:"message_id,omitempty"` // 被拒绝发送的消息没有入库
+	Content      string               `json:"content"`              // 原始内容
+	Action       string               `json:"action"`               // mask / review / reject
+	Reason       string               `json:"reason"`
+	Matches      []ModerationMatchDTO `json:"matches"`
+	ReviewStatus int                  `json:"review_status"`
+	ReviewerID   uint                 `json:"reviewer_id,omitempty"`
+	ReviewNote   string               `json:"review_note,omitempty"`
+	ReviewedAt   int64                `json:"reviewed_at,omitempty"`
+	CreatedAt    int64                `json:"created_at"`
+}
+
+// 出参：单个过滤器的命中
+type ModerationMatchDTO struct {
+	Filter string `json:"filter"` // keyword / spam / classifier
+	Action string `json:"action"` // 该过滤器的判定
+	Reason string `json:"reason,omitempty"`
+	Match  string `json:"match,omitempty"` // 命中的关键词、规则等
+}
+
+// 出参：分页的审核记录
+type ModerationHitPageDTO struct {
+	List     []ModerationHitDTO `json:"list"`
+	Total    int64              `json:"total"`
+	Page     int                `json:"page"`
+	PageSize int                `json:"page_size"`
+}
+
+// 推送：我发出的消息审核结果
+type ModerationEventDTO struct {
+	MsgID  uint   `json:"msg_id"`
+	Reason string `json:"reason,omitempty"` // 审核未通过的原因
+}
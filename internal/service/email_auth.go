@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"go-chat/internal/pkg/mailer"
+	"go-chat/internal/pkg/utils"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidMailToken  = errors.New("链接无效或已过期")
+	ErrNoEmail           = errors.New("请先设置邮箱")
+	ErrEmailVerified     = errors.New("邮箱已验证")
+	ErrMailTooFrequent   = errors.New("发送过于频繁，请稍后再试")
+	ErrEmailChanged      = errors.New("邮箱已变更，请重新发送验证邮件")
+	errMailerUnavailable = errors.New("mailer not initialized")
+)
+
+// emailVerifyToken 邮箱验证 Token 在 Redis 中对应的内容
+// 记录发送时的邮箱，验证前邮箱被修改则作废
+type emailVerifyToken struct {
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+func emailVerifyTTL() time.Duration {
+	hours := global.Config.GetInt("mail.verify_expire_hours")
+	if hours <= 0 {
+		hours = 24
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func passwordResetTTL() time.Duration {
+	minutes := global.Config.GetInt("mail.reset_expire_minutes")
+	if minutes <= 0 {
+		minutes = 30
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// mailLink 拼接邮件中的前端链接
+func mailLink(path, token string) string {
+	base := strings.TrimRight(global.Config.GetString("mail.link_base"), "/")
+	return base + path + "?token=" + url.QueryEscape(token)
+}
+
+// mailRateLimit 每个地址每小时最多发送的邮件数
+func mailRateLimit() int {
+	limit := global.Config.GetInt("mail.send_rate_limit")
+	if limit == 0 {
+		limit = 5
+	}
+	return limit
+}
+
+func sendMail(ctx context.Context, mail mailer.Mail) error {
+	if global.Mailer == nil {
+		return errMailerUnavailable
+	}
+	return global.Mailer.Send(ctx, mail)
+}
+
+// SendEmailVerification 向当前邮箱发送验证邮件
+func SendEmailVerification(ctx context.Context, userID uint) error {
+	var user models.User
+	if err := global.DB.WithContext(ctx).First(&user, userID).Error; err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return ErrNoEmail
+	}
+	if user.EmailVerified {
+		return ErrEmailVerified
+	}
+	if !allowRate(ctx, mailRateKey(user.Email), mailRateLimit(), time.Hour) {
+		return ErrMailTooFrequent
+	}
+
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return err
+	}
+	data, _ := json.Marshal(emailVerifyToken{UserID: user.ID, Email: user.Email})
+	if err := global.RDB.Set(ctx, emailVerifyKey(hashToken(token)), data, emailVerifyTTL()).Err(); err != nil {
+		return err
+	}
+
+	return sendMail(ctx, mailer.Mail{
+		To:      user.Email,
+		Subject: "验证你的 GoChat 邮箱",
+		Body: fmt.Sprintf("%s，你好：\n\n请点击下面的链接完成邮箱验证（%d 小时内有效）：\n%s\n\n如果这不是你的操作，请忽略本邮件。\n",
+			user.Nickname, int(emailVerifyTTL().Hours()), mailLink("/verify-email", token)),
+	})
+}
+
+// sendEmailVerificationAsync 注册后异步发送验证邮件，失败只记日志
+func sendEmailVerificationAsync(userID uint) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := SendEmailVerification(ctx, userID); err != nil {
+			global.Log.Warn("send verification email failed", zap.Uint("user_id", userID), zap.Error(err))
+		}
+	}()
+}
+
+// VerifyEmail 校验邮箱验证 Token，Token 只能使用一次
+func VerifyEmail(ctx context.Context, token string) error {
+	data, err := global.RDB.GetDel(ctx, emailVerifyKey(hashToken(token))).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return ErrInvalidMailToken
+	}
+	if err != nil {
+		return err
+	}
+
+	var payload emailVerifyToken
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return ErrInvalidMailToken
+	}
+
+	// 邮箱未变更才标记为已验证
+	result := global.DB.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND email = ?", payload.UserID, payload.Email).
+		Update("email_verified", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrEmailChanged
+	}
+	return nil
+}
+
+// ForgotPassword 向已验证的邮箱发送重置密码邮件
+// 无论邮箱是否存在都返回成功，避免被用来探测注册邮箱
+func ForgotPassword(ctx context.Context, email, ip string) error {
+	email = strings.TrimSpace(email)
+	if !allowRate(ctx, mailRateKey(email), mailRateLimit(), time.Hour) ||
+		!allowRate(ctx, resetIPRateKey(ip), global.Config.GetInt("mail.reset_ip_rate_limit"), time.Hour) {
+		return ErrMailTooFrequent
+	}
+
+	var users []models.User
+	if err := global.DB.WithContext(ctx).
+		Where("email = ? AND email_verified = ? AND status <> ?", email, true, 2).
+		Find(&users).Error; err != nil {
+		return err
+	}
+
+	// 同一邮箱可能绑定了多个账号，每个账号单独发一封
+	for _, user := range users {
+		token, err := utils.RandomToken(32)
+		if err != nil {
+			return err
+		}
+		if err := global.RDB.Set(ctx, passwordResetKey(hashToken(token)), user.ID, passwordResetTTL()).Err(); err != nil {
+			return err
+		}
+
+		mail := mailer.Mail{
+			To:      user.Email,
+			Subject: "重置你的 GoChat 密码",
+			Body: fmt.Sprintf("%s，你好：\n\n我们收到了重置账号 %s 密码的请求，请点击下面的链接设置新密码（%d 分钟内有效）：\n%s\n\n如果这不是你的操作，请忽略本邮件，你的密码不会被修改。\n",
+				user.Nickname, user.Username, int(passwordResetTTL().Minutes()), mailLink("/reset-password", token)),
+		}
+		if err := sendMail(ctx, mail); err != nil {
+			global.Log.Error("send password reset email failed", zap.Uint("user_id", user.ID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// ResetPassword 用重置 Token 设置新密码，Token 只能使用一次
+// 成功后所有设备下线，并解除该账号的登录锁定
+func ResetPassword(ctx context.Context, token, newPassword string) error {
+	userID, err := global.RDB.GetDel(ctx, passwordResetKey(hashToken(token))).Uint64()
+	if errors.Is(err, redis.Nil) {
+		return ErrInvalidMailToken
+	}
+	if err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := global.DB.WithContext(ctx).First(&user, uint(userID)).Error; err != nil {
+		return ErrInvalidMailToken
+	}
+
+	hashPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := global.DB.WithContext(ctx).Model(&user).Update("password", string(hashPassword)).Error; err != nil {
+		return err
+	}
+
+	if _, err := RevokeOtherSessions(ctx, user.ID, ""); err != nil {
+		global.Log.Error("revoke sessions after password reset failed", zap.Uint("user_id", user.ID), zap.Error(err))
+	}
+	id := normalizeUsername(user.Username)
+	global.RDB.Del(ctx, loginLockKey("user", id), loginFailKey("user", id), loginStrikeKey("user", id))
+	return nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTagNotFound = errors.New("分组不存在")
+	ErrTagExists   = errors.New("分组名称已存在")
+)
+
+// GetFriendTags 获取我的好友分组 (带分组内好友数量)
+func GetFriendTags(ctx context.Context, userID uint) ([]FriendTagDTO, error) {
+	var tags []models.FriendTag
+	if err := global.DB.WithContext(ctx).
+		Where("owner_id = ?", userID).
+		Order("id asc").
+		Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	// 一次统计每个分组的好友数量
+	var counts []struct {
+		TagID uint
+		Count int
+	}
+	if err := global.DB.WithContext(ctx).Model(&models.Relation{}).
+		Select("tag_id, COUNT(*) AS count").
+		Where("owner_id = ? AND type = 1 AND tag_id > 0", userID).
+		Group("tag_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	countMap := make(map[uint]int, len(counts))
+	for _, c := range counts {
+		countMap[c.TagID] = c.Count
+	}
+
+	dtos := make([]FriendTagDTO, 0, len(tags))
+	for _, tag := range tags {
+		dtos = append(dtos, FriendTagDTO{ID: tag.ID, Name: tag.Name, Count: countMap[tag.ID]})
+	}
+	return dtos, nil
+}
+
+// CreateFriendTag 新建好友分组
+func CreateFriendTag(ctx context.Context, userID uint, req FriendTagReq) (*FriendTagDTO, error) {
+	if err := checkTagName(ctx, userID, 0, req.Name); err != nil {
+		return nil, err
+	}
+
+	tag := models.FriendTag{OwnerID: userID, Name: req.Name}
+	if err := global.DB.WithContext(ctx).Create(&tag).Error; err != nil {
+		return nil, err
+	}
+	return &FriendTagDTO{ID: tag.ID, Name: tag.Name}, nil
+}
+
+// RenameFriendTag 重命名好友分组
+func RenameFriendTag(ctx context.Context, userID, tagID uint, req FriendTagReq) error {
+	if _, err := findFriendTag(ctx, userID, tagID); err != nil {
+		return err
+	}
+	if err := checkTagName(ctx, userID, tagID, req.Name); err != nil {
+		return err
+	}
+
+	return global.DB.WithContext(ctx).Model(&models.FriendTag{}).
+		Where("id = ?", tagID).
+		Update("name", req.Name).Error
+}
+
+// DeleteFriendTag 删除好友分组，组内好友变为未分组
+func DeleteFriendTag(ctx context.Context, userID, tagID uint) error {
+	tag, err := findFriendTag(ctx, userID, tagID)
+	if err != nil {
+		return err
+	}
+
+	return global.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Relation{}).
+			Where("owner_id = ? AND tag_id = ?", userID, tagID).
+			Update("tag_id", 0).Error; err != nil {
+			return err
+		}
+		return tx.Delete(tag).Error
+	})
+}
+
+// SetFriendTag 设置好友所属分组，TagID 为 0 表示移出分组
+func SetFriendTag(ctx context.Context, userID uint, req SetFriendTagReq) error {
+	if req.TagID != 0 {
+		if _, err := findFriendTag(ctx, userID, req.TagID); err != nil {
+			return err
+		}
+	}
+
+	var rel models.Relation
+	if err := global.DB.WithContext(ctx).
+		Where("owner_id = ? AND target_id = ? AND type = 1", userID, req.TargetID).
+		First(&rel).Error; err != nil {
+		return ErrFriendNotFound
+	}
+
+	return global.DB.WithContext(ctx).Model(&rel).Update("tag_id", req.TagID).Error
+}
+
+// findFriendTag 查找属于 userID 的分组
+func findFriendTag(ctx context.Context, userID, tagID uint) (*models.FriendTag, error) {
+	var tag models.FriendTag
+	if err := global.DB.WithContext(ctx).
+		Where("id = ? AND owner_id = ?", tagID, userID).
+		First(&tag).Error; err != nil {
+		return nil, ErrTagNotFound
+	}
+	return &tag, nil
+}
+
+// checkTagName 同一用户下分组名不能重复，exceptID 为重命名时的分组自身
+func checkTagName(ctx context.Context, userID, exceptID uint, name string) error {
+	var count int64
+	if err := global.DB.WithContext(ctx).Model(&models.FriendTag{}).
+		Where("owner_id = ? AND name = ? AND id <> ?", userID, name, exceptID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTagExists
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrGroupNotFound      = errors.New("群聊不存在")
+	ErrAlreadyGroupMember = errors.New("对方已在群中")
+)
+
+// InviteGroupMember 邀请用户加入群聊
+// 邀请人必须是群成员；与被邀请人之间任意一方拉黑了另一方都不允许邀请，规则与好友申请一致
+func InviteGroupMember(ctx context.Context, userID uint, req InviteGroupMemberReq) error {
+	var group models.Group
+	if err := global.DB.WithContext(ctx).Select("id").First(&group, req.GroupID).Error; err != nil {
+		return ErrGroupNotFound
+	}
+
+	var target models.User
+	if err := global.DB.WithContext(ctx).Select("id").First(&target, req.TargetID).Error; err != nil {
+		return ErrTargetNotFound
+	}
+
+	return global.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if member, err := isGroupMember(tx, req.GroupID, userID); err != nil {
+			return err
+		} else if !member {
+			return ErrNotGroupMember
+		}
+		if member, err := isGroupMember(tx, req.GroupID, req.TargetID); err != nil {
+			return err
+		} else if member {
+			return ErrAlreadyGroupMember
+		}
+
+		if blocked, err := isBlocked(tx, userID, req.TargetID); err != nil {
+			return err
+		} else if blocked {
+			return ErrBlocked
+		}
+
+		return tx.Create(&models.GroupMember{GroupID: req.GroupID, UserID: req.TargetID, Role: 3}).Error
+	})
+}
+
+// isGroupMember userID 是否在群中
+func isGroupMember(db *gorm.DB, groupID, userID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.GroupMember{}).
+		Where("group_id = ? AND user_id = ?", groupID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"testing"
+)
+
+// 邀请入群与好友申请使用同样的双向拉黑规则
+func TestInviteGroupMemberBlocked(t *testing.T) {
+	setupTestEnv(t, &models.Relation{}, &models.FriendRequest{}, &models.Group{}, &models.GroupMember{})
+	ctx := context.Background()
+	ids := createTestUsers(t, 4)
+	owner, blockedByOwner, blockerOfOwner, stranger := ids[0], ids[1], ids[2], ids[3]
+
+	group := models.Group{Name: "test", OwnerID: owner, Type: 1}
+	if err := global.DB.Create(&group).Error; err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	if err := global.DB.Create(&models.GroupMember{GroupID: group.ID, UserID: owner, Role: 1}).Error; err != nil {
+		t.Fatalf("create member: %v", err)
+	}
+
+	if err := BlockUser(ctx, owner, BlockUserReq{TargetID: blockedByOwner}); err != nil {
+		t.Fatalf("block: %v", err)
+	}
+	if err := BlockUser(ctx, blockerOfOwner, BlockUserReq{TargetID: owner}); err != nil {
+		t.Fatalf("block: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		inviter uint
+		target  uint
+		want    error
+	}{
+		{"inviter blocked target", owner, blockedByOwner, ErrBlocked},
+		{"target blocked inviter", owner, blockerOfOwner, ErrBlocked},
+		{"inviter not in group", stranger, blockedByOwner, ErrNotGroupMember},
+		{"ok", owner, stranger, nil},
+		{"already member", owner, stranger, ErrAlreadyGroupMember},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := InviteGroupMember(ctx, tt.inviter, InviteGroupMemberReq{GroupID: group.ID, TargetID: tt.target})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("want %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	var members int64
+	if err := global.DB.Model(&models.GroupMember{}).Where("group_id = ?", group.ID).Count(&members).Error; err != nil {
+		t.Fatalf("count members: %v", err)
+	}
+	if members != 2 {
+		t.Fatalf("want 2 members, got %d", members)
+	}
+}
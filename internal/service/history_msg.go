@@ -44,13 +44,16 @@ func GetHistoryMsg(ctx context.Context, userID uint, targetIDStr string) ([]Mess
 	err = global.DB.Where(
 		"(from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?)",
 		userID, targetIDStr, targetIDStr, userID,
-	).Order("created_at desc").Limit(100).Find(&messages).Error
+	).Where("status = ?", models.MessageNormal).Order("created_at desc").Limit(100).Find(&messages).Error
 	// 倒序desc，最新100条消息
 	if err != nil {
 		return nil, err
 	}
 
 	dtos := ToMessageDTOs(messages)
+	if err := attachMedia(ctx, dtos); err != nil {
+		return nil, err
+	}
 
 	// 回写 Redis (设置过期时间，例如 10 分钟)
 	// 注意：缓存的是转换后的 DTO 数据
@@ -63,3 +66,19 @@ func GetHistoryMsg(ctx context.Context, userID uint, targetIDStr string) ([]Mess
 
 	return dtos, nil
 }
+
+// GetMessageRequests 获取陌生人发给我的消息请求 (最新100条)
+func GetMessageRequests(ctx context.Context, userID uint) ([]MessageDTO, error) {
+	var messages []models.Message
+	err := global.DB.WithContext(ctx).
+		Where("to_user_id = ? AND is_request = ? AND status = ?", userID, true, models.MessageNormal).
+		Order("created_at desc").Limit(100).Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	dtos := ToMessageDTOs(messages)
+	if err := attachMedia(ctx, dtos); err != nil {
+		return nil, err
+	}
+	return dtos, nil
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"go-chat/internal/pkg/protocol"
+	"go-chat/internal/pkg/unfurl"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	// 预览缓存有效期默认值 (link_preview.cache_hours)
+	defaultLinkPreviewCacheTTL = 24 * time.Hour
+	// 抓取失败或页面没有预览信息时的缓存时间，避免反复抓取同一个链接
+	linkPreviewMissTTL = time.Hour
+	// 同时进行的抓取任务上限，超出时直接放弃该消息的预览
+	maxConcurrentUnfurls = 16
+)
+
+var (
+	linkFetcherOnce sync.Once
+	linkFetcher     *unfurl.Fetcher
+	unfurlSlots     = make(chan struct{}, maxConcurrentUnfurls)
+)
+
+func linkPreviewFetcher() *unfurl.Fetcher {
+	linkFetcherOnce.Do(func() {
+		allowPrivate := global.Config.GetBool("link_preview.allow_private")
+		if allowPrivate {
+			global.Log.Warn("link_preview.allow_private is enabled, link previews may reach internal addresses")
+		}
+		linkFetcher = unfurl.NewFetcher(unfurl.Options{
+			Timeout:      time.Duration(global.Config.GetInt("link_preview.timeout_ms")) * time.Millisecond,
+			MaxBytes:     global.Config.GetInt64("link_preview.max_bytes"),
+			UserAgent:    global.Config.GetString("link_preview.user_agent"),
+			AllowPrivate: allowPrivate,
+		})
+	})
+	return linkFetcher
+}
+
+func linkPreviewCacheTTL() time.Duration {
+	if h := global.Config.GetInt("link_preview.cache_hours"); h > 0 {
+		return time.Duration(h) * time.Hour
+	}
+	return defaultLinkPreviewCacheTTL
+}
+
+// unfurlMessage 文本消息中有链接时在后台生成预览，写回消息并通知会话双方，不阻塞消息发送
+func unfurlMessage(msg models.Message) {
+	if !global.Config.GetBool("link_preview.enabled") || msg.Media != models.MediaText || msg.Type != protocol.TypeSingleMsg {
+		return
+	}
+	link := unfurl.ExtractURL(msg.Content)
+	if link == "" {
+		return
+	}
+
+	select {
+	case unfurlSlots <- struct{}{}:
+	default:
+		global.Log.Warn("too many link previews in flight, skipped", zap.Uint("msg_id", msg.ID))
+		return
+	}
+	go func() {
+		defer func() { <-unfurlSlots }()
+		ctx := context.Background()
+
+		preview, err := GetLinkPreview(ctx, link)
+		if err != nil {
+			global.Log.Error("get link preview failed", zap.Uint("msg_id", msg.ID), zap.Error(err))
+			return
+		}
+		if preview == nil {
+			return
+		}
+
+		msg.LinkPreview = preview
+		if err := global.DB.WithContext(ctx).Model(&msg).Select("link_preview").Updates(&msg).Error; err != nil {
+			global.Log.Error("save link preview failed", zap.Uint("msg_id", msg.ID), zap.Error(err))
+			return
+		}
+		global.RDB.Del(ctx, generateKey(msg.FromUserID, msg.ToUserID))
+
+		event := MessagePreviewEventDTO{
+			MsgID:      msg.ID,
+			FromUserID: msg.FromUserID,
+			ToUserID:   msg.ToUserID,
+			Preview:    *ToLinkPreviewDTO(preview),
+		}
+		PushEvent(msg.FromUserID, protocol.EventMessagePreview, event)
+		// 陌生人消息请求不实时推送给对方，预览随消息一起在请求箱中拉取
+		if !msg.IsRequest {
+			PushEvent(msg.ToUserID, protocol.EventMessagePreview, event)
+		}
+	}()
+}
+
+// GetLinkPreview 读取链接预览，优先使用 Redis 缓存
+// 链接无法访问或页面没有预览信息时返回 nil，不算错误
+func GetLinkPreview(ctx context.Context, link string) (*models.LinkPreview, error) {
+	sum := sha256.Sum256([]byte(link))
+	key := linkPreviewKey(hex.EncodeToString(sum[:]))
+
+	val, err := global.RDB.Get(ctx, key).Result()
+	if err == nil {
+		if val == "" {
+			return nil, nil
+		}
+		var preview models.LinkPreview
+		if json.Unmarshal([]byte(val), &preview) == nil {
+			return &preview, nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		global.Log.Warn("read link preview cache failed", zap.Error(err))
+	}
+
+	p, err := linkPreviewFetcher().Fetch(ctx, link)
+	if err != nil {
+		// 目标站点的问题不影响业务，只记录调试日志并短暂缓存失败结果
+		global.Log.Debug("unfurl link failed", zap.String("url", link), zap.Error(err))
+		global.RDB.Set(ctx, key, "", linkPreviewMissTTL)
+		return nil, nil
+	}
+
+	preview := &models.LinkPreview{
+		URL:         p.URL,
+		Title:       p.Title,
+		Description: p.Description,
+		Image:       p.Image,
+		SiteName:    p.SiteName,
+	}
+	data, err := json.Marshal(preview)
+	if err != nil {
+		return nil, err
+	}
+	global.RDB.Set(ctx, key, data, linkPreviewCacheTTL())
+	return preview, nil
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"math"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrUserDisabled = errors.New("账号已被禁用")
+
+// 登录失败原因 (写入审计记录)
+const (
+	LoginFailUnknownUser = "unknown_user"
+	LoginFailBadPassword = "bad_password"
+	LoginFailBadTOTP     = "bad_totp"
+	LoginFailLocked      = "locked"
+	LoginFailDisabled    = "disabled"
+)
+
+// LoginLockedError 失败次数过多，暂时禁止登录
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	minutes := int(math.Ceil(e.RetryAfter.Minutes()))
+	if minutes < 1 {
+		minutes = 1
+	}
+	return fmt.Sprintf("登录失败次数过多，请 %d 分钟后再试", minutes)
+}
+
+// loginGuardConfig 登录防爆破参数
+type loginGuardConfig struct {
+	maxAttempts   int           // 同一用户名窗口内允许的失败次数
+	ipMaxAttempts int           // 同一 IP 窗口内允许的失败次数
+	window        time.Duration // 失败计数窗口
+	lockBase      time.Duration // 首次锁定时长，之后每次翻倍
+	lockMax       time.Duration // 锁定时长上限
+}
+
+func getLoginGuardConfig() loginGuardConfig {
+	cfg := loginGuardConfig{
+		maxAttempts:   global.Config.GetInt("login.max_attempts"),
+		ipMaxAttempts: global.Config.GetInt("login.ip_max_attempts"),
+		window:        time.Duration(global.Config.GetInt("login.window_minutes")) * time.Minute,
+		lockBase:      time.Duration(global.Config.GetInt("login.lock_minutes")) * time.Minute,
+		lockMax:       time.Duration(global.Config.GetInt("login.max_lock_minutes")) * time.Minute,
+	}
+	if cfg.window <= 0 {
+		cfg.window = 15 * time.Minute
+	}
+	if cfg.lockBase <= 0 {
+		cfg.lockBase = 5 * time.Minute
+	}
+	if cfg.lockMax < cfg.lockBase {
+		cfg.lockMax = 24 * time.Hour
+	}
+	return cfg
+}
+
+// checkLoginLocked 用户名或 IP 处于锁定期时返回 LoginLockedError
+func checkLoginLocked(ctx context.Context, username, ip string) error {
+	keys := []string{loginLockKey("user", normalizeUsername(username))}
+	if ip != "" {
+		keys = append(keys, loginLockKey("ip", ip))
+	}
+
+	var retryAfter time.Duration
+	for _, key := range keys {
+		ttl, err := global.RDB.PTTL(ctx, key).Result()
+		if err != nil {
+			global.Log.Warn("check login lock failed", zap.Error(err))
+			return nil
+		}
+		if ttl > retryAfter {
+			retryAfter = ttl
+		}
+	}
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// recordLoginFailure 累加失败次数，超过阈值后锁定
+// 锁定时长按锁定次数指数增长：lockBase * 2^(n-1)，不超过 lockMax
+func recordLoginFailure(ctx context.Context, username, ip string) {
+	cfg := getLoginGuardConfig()
+
+	lockIfExceeded := func(scope, id string, limit int) {
+		if limit <= 0 || id == "" {
+			return
+		}
+		failKey := loginFailKey(scope, id)
+		count, err := global.RDB.Incr(ctx, failKey).Result()
+		if err != nil {
+			global.Log.Warn("record login failure failed", zap.Error(err))
+			return
+		}
+		if count == 1 {
+			global.RDB.Expire(ctx, failKey, cfg.window)
+		}
+		if count < int64(limit) {
+			return
+		}
+
+		// 达到阈值：锁定并重新计数
+		strikeKey := loginStrikeKey(scope, id)
+		strikes, _ := global.RDB.Incr(ctx, strikeKey).Result()
+		global.RDB.Expire(ctx, strikeKey, 24*time.Hour)
+
+		lock := cfg.lockBase
+		for i := int64(1); i < strikes && lock < cfg.lockMax; i++ {
+			lock *= 2
+		}
+		if lock > cfg.lockMax {
+			lock = cfg.lockMax
+		}
+
+		global.RDB.Set(ctx, loginLockKey(scope, id), "1", lock)
+		global.RDB.Del(ctx, failKey)
+		global.Log.Warn("login locked", zap.String("scope", scope), zap.String("id", id), zap.Duration("lock", lock))
+	}
+
+	lockIfExceeded("user", normalizeUsername(username), cfg.maxAttempts)
+	lockIfExceeded("ip", ip, cfg.ipMaxAttempts)
+}
+
+// clearLoginFailures 登录成功后清空该用户名的失败记录 (IP 计数保留，防止用自己的账号刷新计数)
+func clearLoginFailures(ctx context.Context, username string) {
+	id := normalizeUsername(username)
+	global.RDB.Del(ctx, loginFailKey("user", id), loginStrikeKey("user", id))
+}
+
+// auditLoginFailure 异步写入登录失败审计，失败只记日志
+func auditLoginFailure(username string, userID uint, device DeviceInfo, reason string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		audit := models.LoginAudit{
+			Username:  truncate(username, 64),
+			UserID:    userID,
+			IP:        truncate(device.IP, 64),
+			UserAgent: truncate(device.UserAgent, 255),
+			Reason:    reason,
+		}
+		if err := global.DB.WithContext(ctx).Create(&audit).Error; err != nil {
+			global.Log.Error("write login audit failed", zap.String("username", username), zap.Error(err))
+		}
+	}()
+}
+
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
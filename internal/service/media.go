@@ -0,0 +1,259 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"go-chat/internal/pkg/mediainfo"
+	"go-chat/internal/pkg/utils"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMediaType      = errors.New("不支持的文件类型")
+	ErrMediaMalformed = errors.New("文件已损坏或格式不正确")
+	ErrMediaNotFound  = errors.New("附件不存在")
+	ErrMediaEmpty     = errors.New("文件不能为空")
+)
+
+// MediaTooLargeError 超过该类型的大小上限
+type MediaTooLargeError struct {
+	Limit int64
+}
+
+func (e *MediaTooLargeError) Error() string {
+	return fmt.Sprintf("文件不能超过 %dMB", e.Limit>>20)
+}
+
+// 图片像素上限，防止解压炸弹
+const mediaMaxPixels = 40_000_000
+
+// 各类型允许的文件格式 (根据内容识别，不看扩展名)，文件类型不限制格式
+var mediaMimeTypes = map[int]map[string]string{ // kind -> mime -> 扩展名
+	models.MediaImage: {"image/jpeg": ".jpg", "image/png": ".png", "image/gif": ".gif"},
+	models.MediaAudio: {"audio/mpeg": ".mp3", "audio/mp4": ".m4a", "audio/wav": ".wav", "audio/ogg": ".ogg"},
+	models.MediaVideo: {"video/mp4": ".mp4", "video/quicktime": ".mov", "video/webm": ".webm"},
+}
+
+var mediaKindNames = map[int]string{
+	models.MediaImage: "image",
+	models.MediaAudio: "audio",
+	models.MediaVideo: "video",
+	models.MediaFile:  "file",
+}
+
+// MediaMaxSize 各类型的大小上限 (media.max_size.<类型>)
+func MediaMaxSize(kind int) int64 {
+	defaults := map[int]int64{
+		models.MediaImage: 10 << 20,
+		models.MediaAudio: 20 << 20,
+		models.MediaVideo: 100 << 20,
+		models.MediaFile:  50 << 20,
+	}
+	if v := global.Config.GetInt64("media.max_size." + mediaKindNames[kind]); v > 0 {
+		return v
+	}
+	return defaults[kind]
+}
+
+// MediaUploadMaxSize 所有类型中最大的上限，用于在读取请求体之前拦截
+func MediaUploadMaxSize() int64 {
+	var max int64
+	for kind := range mediaKindNames {
+		if v := MediaMaxSize(kind); v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+// detectMediaKind 按识别出的类型归类；asFile 为 true 时一律作为文件发送
+func detectMediaKind(mime string, asFile bool) int {
+	if asFile {
+		return models.MediaFile
+	}
+	for _, kind := range []int{models.MediaImage, models.MediaAudio, models.MediaVideo} {
+		if _, ok := mediaMimeTypes[kind][mime]; ok {
+			return kind
+		}
+	}
+	return models.MediaFile
+}
+
+// UploadMedia 上传聊天附件：识别类型、校验大小、读取尺寸/时长后存储，返回附件信息
+// 图片会去掉 EXIF 等元数据后再存储，并生成缩略图和 BlurHash 占位图
+// 上传后通过 WebSocket 消息的 media_id 引用
+func UploadMedia(ctx context.Context, userID uint, file io.ReaderAt, size int64, fileName string, asFile bool) (*MediaDTO, error) {
+	if size <= 0 {
+		return nil, ErrMediaEmpty
+	}
+
+	mime := mediainfo.Sniff(file)
+	kind := detectMediaKind(mime, asFile)
+	if limit := MediaMaxSize(kind); size > limit {
+		return nil, &MediaTooLargeError{Limit: limit}
+	}
+
+	info, err := mediainfo.Probe(file, size, mime)
+	if err != nil {
+		// 音视频只是读不出时长，不影响发送；图片读不出尺寸说明文件有问题
+		if kind == models.MediaImage {
+			return nil, ErrMediaMalformed
+		}
+		global.Log.Warn("probe media failed", zap.String("mime", mime), zap.Error(err))
+	}
+	if kind == models.MediaImage && info.Width*info.Height > mediaMaxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	ext := mediaMimeTypes[kind][mime]
+	contentType := mime
+	if kind == models.MediaFile {
+		// 任意文件一律按二进制下载，避免 HTML/SVG 等在本站域名下被浏览器执行
+		ext = ".bin"
+		contentType = "application/octet-stream"
+	}
+
+	name, err := utils.RandomToken(12)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("media/%d/%s/%s%s", userID, time.Now().Format("200601"), name, ext)
+
+	media := models.Media{
+		UserID:     userID,
+		Kind:       kind,
+		StorageKey: key,
+		FileName:   cleanFileName(fileName),
+		MimeType:   mime,
+		Size:       size,
+		Width:      info.Width,
+		Height:     info.Height,
+		DurationMs: info.Duration.Milliseconds(),
+	}
+
+	var content io.Reader = io.NewSectionReader(file, 0, size)
+	var thumbs []imageThumbnail
+	if kind == models.MediaImage {
+		// 图片读入内存处理：去掉 EXIF 位置信息，生成缩略图和占位图
+		data := make([]byte, size)
+		if _, err := file.ReadAt(data, 0); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		img, err := processImage(data)
+		if err != nil {
+			return nil, err
+		}
+		content = bytes.NewReader(img.data)
+		media.Size = int64(len(img.data))
+		media.Width, media.Height = img.width, img.height
+		media.Blurhash = img.blurhash
+		thumbs = img.thumbnails
+	}
+
+	hash := sha256.New()
+	if err := global.Storage.Put(ctx, key, io.TeeReader(content, hash), media.Size, contentType); err != nil {
+		return nil, err
+	}
+	media.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	stored, err := storeThumbnails(ctx, key, thumbs)
+	if err != nil {
+		global.Storage.Delete(ctx, key)
+		return nil, err
+	}
+	media.Thumbnails = stored
+
+	if err := global.DB.WithContext(ctx).Create(&media).Error; err != nil {
+		global.Storage.Delete(ctx, key)
+		deleteThumbnails(ctx, stored)
+		return nil, err
+	}
+
+	dto := ToMediaDTO(&media)
+	return &dto, nil
+}
+
+// cleanFileName 只保留文件名本身，去掉路径和控制字符
+func cleanFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7F {
+			return -1
+		}
+		return r
+	}, name)
+	if !utf8.ValidString(name) {
+		name = strings.ToValidUTF8(name, "")
+	}
+	return truncate(name, 255)
+}
+
+// checkMessageMedia 发送消息前校验附件：必须是发送者自己上传的，且类型和大小符合当前配置
+func checkMessageMedia(ctx context.Context, userID, mediaID uint) (*models.Media, error) {
+	var media models.Media
+	err := global.DB.WithContext(ctx).Where("id = ? AND user_id = ?", mediaID, userID).First(&media).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := mediaKindNames[media.Kind]; !ok {
+		return nil, ErrMediaType
+	}
+	if allowed, ok := mediaMimeTypes[media.Kind]; ok {
+		if _, ok := allowed[media.MimeType]; !ok {
+			return nil, ErrMediaType
+		}
+	}
+	if limit := MediaMaxSize(media.Kind); media.Size > limit {
+		return nil, &MediaTooLargeError{Limit: limit}
+	}
+	return &media, nil
+}
+
+// attachMedia 为消息列表批量填充附件信息
+func attachMedia(ctx context.Context, dtos []MessageDTO) error {
+	var ids []uint
+	for _, d := range dtos {
+		if d.MediaID != 0 {
+			ids = append(ids, d.MediaID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var list []models.Media
+	if err := global.DB.WithContext(ctx).Where("id IN ?", ids).Find(&list).Error; err != nil {
+		return err
+	}
+	byID := make(map[uint]*models.Media, len(list))
+	for i := range list {
+		byID[list[i].ID] = &list[i]
+	}
+	for i := range dtos {
+		if m, ok := byID[dtos[i].MediaID]; ok {
+			dto := ToMediaDTO(m)
+			dtos[i].Attachment = &dto
+		}
+	}
+	return nil
+}
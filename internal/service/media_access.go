@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"go-chat/internal/pkg/protocol"
+	"go-chat/internal/pkg/storage"
+	"io"
+	"mime"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMediaLinkInvalid = errors.New("链接无效")
+	ErrMediaLinkExpired = errors.New("链接已过期")
+)
+
+const (
+	// 签名地址默认有效期 (media.url_ttl_hours)
+	defaultMediaURLTTL = 24 * time.Hour
+	// 跳转到存储后端预签名地址的有效期，只用于这一次下载
+	mediaRedirectTTL = 5 * time.Minute
+)
+
+var (
+	mediaURLKeyOnce sync.Once
+	mediaURLKey     []byte
+)
+
+// mediaURLSecret 签名密钥：media.url_secret，未配置时由 jwt.secret 派生
+// 都没有时随机生成，重启后已签发的地址全部失效，且多实例之间不通用
+func mediaURLSecret() []byte {
+	mediaURLKeyOnce.Do(func() {
+		if secret := global.Config.GetString("media.url_secret"); secret != "" {
+			mediaURLKey = []byte(secret)
+			return
+		}
+		if secret := global.Config.GetString("jwt.secret"); secret != "" {
+			sum := sha256.Sum256([]byte("media-url:" + secret))
+			mediaURLKey = sum[:]
+			return
+		}
+		global.Log.Warn("media.url_secret is not configured, signed media URLs will not survive restarts")
+		mediaURLKey = make([]byte, 32)
+		rand.Read(mediaURLKey)
+	})
+	return mediaURLKey
+}
+
+func mediaURLTTL() time.Duration {
+	if h := global.Config.GetInt("media.url_ttl_hours"); h > 0 {
+		return time.Duration(h) * time.Hour
+	}
+	return defaultMediaURLTTL
+}
+
+func mediaSignature(mediaID uint, variant string, expires int64) string {
+	mac := hmac.New(sha256.New, mediaURLSecret())
+	fmt.Fprintf(mac, "%d\n%s\n%d", mediaID, variant, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignMediaURL 生成附件的签名下载地址，到期前无需登录即可访问 (用于 <img>、CDN 缓存、离线下载等)
+// variant 为空表示原文件，否则为缩略图尺寸 (如 480x360)
+// 到期时间向上取整到整点，同一小时内对同一附件生成的地址相同，便于客户端和 CDN 缓存
+func SignMediaURL(mediaID uint, variant string) (string, time.Time) {
+	expires := time.Now().Add(mediaURLTTL()).Truncate(time.Hour).Add(time.Hour)
+
+	q := url.Values{}
+	if variant != "" {
+		q.Set("variant", variant)
+	}
+	q.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	q.Set("sig", mediaSignature(mediaID, variant, expires.Unix()))
+
+	base := strings.TrimRight(global.Config.GetString("media.url_base"), "/")
+	return fmt.Sprintf("%s/api/media/%d/download?%s", base, mediaID, q.Encode()), expires
+}
+
+// VerifyMediaURL 校验签名下载地址，返回剩余有效期
+func VerifyMediaURL(mediaID uint, variant, expiresStr, sig string) (time.Duration, error) {
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || sig == "" {
+		return 0, ErrMediaLinkInvalid
+	}
+	if !hmac.Equal([]byte(sig), []byte(mediaSignature(mediaID, variant, expires))) {
+		return 0, ErrMediaLinkInvalid
+	}
+	remaining := time.Until(time.Unix(expires, 0))
+	if remaining <= 0 {
+		return 0, ErrMediaLinkExpired
+	}
+	return remaining, nil
+}
+
+// GetMedia 按 ID 读取附件 (签名地址已校验过权限)
+func GetMedia(ctx context.Context, mediaID uint) (*models.Media, error) {
+	var media models.Media
+	err := global.DB.WithContext(ctx).First(&media, mediaID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+// GetAccessibleMedia 读取当前用户有权查看的附件：自己上传的，或出现在自己参与的单聊、所在群的群聊消息中
+// 无权访问时同样返回 ErrMediaNotFound，不暴露附件是否存在
+func GetAccessibleMedia(ctx context.Context, userID, mediaID uint) (*models.Media, error) {
+	media, err := GetMedia(ctx, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	if media.UserID == userID {
+		return media, nil
+	}
+
+	db := global.DB.WithContext(ctx)
+	myGroups := db.Model(&models.GroupMember{}).Select("group_id").Where("user_id = ?", userID)
+	var count int64
+	err = db.Model(&models.Message{}).
+		Where("media_id = ? AND status = ?", mediaID, models.MessageNormal).
+		Where(db.Where("type = ? AND (from_user_id = ? OR to_user_id = ?)", protocol.TypeSingleMsg, userID, userID).
+			Or("type = ? AND to_user_id IN (?)", protocol.TypeGroupMsg, myGroups)).
+		Limit(1).Count(&count).Error
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrMediaNotFound
+	}
+	return media, nil
+}
+
+// MediaContent 附件内容：存储后端支持预签名时只给出跳转地址，否则给出读取流 (调用方负责关闭)
+type MediaContent struct {
+	RedirectURL string
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64 // 未知时为 -1
+	Disposition string
+	ModTime     time.Time
+}
+
+// OpenMedia 打开附件原文件或指定尺寸的缩略图
+func OpenMedia(ctx context.Context, media *models.Media, variant string) (*MediaContent, error) {
+	key, contentType, size := media.StorageKey, media.MimeType, media.Size
+	if media.Kind == models.MediaFile {
+		contentType = "application/octet-stream"
+	}
+	if variant != "" {
+		t, ok := findThumbnail(media, variant)
+		if !ok {
+			return nil, ErrMediaNotFound
+		}
+		key, contentType, size = t.StorageKey, t.MimeType, -1
+	}
+
+	content := &MediaContent{
+		ContentType: contentType,
+		Size:        size,
+		Disposition: mediaDisposition(media),
+		ModTime:     media.CreatedAt,
+	}
+
+	if presigner, ok := global.Storage.(storage.Presigner); ok {
+		params := url.Values{"response-content-disposition": {content.Disposition}}
+		u, err := presigner.PresignGet(key, mediaRedirectTTL, params)
+		if err != nil {
+			return nil, err
+		}
+		content.RedirectURL = u
+		return content, nil
+	}
+
+	body, err := global.Storage.Get(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		global.Log.Error("media object missing", zap.Uint("media_id", media.ID), zap.String("key", key))
+		return nil, ErrMediaNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	content.Body = body
+	return content, nil
+}
+
+// mediaDisposition 图片和音视频可以直接在浏览器中打开，文件一律作为附件下载
+func mediaDisposition(media *models.Media) string {
+	disposition := "inline"
+	if media.Kind == models.MediaFile {
+		disposition = "attachment"
+	}
+	if media.FileName == "" {
+		return disposition
+	}
+	// 非 ASCII 文件名按 RFC 2231 编码
+	if v := mime.FormatMediaType(disposition, map[string]string{"filename": media.FileName}); v != "" {
+		return v
+	}
+	return disposition
+}
+
+// thumbnailVariant 缩略图在地址中的标识 (宽x高)
+func thumbnailVariant(t models.MediaThumbnail) string {
+	return fmt.Sprintf("%dx%d", t.Width, t.Height)
+}
+
+// HasThumbnail 附件是否有该尺寸的缩略图
+func HasThumbnail(media *models.Media, variant string) bool {
+	_, ok := findThumbnail(media, variant)
+	return ok
+}
+
+func findThumbnail(media *models.Media, variant string) (models.MediaThumbnail, bool) {
+	for _, t := range media.Thumbnails {
+		if thumbnailVariant(t) == variant {
+			return t, true
+		}
+	}
+	return models.MediaThumbnail{}, false
+}
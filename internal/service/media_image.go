@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"go-chat/internal/pkg/blurhash"
+	"go-chat/internal/pkg/imageutil"
+	"image"
+	"path"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// 默认缩略图尺寸 (长边像素)，可通过 media.thumbnail_sizes 调整
+var defaultThumbnailSizes = []int{160, 480, 1080}
+
+// processedImage 处理后的图片：去掉元数据的原图，以及按显示方向生成的缩略图
+type processedImage struct {
+	data       []byte
+	width      int // 显示方向下的宽高
+	height     int
+	blurhash   string
+	thumbnails []imageThumbnail
+}
+
+type imageThumbnail struct {
+	data   []byte
+	format string
+	width  int
+	height int
+}
+
+// thumbnailSizes 配置的缩略图尺寸，去重后从大到小排列
+func thumbnailSizes() []int {
+	sizes := global.Config.GetIntSlice("media.thumbnail_sizes")
+	if len(sizes) == 0 {
+		sizes = defaultThumbnailSizes
+	}
+	seen := make(map[int]bool, len(sizes))
+	var out []int
+	for _, s := range sizes {
+		if s > 0 && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(out)))
+	return out
+}
+
+// processImage 去掉 EXIF 位置等元数据，按 EXIF 方向摆正后生成各尺寸缩略图和 BlurHash
+// 原图只做无损的元数据删除，不重新编码
+func processImage(data []byte) (*processedImage, error) {
+	img, format, err := imageutil.Decode(data, mediaMaxPixels)
+	if errors.Is(err, imageutil.ErrTooLarge) {
+		return nil, ErrImageTooLarge
+	}
+	if err != nil {
+		return nil, ErrMediaMalformed
+	}
+
+	stripped, orientation, err := imageutil.StripMetadata(data, format)
+	if err != nil {
+		return nil, ErrMediaMalformed
+	}
+	img = imageutil.Orient(img, orientation)
+
+	b := img.Bounds()
+	result := &processedImage{data: stripped, width: b.Dx(), height: b.Dy()}
+
+	// 从大到小依次缩放，每次以上一级缩略图为源，减少计算量
+	// 不超过缩略图尺寸的原图直接使用原图，不再生成
+	src := img
+	for _, size := range thumbnailSizes() {
+		if result.width <= size && result.height <= size {
+			continue
+		}
+		src = imageutil.Fit(src, size, size)
+		var buf bytes.Buffer
+		if err := imageutil.Encode(&buf, src, format); err != nil {
+			return nil, err
+		}
+		tb := src.Bounds()
+		result.thumbnails = append(result.thumbnails, imageThumbnail{
+			data: buf.Bytes(), format: format, width: tb.Dx(), height: tb.Dy(),
+		})
+	}
+	// 存储和展示按从小到大
+	for i, j := 0, len(result.thumbnails)-1; i < j; i, j = i+1, j-1 {
+		result.thumbnails[i], result.thumbnails[j] = result.thumbnails[j], result.thumbnails[i]
+	}
+
+	hash, err := imageBlurhash(src)
+	if err != nil {
+		// 占位图只是锦上添花，失败不影响发送
+		global.Log.Warn("encode blurhash failed", zap.Error(err))
+	}
+	result.blurhash = hash
+	return result, nil
+}
+
+// imageBlurhash 缩小到 32 像素以内后按 4x3 (竖图 3x4) 个分量编码
+func imageBlurhash(img image.Image) (string, error) {
+	img = imageutil.Fit(img, 32, 32)
+	b := img.Bounds()
+	x, y := 4, 3
+	if b.Dy() > b.Dx() {
+		x, y = 3, 4
+	}
+	return blurhash.Encode(img, x, y)
+}
+
+// storeThumbnails 将缩略图存到原图 key 旁边 (<原图名>_<宽>x<高><扩展名>)，失败时清理已写入的部分
+func storeThumbnails(ctx context.Context, key string, thumbs []imageThumbnail) ([]models.MediaThumbnail, error) {
+	base := strings.TrimSuffix(key, path.Ext(key))
+	var stored []models.MediaThumbnail
+	for _, t := range thumbs {
+		thumbKey := fmt.Sprintf("%s_%dx%d%s", base, t.width, t.height, imageutil.Ext(t.format))
+		if err := global.Storage.Put(ctx, thumbKey, bytes.NewReader(t.data), int64(len(t.data)), imageutil.ContentType(t.format)); err != nil {
+			deleteThumbnails(ctx, stored)
+			return nil, err
+		}
+		stored = append(stored, models.MediaThumbnail{
+			StorageKey: thumbKey,
+			MimeType:   imageutil.ContentType(t.format),
+			Width:      t.width,
+			Height:     t.height,
+		})
+	}
+	return stored, nil
+}
+
+func deleteThumbnails(ctx context.Context, thumbs []models.MediaThumbnail) {
+	for _, t := range thumbs {
+		global.Storage.Delete(ctx, t.StorageKey)
+	}
+}
@@ -0,0 +1,351 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"go-chat/internal/pkg/utils"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUploadNotFound     = errors.New("上传任务不存在或已过期")
+	ErrUploadTooMany      = errors.New("同时进行的上传任务过多，请先完成或取消之前的上传")
+	ErrUploadChunkIndex   = errors.New("分片序号无效")
+	ErrUploadChunkSize    = errors.New("分片大小与上传任务不符")
+	ErrUploadChecksum     = errors.New("分片校验失败，请重新上传该分片")
+	ErrUploadIncomplete   = errors.New("还有分片未上传")
+	ErrUploadBusy         = errors.New("上传任务正在合并中")
+	ErrUploadCompleted    = errors.New("上传任务已完成")
+	ErrUploadFileChecksum = errors.New("文件校验失败，请重新上传")
+)
+
+const (
+	defaultUploadChunkSize = 5 << 20
+	minUploadChunkSize     = 256 << 10
+	maxUploadChunkSize     = 16 << 20
+	// 未完成的任务超过该时间没有新分片即视为放弃 (media.upload_ttl_hours)
+	defaultUploadTTL = 24 * time.Hour
+	// 每个用户同时进行的上传任务上限，防止占用大量存储
+	maxPendingUploads = 10
+)
+
+func uploadTTL() time.Duration {
+	if h := global.Config.GetInt("media.upload_ttl_hours"); h > 0 {
+		return time.Duration(h) * time.Hour
+	}
+	return defaultUploadTTL
+}
+
+// uploadChunkSize 客户端期望的分片大小在允许范围内时采用，否则使用 media.chunk_size
+func uploadChunkSize(requested int64) int64 {
+	if requested >= minUploadChunkSize && requested <= maxUploadChunkSize {
+		return requested
+	}
+	if v := global.Config.GetInt64("media.chunk_size"); v >= minUploadChunkSize && v <= maxUploadChunkSize {
+		return v
+	}
+	return defaultUploadChunkSize
+}
+
+// chunkKey 分片在存储后端中的 key
+func chunkKey(up *models.MediaUpload, index int) string {
+	return fmt.Sprintf("uploads/%d/%s/%d", up.UserID, up.UploadID, index)
+}
+
+// chunkLength 第 index 片应有的字节数，最后一片为剩余部分
+func chunkLength(up *models.MediaUpload, index int) int64 {
+	if index == up.TotalChunks-1 {
+		return up.Size - int64(index)*up.ChunkSize
+	}
+	return up.ChunkSize
+}
+
+// InitMediaUpload 创建分片上传任务
+// 文件类型要等合并后才能识别，这里只按所有类型中最大的上限校验，合并时再按实际类型校验
+func InitMediaUpload(ctx context.Context, userID uint, req InitUploadRequest) (*UploadSessionDTO, error) {
+	if limit := MediaUploadMaxSize(); req.Size > limit {
+		return nil, &MediaTooLargeError{Limit: limit}
+	}
+
+	var pending int64
+	if err := global.DB.WithContext(ctx).Model(&models.MediaUpload{}).
+		Where("user_id = ? AND status <> ? AND expires_at > ?", userID, models.UploadCompleted, time.Now()).
+		Count(&pending).Error; err != nil {
+		return nil, err
+	}
+	if pending >= maxPendingUploads {
+		return nil, ErrUploadTooMany
+	}
+
+	uploadID, err := utils.RandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	chunkSize := uploadChunkSize(req.ChunkSize)
+	up := models.MediaUpload{
+		UploadID:    uploadID,
+		UserID:      userID,
+		FileName:    cleanFileName(req.FileName),
+		Size:        req.Size,
+		ChunkSize:   chunkSize,
+		TotalChunks: int((req.Size + chunkSize - 1) / chunkSize),
+		SHA256:      strings.ToLower(req.SHA256),
+		AsFile:      req.AsFile,
+		ExpiresAt:   time.Now().Add(uploadTTL()),
+	}
+	if err := global.DB.WithContext(ctx).Create(&up).Error; err != nil {
+		return nil, err
+	}
+	return ToUploadSessionDTO(&up, []int{}), nil
+}
+
+// loadUpload 读取当前用户未过期的上传任务
+func loadUpload(ctx context.Context, userID uint, uploadID string) (*models.MediaUpload, error) {
+	var up models.MediaUpload
+	err := global.DB.WithContext(ctx).
+		Where("upload_id = ? AND user_id = ? AND expires_at > ?", uploadID, userID, time.Now()).
+		First(&up).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &up, nil
+}
+
+// GetMediaUpload 查询上传进度，断点续传时只需上传 uploaded 以外的分片
+func GetMediaUpload(ctx context.Context, userID uint, uploadID string) (*UploadSessionDTO, error) {
+	up, err := loadUpload(ctx, userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	uploaded := []int{}
+	if err := global.DB.WithContext(ctx).Model(&models.MediaUploadChunk{}).
+		Where("upload_id = ?", up.ID).
+		Order("chunk_index").
+		Pluck("chunk_index", &uploaded).Error; err != nil {
+		return nil, err
+	}
+	return ToUploadSessionDTO(up, uploaded), nil
+}
+
+// UploadChunk 上传一个分片，checksum 为该分片的 SHA-256 (十六进制)
+// 同一分片可以重复上传，以最后一次为准
+func UploadChunk(ctx context.Context, userID uint, uploadID string, index int, r io.Reader, checksum string) error {
+	up, err := loadUpload(ctx, userID, uploadID)
+	if err != nil {
+		return err
+	}
+	switch up.Status {
+	case models.UploadAssembling:
+		return ErrUploadBusy
+	case models.UploadCompleted:
+		return ErrUploadCompleted
+	}
+	if index < 0 || index >= up.TotalChunks {
+		return ErrUploadChunkIndex
+	}
+
+	// 分片不大 (最多 16MB)，读入内存校验后再写入存储
+	want := chunkLength(up, index)
+	data, err := io.ReadAll(io.LimitReader(r, want+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != want {
+		return ErrUploadChunkSize
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	if !strings.EqualFold(digest, checksum) {
+		return ErrUploadChecksum
+	}
+
+	if err := global.Storage.Put(ctx, chunkKey(up, index), bytes.NewReader(data), want, "application/octet-stream"); err != nil {
+		return err
+	}
+
+	chunk := models.MediaUploadChunk{UploadID: up.ID, ChunkIndex: index, Size: want, SHA256: digest}
+	db := global.DB.WithContext(ctx)
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "upload_id"}, {Name: "chunk_index"}},
+		DoUpdates: clause.AssignmentColumns([]string{"size", "sha256"}),
+	}).Create(&chunk).Error; err != nil {
+		return err
+	}
+	// 还在上传的任务顺延过期时间
+	return db.Model(up).Update("expires_at", time.Now().Add(uploadTTL())).Error
+}
+
+// CompleteMediaUpload 合并所有分片并按普通上传的流程生成附件
+// 客户端没收到响应而重试时，已完成的任务直接返回之前生成的附件
+func CompleteMediaUpload(ctx context.Context, userID uint, uploadID string) (*MediaDTO, error) {
+	up, err := loadUpload(ctx, userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	switch up.Status {
+	case models.UploadAssembling:
+		return nil, ErrUploadBusy
+	case models.UploadCompleted:
+		media, err := GetMedia(ctx, up.MediaID)
+		if err != nil {
+			return nil, err
+		}
+		dto := ToMediaDTO(media)
+		return &dto, nil
+	}
+
+	db := global.DB.WithContext(ctx)
+	var chunks []models.MediaUploadChunk
+	if err := db.Where("upload_id = ?", up.ID).Order("chunk_index").Find(&chunks).Error; err != nil {
+		return nil, err
+	}
+	if len(chunks) != up.TotalChunks {
+		return nil, ErrUploadIncomplete
+	}
+
+	// 抢占合并权，防止并发的 complete 重复合并
+	result := db.Model(&models.MediaUpload{}).
+		Where("id = ? AND status = ?", up.ID, models.UploadPending).
+		Updates(map[string]interface{}{"status": models.UploadAssembling, "expires_at": time.Now().Add(uploadTTL())})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrUploadBusy
+	}
+
+	dto, err := assembleUpload(ctx, up, chunks)
+	if err != nil {
+		// 退回上传中，客户端可以补传分片后重试或取消
+		if err := db.Model(up).Update("status", models.UploadPending).Error; err != nil {
+			global.Log.Error("reset media upload status failed", zap.String("upload_id", up.UploadID), zap.Error(err))
+		}
+		return nil, err
+	}
+
+	if err := db.Model(up).Updates(map[string]interface{}{"status": models.UploadCompleted, "media_id": dto.ID}).Error; err != nil {
+		return nil, err
+	}
+	if err := deleteUploadChunks(ctx, up); err != nil {
+		global.Log.Warn("delete upload chunks failed", zap.String("upload_id", up.UploadID), zap.Error(err))
+	}
+	return dto, nil
+}
+
+// assembleUpload 按顺序把分片写入临时文件，逐片复核摘要后交给 UploadMedia
+func assembleUpload(ctx context.Context, up *models.MediaUpload, chunks []models.MediaUploadChunk) (*MediaDTO, error) {
+	tmp, err := os.CreateTemp("", "go-chat-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	fileHash := sha256.New()
+	for _, chunk := range chunks {
+		rc, err := global.Storage.Get(ctx, chunkKey(up, chunk.ChunkIndex))
+		if err != nil {
+			return nil, fmt.Errorf("read chunk %d: %w", chunk.ChunkIndex, err)
+		}
+		chunkHash := sha256.New()
+		n, err := io.Copy(io.MultiWriter(tmp, fileHash, chunkHash), rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if n != chunk.Size || hex.EncodeToString(chunkHash.Sum(nil)) != chunk.SHA256 {
+			// 存储中的分片已损坏，删除记录让客户端重新上传该分片
+			global.DB.WithContext(ctx).Delete(&chunk)
+			return nil, ErrUploadIncomplete
+		}
+	}
+	if up.SHA256 != "" && hex.EncodeToString(fileHash.Sum(nil)) != up.SHA256 {
+		return nil, ErrUploadFileChecksum
+	}
+
+	return UploadMedia(ctx, up.UserID, tmp, up.Size, up.FileName, up.AsFile)
+}
+
+// AbortMediaUpload 取消上传任务并删除已上传的分片
+func AbortMediaUpload(ctx context.Context, userID uint, uploadID string) error {
+	up, err := loadUpload(ctx, userID, uploadID)
+	if err != nil {
+		return err
+	}
+	if up.Status == models.UploadAssembling {
+		return ErrUploadBusy
+	}
+	return cleanupUpload(ctx, up)
+}
+
+// deleteUploadChunks 删除存储中的分片和分片记录
+// 按序号逐个删除，写入存储后没来得及记录的分片也能删掉
+func deleteUploadChunks(ctx context.Context, up *models.MediaUpload) error {
+	for i := 0; i < up.TotalChunks; i++ {
+		if err := global.Storage.Delete(ctx, chunkKey(up, i)); err != nil {
+			return err
+		}
+	}
+	return global.DB.WithContext(ctx).Where("upload_id = ?", up.ID).Delete(&models.MediaUploadChunk{}).Error
+}
+
+func cleanupUpload(ctx context.Context, up *models.MediaUpload) error {
+	if up.Status != models.UploadCompleted {
+		if err := deleteUploadChunks(ctx, up); err != nil {
+			return err
+		}
+	}
+	return global.DB.WithContext(ctx).Unscoped().Delete(up).Error
+}
+
+// StartMediaUploadJanitor 定时清理过期的上传任务 (放弃的任务连同分片一起删除) (在 main.go 中调用)
+func StartMediaUploadJanitor() {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			cleanupExpiredUploads()
+			<-ticker.C
+		}
+	}()
+}
+
+func cleanupExpiredUploads() {
+	ctx := context.Background()
+	for {
+		var expired []models.MediaUpload
+		if err := global.DB.Where("expires_at <= ?", time.Now()).Limit(100).Find(&expired).Error; err != nil {
+			global.Log.Error("query expired media uploads failed", zap.Error(err))
+			return
+		}
+		for i := range expired {
+			if err := cleanupUpload(ctx, &expired[i]); err != nil {
+				// 存储暂时不可用时下一轮再试，本轮不再继续
+				global.Log.Error("cleanup media upload failed", zap.String("upload_id", expired[i].UploadID), zap.Error(err))
+				return
+			}
+		}
+		if len(expired) > 0 {
+			global.Log.Info("expired media uploads cleaned", zap.Int("count", len(expired)))
+		}
+		if len(expired) < 100 {
+			return
+		}
+	}
+}
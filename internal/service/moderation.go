@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"go-chat/internal/pkg/moderation"
+	"go-chat/internal/pkg/protocol"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrModerationHitNotFound = errors.New("审核记录不存在")
+	ErrModerationNotPending  = errors.New("该记录不在待审核状态")
+)
+
+// 各判定未配置原因时告知发送者的默认说明
+var defaultModerationReasons = map[moderation.Action]string{
+	moderation.Mask:   "消息中的部分内容已被屏蔽",
+	moderation.Review: "消息正在审核，通过后对方才能收到",
+	moderation.Reject: "消息包含违规内容，无法发送",
+}
+
+// 为 nil 表示未开启内容审核
+var moderationPipeline *moderation.Pipeline
+
+// keywordRuleConfig moderation.keywords 中的一条规则
+type keywordRuleConfig struct {
+	Action   string
+	Reason   string
+	Words    []string
+	Patterns []string
+}
+
+// InitModeration 按配置组装审核过滤器 (在 main.go 中调用)，规则有误时直接退出，避免带着失效的规则运行
+func InitModeration() {
+	if !global.Config.GetBool("moderation.enabled") {
+		return
+	}
+	pipeline, err := buildModerationPipeline()
+	if err != nil {
+		global.Log.Fatal("moderation config invalid", zap.Error(err))
+	}
+	moderationPipeline = pipeline
+}
+
+func buildModerationPipeline() (*moderation.Pipeline, error) {
+	var filters []moderation.Filter
+
+	// 1. 关键词/正则
+	var ruleConfigs []keywordRuleConfig
+	if err := global.Config.UnmarshalKey("moderation.keywords", &ruleConfigs); err != nil {
+		return nil, err
+	}
+	rules := make([]moderation.KeywordRule, 0, len(ruleConfigs))
+	for _, rc := range ruleConfigs {
+		action, err := moderation.ParseAction(rc.Action)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, moderation.KeywordRule{Action: action, Reason: rc.Reason, Words: rc.Words, Patterns: rc.Patterns})
+	}
+	if len(rules) > 0 {
+		keyword, err := moderation.NewKeywordFilter(rules)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, keyword)
+	}
+
+	// 2. 垃圾消息
+	if global.Config.IsSet("moderation.spam") {
+		action, err := configAction("moderation.spam.action", moderation.Review)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, moderation.NewSpamFilter(moderation.SpamOptions{
+			Action:          action,
+			Reason:          global.Config.GetString("moderation.spam.reason"),
+			MaxLinks:        global.Config.GetInt("moderation.spam.max_links"),
+			MaxRepeat:       global.Config.GetInt("moderation.spam.max_repeat"),
+			DuplicateLimit:  global.Config.GetInt("moderation.spam.duplicate_limit"),
+			DuplicateWindow: time.Duration(global.Config.GetInt("moderation.spam.duplicate_window_seconds")) * time.Second,
+			Counter:         redisCounter{},
+		}))
+	}
+
+	// 3. 外部分类服务，放在最后，前面已经拒绝的消息不必再调用
+	if u := global.Config.GetString("moderation.classifier.url"); u != "" {
+		failAction, err := configAction("moderation.classifier.fail_action", moderation.Allow)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, moderation.NewClassifierFilter(moderation.ClassifierOptions{
+			URL:        u,
+			Token:      global.Config.GetString("moderation.classifier.token"),
+			Timeout:    time.Duration(global.Config.GetInt("moderation.classifier.timeout_ms")) * time.Millisecond,
+			FailAction: failAction,
+		}))
+	}
+
+	return moderation.NewPipeline(filters...), nil
+}
+
+// configAction 读取配置中的判定名称，未配置时使用默认值
+func configAction(key string, def moderation.Action) (moderation.Action, error) {
+	s := global.Config.GetString(key)
+	if s == "" {
+		return def, nil
+	}
+	return moderation.ParseAction(s)
+}
+
+// redisCounter 基于 Redis 的计数器，多实例共享
+type redisCounter struct{}
+
+func (redisCounter) Incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	key = moderationCounterKey(key)
+	n, err := global.RDB.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if n == 1 {
+		global.RDB.Expire(ctx, key, window)
+	}
+	return n, nil
+}
+
+// moderateMessage 审核消息内容，未开启审核或内容为空时直接放行
+func moderateMessage(ctx context.Context, fromID, toID uint, content string) moderation.Result {
+	if moderationPipeline == nil || content == "" {
+		return moderation.Result{Action: moderation.Allow, Content: content}
+	}
+	res, err := moderationPipeline.Check(ctx, moderation.Input{UserID: fromID, TargetID: toID, Content: content})
+	if err != nil {
+		global.Log.Warn("moderation filter failed", zap.Uint("user_id", fromID), zap.Error(err))
+	}
+	if res.Action != moderation.Allow && res.Reason == "" {
+		res.Reason = defaultModerationReasons[res.Action]
+	}
+	return res
+}
+
+// recordModerationHit 记录命中，msg 为入库的消息 (被拒绝发送时为 nil)
+// 记录失败不影响消息本身的处理，只记日志
+func recordModerationHit(ctx context.Context, fromID, toID uint, content string, msg *models.Message, res moderation.Result) {
+	hit := models.ModerationHit{
+		UserID:   fromID,
+		TargetID: toID,
+		Content:  content,
+		Action:   res.Action.String(),
+		Reason:   res.Reason,
+	}
+	if msg != nil {
+		hit.MessageID = msg.ID
+	}
+	if res.Action == moderation.Review {
+		hit.ReviewStatus = models.ReviewPending
+	}
+	for _, h := range res.Hits {
+		hit.Matches = append(hit.Matches, models.ModerationMatch{
+			Filter: h.Filter,
+			Action: h.Action.String(),
+			Reason: h.Reason,
+			Match:  h.Match,
+		})
+	}
+	if err := global.DB.WithContext(ctx).Create(&hit).Error; err != nil {
+		global.Log.Error("save moderation hit failed", zap.Uint("user_id", fromID), zap.Error(err))
+	}
+}
+
+// IsAdmin 是否为管理员 (admin.user_ids)
+func IsAdmin(userID uint) bool {
+	for _, id := range global.Config.GetIntSlice("admin.user_ids") {
+		if id > 0 && uint(id) == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// ListModerationHits 管理员查看审核记录 (时间倒序)
+func ListModerationHits(ctx context.Context, req ModerationHitsReq) (*ModerationHitPageDTO, error) {
+	query := global.DB.WithContext(ctx).Model(&models.ModerationHit{})
+	if req.ReviewStatus != nil {
+		query = query.Where("review_status = ?", *req.ReviewStatus)
+	}
+	if req.Action != "" {
+		query = query.Where("action = ?", req.Action)
+	}
+	if req.UserID != 0 {
+		query = query.Where("user_id = ?", req.UserID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+	var hits []models.ModerationHit
+	err := query.Order("id desc").Offset((req.Page - 1) * req.PageSize).Limit(req.PageSize).Find(&hits).Error
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]ModerationHitDTO, len(hits))
+	for i := range hits {
+		list[i] = ToModerationHitDTO(&hits[i])
+	}
+	return &ModerationHitPageDTO{List: list, Total: total, Page: req.Page, PageSize: req.PageSize}, nil
+}
+
+// ApproveModerationHit 审核通过：消息恢复正常并投递给接收者
+func ApproveModerationHit(ctx context.Context, adminID, hitID uint) error {
+	var msg models.Message
+	err := reviewModerationHit(ctx, adminID, hitID, models.ReviewApproved, "", models.MessageNormal, &msg)
+	if err != nil {
+		return err
+	}
+
+	global.RDB.Del(ctx, generateKey(msg.FromUserID, msg.ToUserID))
+	indexMessage(ctx, &msg)
+
+	// 审核期间任意一方拉黑了另一方时不再推送，与发送时的校验一致
+	blocked, err := IsBlocked(ctx, msg.FromUserID, msg.ToUserID)
+	if err != nil {
+		global.Log.Error("check block before delivery failed", zap.Uint("msg_id", msg.ID), zap.Error(err))
+		blocked = true
+	}
+	if !blocked {
+		if !msg.IsRequest {
+			PushMessageToUser(msg)
+		}
+		unfurlMessage(msg)
+	}
+	PushEvent(msg.FromUserID, protocol.EventMessageApproved, ModerationEventDTO{MsgID: msg.ID})
+	return nil
+}
+
+// RejectModerationHit 审核未通过：消息不再投递，并告知发送者
+func RejectModerationHit(ctx context.Context, adminID, hitID uint, note string) error {
+	var msg models.Message
+	err := reviewModerationHit(ctx, adminID, hitID, models.ReviewRejected, note, models.MessageRemoved, &msg)
+	if err != nil {
+		return err
+	}
+
+	reason := note
+	if reason == "" {
+		reason = defaultModerationReasons[moderation.Reject]
+	}
+	PushEvent(msg.FromUserID, protocol.EventMessageRemoved, ModerationEventDTO{MsgID: msg.ID, Reason: reason})
+	return nil
+}
+
+// reviewModerationHit 在事务中更新审核记录和对应消息的状态
+// 按 review_status 条件更新，多个管理员同时处理时只有一个生效
+func reviewModerationHit(ctx context.Context, adminID, hitID uint, reviewStatus int, note string, msgStatus int, msg *models.Message) error {
+	return global.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var hit models.ModerationHit
+		if err := tx.First(&hit, hitID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrModerationHitNotFound
+			}
+			return err
+		}
+
+		now := time.Now()
+		res := tx.Model(&models.ModerationHit{}).
+			Where("id = ? AND review_status = ?", hitID, models.ReviewPending).
+			Updates(map[string]interface{}{
+				"review_status": reviewStatus,
+				"reviewer_id":   adminID,
+				"review_note":   note,
+				"reviewed_at":   now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrModerationNotPending
+		}
+
+		if err := tx.First(msg, hit.MessageID).Error; err != nil {
+			return err
+		}
+		msg.Status = msgStatus
+		return tx.Model(msg).Update("status", msgStatus).Error
+	})
+}
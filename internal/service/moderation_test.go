@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"go-chat/internal/pkg/protocol"
+	"testing"
+)
+
+// connectTestClient 直接登记一个连接，推送给 userID 的数据写入返回的管道
+func connectTestClient(t *testing.T, userID uint) chan []byte {
+	t.Helper()
+	c := &Client{
+		UserID:    userID,
+		SessionID: "test",
+		Send:      make(chan []byte, 8),
+		Done:      make(chan struct{}),
+		Codec:     protocol.CodecFor(""),
+	}
+	Manager.Lock.Lock()
+	Manager.Clients[userID] = map[string]*Client{c.SessionID: c}
+	Manager.Lock.Unlock()
+	t.Cleanup(func() {
+		Manager.Lock.Lock()
+		delete(Manager.Clients, userID)
+		Manager.Lock.Unlock()
+	})
+	return c.Send
+}
+
+// 审核通过时接收者已拉黑发送者，消息不再推送给接收者
+func TestApproveModerationHitBlocked(t *testing.T) {
+	tests := []struct {
+		name      string
+		block     bool
+		wantFrame int
+	}{
+		{"delivered", false, 1},
+		{"recipient blocked sender", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestEnv(t, &models.Relation{}, &models.FriendRequest{}, &models.Message{}, &models.ModerationHit{})
+			ctx := context.Background()
+			ids := createTestUsers(t, 3)
+			sender, recipient, admin := ids[0], ids[1], ids[2]
+			for _, pair := range [][2]uint{{sender, recipient}, {recipient, sender}} {
+				if err := global.DB.Create(&models.Relation{OwnerID: pair[0], TargetID: pair[1], Type: 1}).Error; err != nil {
+					t.Fatalf("create relation: %v", err)
+				}
+			}
+
+			msg := models.Message{FromUserID: sender, ToUserID: recipient, Type: protocol.TypeSingleMsg,
+				Content: "待审核的消息", Status: models.MessageHeld}
+			if err := global.DB.Create(&msg).Error; err != nil {
+				t.Fatalf("create message: %v", err)
+			}
+			hit := models.ModerationHit{UserID: sender, TargetID: recipient, MessageID: msg.ID,
+				Action: "review", ReviewStatus: models.ReviewPending}
+			if err := global.DB.Create(&hit).Error; err != nil {
+				t.Fatalf("create hit: %v", err)
+			}
+
+			if tt.block {
+				if err := BlockUser(ctx, recipient, BlockUserReq{TargetID: sender}); err != nil {
+					t.Fatalf("block: %v", err)
+				}
+			}
+			frames := connectTestClient(t, recipient)
+
+			if err := ApproveModerationHit(ctx, admin, hit.ID); err != nil {
+				t.Fatalf("approve: %v", err)
+			}
+			if n := len(frames); n != tt.wantFrame {
+				t.Fatalf("recipient got %d frames, want %d", n, tt.wantFrame)
+			}
+		})
+	}
+}
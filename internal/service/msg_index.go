@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 消息索引实现 (search.message_index)
+const (
+	MessageIndexMySQL = "mysql" // MySQL FULLTEXT 索引 (ngram 分词，支持中文)
+	MessageIndexLike  = "like"  // LIKE 模糊匹配，无需额外索引，适合数据量小或非 MySQL 环境
+)
+
+// MessageIndex 聊天记录全文索引
+// 消息入库后调用 Index 更新索引，Search 在调用方给定的会话范围内检索
+// 内置的 mysql、like 两种实现直接查询 messages 表，不需要维护额外的索引数据；
+// 接入 bleve 等独立维护数据的索引时，实现 Index 即可跟上新消息
+type MessageIndex interface {
+	// Setup 启动时准备索引所需的结构 (建索引等)
+	Setup(ctx context.Context) error
+	// Index 消息对接收方可见时调用，调用约定：
+	//   - msg 已提交到 messages 表且状态正常，ID、CreatedAt 已填充，实现不得修改 msg
+	//   - 每条消息调用一次：直接入库或 Kafka 消费入库成功后；转人工审核的消息在审核通过时，未通过的不会传入
+	//   - 媒体消息也会传入，是否索引其说明文字由实现决定 (Search 只需返回文本消息)
+	//   - 在入库的协程中同步调用，耗时的操作应由实现自行异步处理
+	//   - 返回的错误只记日志，不会重试，需要完整性的实现应在 Setup 中从 messages 表补齐缺失的数据
+	Index(ctx context.Context, msg *models.Message) error
+	// Search 按条件检索，返回当前页的消息和总数
+	Search(ctx context.Context, q MessageQuery) ([]models.Message, int64, error)
+}
+
+// MessageQuery 检索条件，会话范围由 service 层根据请求者计算好后传入
+type MessageQuery struct {
+	Keyword  string
+	UserID   uint      // 请求者，单聊只检索与他相关的消息
+	PeerID   uint      // 只检索与该用户的单聊
+	GroupID  uint      // 只检索该群的群聊 (调用方需校验成员身份)
+	GroupIDs []uint    // 请求者所在的群 (为空则不检索群聊)
+	SenderID uint      // 只检索该用户发送的消息
+	Start    time.Time // 起始时间 (含)
+	End      time.Time // 结束时间 (不含)
+	Offset   int
+	Limit    int
+}
+
+var (
+	msgIndex     MessageIndex
+	msgIndexOnce sync.Once
+)
+
+// messageIndex 按配置选择索引实现，首次使用时初始化
+func messageIndex() MessageIndex {
+	msgIndexOnce.Do(func() {
+		switch global.Config.GetString("search.message_index") {
+		case MessageIndexLike:
+			msgIndex = likeMessageIndex{}
+		default:
+			msgIndex = mysqlMessageIndex{}
+		}
+	})
+	return msgIndex
+}
+
+// InitMessageIndex 准备消息索引 (在 main.go 中 AutoMigrate 之后、开始处理请求之前调用)
+// 准备失败 (如数据库不支持 FULLTEXT 或 ngram) 时退回 like 实现，搜索变慢但仍可用
+func InitMessageIndex() {
+	if err := messageIndex().Setup(context.Background()); err != nil {
+		global.Log.Warn("message index setup failed, falling back to like index", zap.Error(err))
+		msgIndex = likeMessageIndex{}
+	}
+}
+
+// indexMessage 消息入库后更新索引，失败只记日志，不影响消息投递
+func indexMessage(ctx context.Context, msg *models.Message) {
+	if err := messageIndex().Index(ctx, msg); err != nil {
+		global.Log.Error("index message failed", zap.Uint("msg_id", msg.ID), zap.Error(err))
+	}
+}
+
+// scopeQuery 拼出会话范围与过滤条件，各实现共用
+func scopeQuery(db *gorm.DB, q MessageQuery) *gorm.DB {
+	// 会话范围：与我相关的单聊 + 我所在群的群聊
+	scope := db.Where("type = ? AND (from_user_id = ? OR to_user_id = ?)", 2, q.UserID, q.UserID)
+	if len(q.GroupIDs) > 0 {
+		scope = scope.Or("type = ? AND to_user_id IN ?", 3, q.GroupIDs)
+	}
+	query := db.Model(&models.Message{}).Where(scope)
+
+	if q.PeerID != 0 {
+		query = query.Where("type = ? AND ((from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?))",
+			2, q.UserID, q.PeerID, q.PeerID, q.UserID)
+	}
+	if q.GroupID != 0 {
+		query = query.Where("type = ? AND to_user_id = ?", 3, q.GroupID)
+	}
+	if q.SenderID != 0 {
+		query = query.Where("from_user_id = ?", q.SenderID)
+	}
+	if !q.Start.IsZero() {
+		query = query.Where("created_at >= ?", q.Start)
+	}
+	if !q.End.IsZero() {
+		query = query.Where("created_at < ?", q.End)
+	}
+	// 只检索正常状态的文本消息 (旧数据 media 可能为 0)
+	return query.Where("media IN ? AND status = ?", []int{0, 1}, models.MessageNormal)
+}
+
+// pageQuery 统计总数并取出当前页 (时间倒序)
+func pageQuery(query *gorm.DB, q MessageQuery) ([]models.Message, int64, error) {
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var messages []models.Message
+	err := query.Order("created_at desc").Offset(q.Offset).Limit(q.Limit).Find(&messages).Error
+	return messages, total, err
+}
+
+// mysqlMessageIndex 基于 MySQL FULLTEXT 的实现
+// 索引由 MySQL 在写入时自动维护，Index 无需额外操作
+type mysqlMessageIndex struct{}
+
+const mysqlFulltextIndexName = "ft_messages_content"
+
+func (mysqlMessageIndex) Setup(ctx context.Context) error {
+	db := global.DB.WithContext(ctx)
+
+	var count int64
+	if err := db.Raw("SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?",
+		"messages", mysqlFulltextIndexName).Scan(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	// ngram 分词器才能正确切分中文
+	global.Log.Info("creating fulltext index on messages.content")
+	return db.Exec("ALTER TABLE messages ADD FULLTEXT INDEX " + mysqlFulltextIndexName + " (content) WITH PARSER ngram").Error
+}
+
+// Index FULLTEXT 索引随 messages 表的写入自动更新，这里无需操作
+func (mysqlMessageIndex) Index(ctx context.Context, msg *models.Message) error {
+	return nil
+}
+
+func (mysqlMessageIndex) Search(ctx context.Context, q MessageQuery) ([]models.Message, int64, error) {
+	query := scopeQuery(global.DB.WithContext(ctx), q).
+		Where("MATCH(content) AGAINST(? IN BOOLEAN MODE)", booleanModeQuery(q.Keyword))
+	return pageQuery(query, q)
+}
+
+// booleanModeQuery 把用户输入转换为 BOOLEAN MODE 查询：每个词都必须出现
+// 去掉输入中的布尔运算符，防止用户构造查询语法
+func booleanModeQuery(keyword string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`+-<>()~*"@`, r) {
+			return ' '
+		}
+		return r
+	}, keyword)
+
+	terms := strings.Fields(cleaned)
+	for i, t := range terms {
+		terms[i] = `+"` + t + `"`
+	}
+	return strings.Join(terms, " ")
+}
+
+// likeMessageIndex 基于 LIKE 的实现，每个词都必须出现
+type likeMessageIndex struct{}
+
+func (likeMessageIndex) Setup(ctx context.Context) error {
+	return nil
+}
+
+// Index 直接查询 messages 表，没有需要维护的索引
+func (likeMessageIndex) Index(ctx context.Context, msg *models.Message) error {
+	return nil
+}
+
+func (likeMessageIndex) Search(ctx context.Context, q MessageQuery) ([]models.Message, int64, error) {
+	query := scopeQuery(global.DB.WithContext(ctx), q)
+	for _, term := range strings.Fields(q.Keyword) {
+		query = query.Where("content LIKE ?", "%"+likeEscaper.Replace(term)+"%")
+	}
+	return pageQuery(query, q)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var ErrNotGroupMember = errors.New("你不在该群中")
+
+// SearchMessages 在我参与的会话中检索聊天记录
+func SearchMessages(ctx context.Context, userID uint, req SearchMessagesReq) (*MessageSearchPageDTO, error) {
+	q := MessageQuery{
+		Keyword:  strings.TrimSpace(req.Keyword),
+		UserID:   userID,
+		PeerID:   req.PeerID,
+		GroupID:  req.GroupID,
+		SenderID: req.SenderID,
+		Offset:   (req.Page - 1) * req.PageSize,
+		Limit:    req.PageSize,
+	}
+	if req.Start > 0 {
+		q.Start = time.UnixMilli(req.Start)
+	}
+	if req.End > 0 {
+		q.End = time.UnixMilli(req.End)
+	}
+
+	page := &MessageSearchPageDTO{List: []MessageSearchHitDTO{}, Page: req.Page, PageSize: req.PageSize}
+	if q.Keyword == "" {
+		return page, nil
+	}
+
+	// 群聊范围：指定了群则只查该群 (需是成员)，否则查我所在的全部群；指定了单聊对象则不查群聊
+	if req.PeerID == 0 {
+		var groupIDs []uint
+		if err := global.DB.WithContext(ctx).Model(&models.GroupMember{}).
+			Where("user_id = ?", userID).
+			Pluck("group_id", &groupIDs).Error; err != nil {
+			return nil, err
+		}
+		if req.GroupID != 0 {
+			if !containsID(groupIDs, req.GroupID) {
+				return nil, ErrNotGroupMember
+			}
+			groupIDs = []uint{req.GroupID}
+		}
+		q.GroupIDs = groupIDs
+	}
+
+	messages, total, err := messageIndex().Search(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	terms := strings.Fields(q.Keyword)
+	hits := make([]MessageSearchHitDTO, 0, len(messages))
+	for i := range messages {
+		hits = append(hits, MessageSearchHitDTO{
+			Message:   ToMessageDTO(&messages[i]),
+			Highlight: highlight(messages[i].Content, terms),
+		})
+	}
+
+	page.List = hits
+	page.Total = total
+	return page, nil
+}
+
+// highlight 对内容做 HTML 转义后用 <em> 标出命中的关键词 (不区分大小写)
+func highlight(content string, terms []string) string {
+	if len(terms) == 0 {
+		return html.EscapeString(content)
+	}
+
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	re, err := regexp.Compile("(?i)" + strings.Join(quoted, "|"))
+	if err != nil {
+		return html.EscapeString(content)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringIndex(content, -1) {
+		b.WriteString(html.EscapeString(content[last:loc[0]]))
+		b.WriteString("<em>")
+		b.WriteString(html.EscapeString(content[loc[0]:loc[1]]))
+		b.WriteString("</em>")
+		last = loc[1]
+	}
+	b.WriteString(html.EscapeString(content[last:]))
+	return b.String()
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
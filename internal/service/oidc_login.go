@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"go-chat/internal/pkg/oidc"
+	"go-chat/internal/pkg/utils"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOIDCDisabled        = errors.New("未开启单点登录")
+	ErrOIDCUnavailable     = errors.New("身份提供方暂不可用，请稍后再试")
+	ErrOIDCState           = errors.New("登录请求无效或已过期，请重新登录")
+	ErrOIDCFailed          = errors.New("单点登录失败")
+	ErrOIDCEmailUnverified = errors.New("身份提供方未返回已验证的邮箱")
+	ErrOIDCSignupDenied    = errors.New("该账号不允许通过单点登录注册，请联系管理员")
+	ErrOIDCEmailConflict   = errors.New("该邮箱对应多个账号，请联系管理员")
+)
+
+// oidcStateTTL 从跳转到身份提供方到回调的最长时间
+const oidcStateTTL = 10 * time.Minute
+
+// oidcLoginState 发起登录时保存在 Redis 中，回调时取出校验
+type oidcLoginState struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+var (
+	oidcProviderMu sync.Mutex
+	oidcProvider   *oidc.Provider
+)
+
+// getOIDCProvider 首次使用时拉取发现文档，成功后缓存；失败不缓存，下次请求重试
+func getOIDCProvider(ctx context.Context) (*oidc.Provider, error) {
+	if !global.Config.GetBool("oidc.enabled") {
+		return nil, ErrOIDCDisabled
+	}
+
+	oidcProviderMu.Lock()
+	defer oidcProviderMu.Unlock()
+	if oidcProvider != nil {
+		return oidcProvider, nil
+	}
+
+	p, err := oidc.NewProvider(ctx, oidc.Config{
+		Issuer:       global.Config.GetString("oidc.issuer"),
+		ClientID:     global.Config.GetString("oidc.client_id"),
+		ClientSecret: global.Config.GetString("oidc.client_secret"),
+		RedirectURL:  global.Config.GetString("oidc.redirect_url"),
+		Scopes:       global.Config.GetStringSlice("oidc.scopes"),
+	}, nil)
+	if err != nil {
+		global.Log.Error("oidc provider init failed", zap.Error(err))
+		return nil, ErrOIDCUnavailable
+	}
+	oidcProvider = p
+	return p, nil
+}
+
+// OIDCLoginURL 生成跳转到身份提供方的登录地址
+func OIDCLoginURL(ctx context.Context) (string, error) {
+	p, err := getOIDCProvider(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := utils.RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := utils.RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	data, _ := json.Marshal(oidcLoginState{Nonce: nonce, Verifier: verifier})
+	if err := global.RDB.Set(ctx, oidcStateKey(state), data, oidcStateTTL).Err(); err != nil {
+		return "", err
+	}
+	return p.AuthCodeURL(state, nonce, verifier), nil
+}
+
+// OIDCCallback 处理身份提供方的回调：校验 state，用授权码换取 ID Token，找到或创建本地用户后签发 Token
+// 两步验证由身份提供方负责，这里不再要求
+func OIDCCallback(ctx context.Context, state, code string, device DeviceInfo) (*LoginResponseDTO, error) {
+	p, err := getOIDCProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if state == "" || code == "" {
+		return nil, ErrOIDCState
+	}
+
+	// state 只能使用一次
+	data, err := global.RDB.GetDel(ctx, oidcStateKey(state)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrOIDCState
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved oidcLoginState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, ErrOIDCState
+	}
+
+	rawIDToken, err := p.Exchange(ctx, code, saved.Verifier)
+	if err != nil {
+		global.Log.Warn("oidc code exchange failed", zap.Error(err))
+		return nil, ErrOIDCFailed
+	}
+	claims, err := p.VerifyIDToken(ctx, rawIDToken, saved.Nonce)
+	if err != nil {
+		global.Log.Warn("oidc id token rejected", zap.Error(err))
+		return nil, ErrOIDCFailed
+	}
+
+	user, err := findOrCreateOIDCUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status == 2 {
+		auditLoginFailure(user.Username, user.ID, device, LoginFailDisabled)
+		return nil, ErrUserDisabled
+	}
+	return completeLogin(ctx, *user, device)
+}
+
+// findOrCreateOIDCUser 依次按已绑定身份、已验证邮箱查找本地用户，都没有时按配置自动注册
+func findOrCreateOIDCUser(ctx context.Context, claims *oidc.Claims) (*models.User, error) {
+	db := global.DB.WithContext(ctx)
+	provider := strings.TrimRight(claims.Issuer, "/")
+
+	user, err := findUserByIdentity(ctx, provider, claims.Subject)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, err
+	}
+
+	// 只按双方都已验证的邮箱绑定，避免有人注册一个未验证的同名邮箱来接管账号
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	if email == "" || !claims.EmailVerified {
+		return nil, ErrOIDCEmailUnverified
+	}
+	identity := models.UserIdentity{Provider: provider, Subject: claims.Subject, Email: email}
+
+	var matched []models.User
+	if err := db.Where("email = ? AND email_verified = ?", email, true).Limit(2).Find(&matched).Error; err != nil {
+		return nil, err
+	}
+	switch len(matched) {
+	case 1:
+		identity.UserID = matched[0].ID
+		if err := db.Create(&identity).Error; err != nil {
+			return retryIdentityLookup(ctx, provider, claims.Subject, err)
+		}
+		return &matched[0], nil
+	case 2:
+		return nil, ErrOIDCEmailConflict
+	}
+
+	if !oidcSignupAllowed(email) {
+		return nil, ErrOIDCSignupDenied
+	}
+
+	// 随机密码，该用户只能通过单点登录或找回密码登录
+	password, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hashPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	username, err := oidcUsername(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	nickname := truncate(strings.TrimSpace(claims.Name), 64)
+	if nickname == "" {
+		nickname = username
+	}
+	newUser := models.User{
+		Username:      username,
+		Password:      string(hashPassword),
+		Nickname:      nickname,
+		Email:         email,
+		EmailVerified: true,
+		Status:        1,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newUser).Error; err != nil {
+			return err
+		}
+		identity.UserID = newUser.ID
+		return tx.Create(&identity).Error
+	})
+	if err != nil {
+		return retryIdentityLookup(ctx, provider, claims.Subject, err)
+	}
+	return &newUser, nil
+}
+
+func findUserByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	var identity models.UserIdentity
+	err := global.DB.WithContext(ctx).
+		Where("provider = ? AND subject = ?", provider, subject).
+		First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	if err := global.DB.WithContext(ctx).First(&user, identity.UserID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// retryIdentityLookup 同一账号并发回调时，另一个请求可能已先完成绑定
+func retryIdentityLookup(ctx context.Context, provider, subject string, cause error) (*models.User, error) {
+	if user, err := findUserByIdentity(ctx, provider, subject); err == nil {
+		return user, nil
+	}
+	return nil, cause
+}
+
+// oidcSignupAllowed 是否允许自动注册：需开启 allow_signup，配置了 allowed_domains 时邮箱域名必须在其中
+func oidcSignupAllowed(email string) bool {
+	if !global.Config.GetBool("oidc.allow_signup") {
+		return false
+	}
+	domains := global.Config.GetStringSlice("oidc.allowed_domains")
+	if len(domains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, d := range domains {
+		if strings.EqualFold(strings.TrimSpace(d), domain) {
+			return true
+		}
+	}
+	return false
+}
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// oidcUsername 由 preferred_username 或邮箱前缀生成一个未被占用的用户名
+func oidcUsername(ctx context.Context, claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if at := strings.Index(base, "@"); at >= 0 {
+		base = base[:at]
+	}
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = truncate(usernameInvalidChars.ReplaceAllString(base, ""), 48)
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		var count int64
+		if err := global.DB.WithContext(ctx).Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		suffix, err := utils.RandomToken(3)
+		if err != nil {
+			return "", err
+		}
+		candidate = base + "_" + suffix
+	}
+	return "", ErrOccupiedUsername
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"go-chat/internal/pkg/jwtkeys"
+	"go-chat/internal/pkg/oidc/oidctest"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+const (
+	testOIDCClientID     = "go-chat"
+	testOIDCClientSecret = "secret"
+)
+
+// setupOIDCTest 启动模拟身份提供方并开启单点登录
+func setupOIDCTest(t *testing.T, user oidctest.User) *oidctest.Provider {
+	t.Helper()
+	setupTestEnv(t, &models.Session{}, &models.UserIdentity{}, &models.LoginAudit{})
+
+	p, err := oidctest.NewServer(testOIDCClientID, testOIDCClientSecret, user)
+	if err != nil {
+		t.Fatalf("start mock provider: %v", err)
+	}
+	t.Cleanup(p.Close)
+
+	global.Config.Set("oidc.enabled", true)
+	global.Config.Set("oidc.issuer", p.Issuer())
+	global.Config.Set("oidc.client_id", testOIDCClientID)
+	global.Config.Set("oidc.client_secret", testOIDCClientSecret)
+	global.Config.Set("oidc.redirect_url", "http://chat.test/api/user/oidc/callback")
+	global.Config.Set("oidc.allow_signup", true)
+
+	keys, err := jwtkeys.New(jwtkeys.Options{Algorithm: jwtkeys.HS256, Secret: "test-secret"})
+	if err != nil {
+		t.Fatalf("jwt keys: %v", err)
+	}
+	global.JWTKeys = keys
+
+	// 发现文档按 issuer 缓存在包变量中，每个测试的模拟服务地址不同
+	oidcProvider = nil
+	t.Cleanup(func() { oidcProvider = nil })
+	return p
+}
+
+// authorize 访问登录地址，模拟身份提供方同意授权后从跳转地址中取出 state 和 code
+func authorize(t *testing.T, loginURL string) (state, code string) {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(loginURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: want 302, got %d", resp.StatusCode)
+	}
+	redirect, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	return redirect.Query().Get("state"), redirect.Query().Get("code")
+}
+
+func oidcLoginURL(t *testing.T) string {
+	t.Helper()
+	loginURL, err := OIDCLoginURL(context.Background())
+	if err != nil {
+		t.Fatalf("login url: %v", err)
+	}
+	return loginURL
+}
+
+func countRows(t *testing.T, model interface{}) int64 {
+	t.Helper()
+	var n int64
+	if err := global.DB.Model(model).Count(&n).Error; err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	return n
+}
+
+// 身份提供方返回的邮箱与已验证邮箱的本地账号一致时绑定到该账号，之后按绑定关系登录
+func TestOIDCLoginLinksVerifiedEmail(t *testing.T) {
+	p := setupOIDCTest(t, oidctest.User{Subject: "sub-alice", Email: "Alice@Example.com", EmailVerified: true})
+	ctx := context.Background()
+
+	alice := models.User{Username: "alice", Email: "alice@example.com", EmailVerified: true, Status: 1}
+	if err := global.DB.Create(&alice).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	state, code := authorize(t, oidcLoginURL(t))
+	resp, err := OIDCCallback(ctx, state, code, DeviceInfo{})
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	if resp.Username != "alice" || resp.Token == "" {
+		t.Fatalf("want login as alice, got %+v", resp)
+	}
+
+	var identity models.UserIdentity
+	if err := global.DB.Where("provider = ? AND subject = ?", p.Issuer(), "sub-alice").First(&identity).Error; err != nil {
+		t.Fatalf("identity not linked: %v", err)
+	}
+	if identity.UserID != alice.ID {
+		t.Fatalf("identity linked to user %d, want %d", identity.UserID, alice.ID)
+	}
+	if n := countRows(t, &models.User{}); n != 1 {
+		t.Fatalf("want no new user, got %d users", n)
+	}
+
+	// 已绑定后不再依赖邮箱：身份提供方换了邮箱也仍登录到同一账号
+	p.SetUser(oidctest.User{Subject: "sub-alice", Email: "alice@elsewhere.com"})
+	state, code = authorize(t, oidcLoginURL(t))
+	resp, err = OIDCCallback(ctx, state, code, DeviceInfo{})
+	if err != nil {
+		t.Fatalf("second callback: %v", err)
+	}
+	if resp.Username != "alice" {
+		t.Fatalf("second login: want alice, got %s", resp.Username)
+	}
+}
+
+// 身份提供方未验证的邮箱既不能绑定已有账号，也不能注册新账号
+func TestOIDCLoginRejectsUnverifiedEmail(t *testing.T) {
+	setupOIDCTest(t, oidctest.User{Subject: "sub-mallory", Email: "bob@example.com", EmailVerified: false})
+
+	bob := models.User{Username: "bob", Email: "bob@example.com", EmailVerified: true, Status: 1}
+	if err := global.DB.Create(&bob).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	state, code := authorize(t, oidcLoginURL(t))
+	if _, err := OIDCCallback(context.Background(), state, code, DeviceInfo{}); !errors.Is(err, ErrOIDCEmailUnverified) {
+		t.Fatalf("want ErrOIDCEmailUnverified, got %v", err)
+	}
+	if n := countRows(t, &models.UserIdentity{}); n != 0 {
+		t.Fatalf("want no identity linked, got %d", n)
+	}
+	if n := countRows(t, &models.User{}); n != 1 {
+		t.Fatalf("want no new user, got %d users", n)
+	}
+}
+
+// state 只能使用一次：回调被重放时即使带着新的授权码也要拒绝
+func TestOIDCCallbackStateReuse(t *testing.T) {
+	setupOIDCTest(t, oidctest.User{Subject: "sub-carol", Email: "carol@example.com", EmailVerified: true})
+	ctx := context.Background()
+
+	loginURL := oidcLoginURL(t)
+	state, code := authorize(t, loginURL)
+	if _, err := OIDCCallback(ctx, state, code, DeviceInfo{}); err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+
+	_, code = authorize(t, loginURL)
+	if _, err := OIDCCallback(ctx, state, code, DeviceInfo{}); !errors.Is(err, ErrOIDCState) {
+		t.Fatalf("replayed state: want ErrOIDCState, got %v", err)
+	}
+}
+
+// ID Token 中的 nonce 必须属于本次登录：沿用之前某次登录的 nonce 签发的 Token 被拒绝
+func TestOIDCCallbackNonceReuse(t *testing.T) {
+	setupOIDCTest(t, oidctest.User{Subject: "sub-dave", Email: "dave@example.com", EmailVerified: true})
+	ctx := context.Background()
+
+	first, err := url.Parse(oidcLoginURL(t))
+	if err != nil {
+		t.Fatalf("parse login url: %v", err)
+	}
+	oldNonce := first.Query().Get("nonce")
+	state, code := authorize(t, first.String())
+	if _, err := OIDCCallback(ctx, state, code, DeviceInfo{}); err != nil {
+		t.Fatalf("first callback: %v", err)
+	}
+
+	// 第二次登录换成第一次的 nonce，其余参数 (state、PKCE) 保持不变
+	second, err := url.Parse(oidcLoginURL(t))
+	if err != nil {
+		t.Fatalf("parse login url: %v", err)
+	}
+	q := second.Query()
+	q.Set("nonce", oldNonce)
+	second.RawQuery = q.Encode()
+
+	state, code = authorize(t, second.String())
+	if _, err := OIDCCallback(ctx, state, code, DeviceInfo{}); !errors.Is(err, ErrOIDCFailed) {
+		t.Fatalf("reused nonce: want ErrOIDCFailed, got %v", err)
+	}
+	// 失败的回调同样消耗了 state
+	if _, err := OIDCCallback(ctx, state, code, DeviceInfo{}); !errors.Is(err, ErrOIDCState) {
+		t.Fatalf("retry after failure: want ErrOIDCState, got %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"go-chat/global"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// allowRate 基于 Redis 的固定窗口限流：key 在 window 内最多允许 limit 次
+// limit <= 0 表示不限流；Redis 故障时放行，不影响主流程
+func allowRate(ctx context.Context, key string, limit int, window time.Duration) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	count, err := global.RDB.Incr(ctx, key).Result()
+	if err != nil {
+		global.Log.Warn("rate limit check failed", zap.String("key", key), zap.Error(err))
+		return true
+	}
+	if count == 1 {
+		global.RDB.Expire(ctx, key, window)
+	}
+	return count <= int64(limit)
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	recommendCacheSize = 50               // 缓存的候选人数量上限
+	recommendCacheTTL  = 30 * time.Minute // 推荐结果缓存时间
+)
+
+// RecommendFriends 可能认识的人
+// 按共同好友数 (权重2) 和共同群数 (权重1) 打分，排除已是好友、拉黑关系和待处理申请的用户
+func RecommendFriends(ctx context.Context, userID uint, limit int) ([]RecommendUserDTO, error) {
+	key := recommendKey(userID)
+
+	// 1. 优先读缓存
+	var dtos []RecommendUserDTO
+	if val, err := global.RDB.Get(ctx, key).Result(); err == nil {
+		if jsonErr := json.Unmarshal([]byte(val), &dtos); jsonErr == nil {
+			return truncateRecommend(dtos, limit), nil
+		}
+		global.Log.Error("redis data unmarshal failed", zap.String("key", key))
+	}
+
+	// 2. 计算推荐
+	dtos, err := computeRecommend(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 回写缓存 (空结果也缓存，避免新用户反复计算)
+	jsonBytes, _ := json.Marshal(dtos)
+	global.RDB.Set(ctx, key, jsonBytes, recommendCacheTTL)
+
+	return truncateRecommend(dtos, limit), nil
+}
+
+func computeRecommend(ctx context.Context, userID uint) ([]RecommendUserDTO, error) {
+	db := global.DB.WithContext(ctx)
+
+	// 1. 共同好友：我的好友的好友
+	var mutual []struct {
+		UserID uint
+		Count  int
+	}
+	if err := db.Raw(`SELECT r2.target_id AS user_id, COUNT(*) AS count
+		FROM relations r1 JOIN relations r2 ON r2.owner_id = r1.target_id
+		WHERE r1.owner_id = ? AND r1.type = 1 AND r2.type = 1 AND r2.target_id <> ?
+		AND r1.deleted_at IS NULL AND r2.deleted_at IS NULL
+		GROUP BY r2.target_id`, userID, userID).Scan(&mutual).Error; err != nil {
+		return nil, err
+	}
+
+	// 2. 共同群：和我在同一个群里的人
+	var shared []struct {
+		UserID uint
+		Count  int
+	}
+	if err := db.Raw(`SELECT gm2.user_id AS user_id, COUNT(DISTINCT gm2.group_id) AS count
+		FROM group_members gm1 JOIN group_members gm2 ON gm2.group_id = gm1.group_id
+		WHERE gm1.user_id = ? AND gm2.user_id <> ?
+		AND gm1.deleted_at IS NULL AND gm2.deleted_at IS NULL
+		GROUP BY gm2.user_id`, userID, userID).Scan(&shared).Error; err != nil {
+		return nil, err
+	}
+
+	type score struct {
+		mutual int
+		groups int
+	}
+	scores := make(map[uint]*score)
+	for _, m := range mutual {
+		scores[m.UserID] = &score{mutual: m.Count}
+	}
+	for _, g := range shared {
+		if s, ok := scores[g.UserID]; ok {
+			s.groups = g.Count
+		} else {
+			scores[g.UserID] = &score{groups: g.Count}
+		}
+	}
+	if len(scores) == 0 {
+		return []RecommendUserDTO{}, nil
+	}
+
+	// 3. 排除：我的所有关系 (好友 / 我拉黑的)、拉黑了我的、双方之间待处理且未过期的申请
+	cutoff := friendRequestCutoff()
+	exclusions := []struct {
+		query  *gorm.DB
+		column string
+	}{
+		{db.Model(&models.Relation{}).Where("owner_id = ?", userID), "target_id"},
+		{db.Model(&models.Relation{}).Where("target_id = ? AND type = 2", userID), "owner_id"},
+		{db.Model(&models.FriendRequest{}).Where("sender_id = ? AND status = 0 AND created_at > ?", userID, cutoff), "receiver_id"},
+		{db.Model(&models.FriendRequest{}).Where("receiver_id = ? AND status = 0 AND created_at > ?", userID, cutoff), "sender_id"},
+	}
+	for _, ex := range exclusions {
+		var ids []uint
+		if err := ex.query.Pluck(ex.column, &ids).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			delete(scores, id)
+		}
+	}
+
+	// 4. 排序取前 N 个候选人
+	candidates := make([]uint, 0, len(scores))
+	for id := range scores {
+		candidates = append(candidates, id)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		si, sj := scores[candidates[i]], scores[candidates[j]]
+		wi, wj := si.mutual*2+si.groups, sj.mutual*2+sj.groups
+		if wi != wj {
+			return wi > wj
+		}
+		return candidates[i] < candidates[j]
+	})
+	if len(candidates) > recommendCacheSize {
+		candidates = candidates[:recommendCacheSize]
+	}
+
+	// 5. 查询用户信息 (跳过已禁用的账号)，保持排序
+	var users []models.User
+	if err := db.Where("id IN ? AND status = 1", candidates).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	userMap := make(map[uint]models.User, len(users))
+	for _, u := range users {
+		userMap[u.ID] = u
+	}
+
+	dtos := make([]RecommendUserDTO, 0, len(candidates))
+	for _, id := range candidates {
+		u, ok := userMap[id]
+		if !ok {
+			continue
+		}
+		dtos = append(dtos, RecommendUserDTO{
+			ID:            u.ID,
+			Username:      u.Username,
+			Nickname:      u.Nickname,
+			Avatar:        u.Avatar,
+			MutualFriends: scores[id].mutual,
+			SharedGroups:  scores[id].groups,
+		})
+	}
+	return dtos, nil
+}
+
+func truncateRecommend(dtos []RecommendUserDTO, limit int) []RecommendUserDTO {
+	if limit > 0 && len(dtos) > limit {
+		return dtos[:limit]
+	}
+	return dtos
+}
+
+// invalidateRecommend 好友关系变化后清除相关用户的推荐缓存
+func invalidateRecommend(ctx context.Context, userIDs ...uint) {
+	keys := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		keys = append(keys, recommendKey(id))
+	}
+	global.RDB.Del(ctx, keys...)
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"testing"
+	"time"
+)
+
+// 待处理的申请只有未过期时才排除候选人
+func TestComputeRecommendPendingRequestExpiry(t *testing.T) {
+	setupTestEnv(t, &models.Relation{}, &models.FriendRequest{}, &models.GroupMember{})
+	global.Config.Set("friend.request_expire_days", 7)
+	ctx := context.Background()
+
+	ids := createTestUsers(t, 4)
+	me, friend, expired, pending := ids[0], ids[1], ids[2], ids[3]
+	for _, pair := range [][2]uint{{me, friend}, {friend, me}, {friend, expired}, {friend, pending}} {
+		if err := global.DB.Create(&models.Relation{OwnerID: pair[0], TargetID: pair[1], Type: 1}).Error; err != nil {
+			t.Fatalf("create relation: %v", err)
+		}
+	}
+
+	old := models.FriendRequest{SenderID: me, ReceiverID: expired}
+	old.CreatedAt = time.Now().AddDate(0, 0, -8)
+	if err := global.DB.Create(&old).Error; err != nil {
+		t.Fatalf("create request: %v", err)
+	}
+	newFriendRequest(t, pending, me)
+
+	dtos, err := computeRecommend(ctx, me)
+	if err != nil {
+		t.Fatalf("recommend: %v", err)
+	}
+	if len(dtos) != 1 || dtos[0].ID != expired {
+		t.Fatalf("want only user %d recommended, got %+v", expired, dtos)
+	}
+}
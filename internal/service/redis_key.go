@@ -3,6 +3,7 @@ package service
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 // 在线状态 Key
@@ -10,6 +11,106 @@ func onlineStatusKey(userID uint) string {
 	return fmt.Sprintf("user:online:%d", userID)
 }
 
+// 好友推荐缓存 Key
+func recommendKey(userID uint) string {
+	return fmt.Sprintf("friend:recommend:%d", userID)
+}
+
+// 用户搜索限流 Key
+func userSearchRateKey(userID uint) string {
+	return fmt.Sprintf("rate:user_search:%d", userID)
+}
+
+// Refresh Token Key (存 Token 的 SHA-256 摘要)
+func refreshTokenKey(tokenHash string) string {
+	return "auth:refresh:" + tokenHash
+}
+
+// 已吊销的 Access Token Key
+func revokedTokenKey(tokenID string) string {
+	return "auth:revoked:" + tokenID
+}
+
+// 已吊销的登录会话 Key
+func revokedSessionKey(sessionID string) string {
+	return "auth:session_revoked:" + sessionID
+}
+
+// 会话最后活跃时间的写库节流 Key
+func sessionSeenKey(sessionID string) string {
+	return "auth:session_seen:" + sessionID
+}
+
+// 登录失败计数 Key (scope 为 user / ip)
+func loginFailKey(scope, id string) string {
+	return fmt.Sprintf("login:fail:%s:%s", scope, id)
+}
+
+// 登录锁定 Key
+func loginLockKey(scope, id string) string {
+	return fmt.Sprintf("login:lock:%s:%s", scope, id)
+}
+
+// 24 小时内被锁定的次数，用于计算指数退避
+func loginStrikeKey(scope, id string) string {
+	return fmt.Sprintf("login:strike:%s:%s", scope, id)
+}
+
+// 邮箱验证 Token Key (存 Token 的 SHA-256 摘要)
+func emailVerifyKey(tokenHash string) string {
+	return "auth:email_verify:" + tokenHash
+}
+
+// 重置密码 Token Key (存 Token 的 SHA-256 摘要)
+func passwordResetKey(tokenHash string) string {
+	return "auth:password_reset:" + tokenHash
+}
+
+// 每个邮箱地址的发信限流 Key
+func mailRateKey(email string) string {
+	return "rate:mail:" + strings.ToLower(email)
+}
+
+// 每个 IP 的找回密码限流 Key
+func resetIPRateKey(ip string) string {
+	return "rate:password_reset:" + ip
+}
+
+// 待确认的 TOTP 密钥 Key
+func totpSetupKey(userID uint) string {
+	return fmt.Sprintf("auth:totp_setup:%d", userID)
+}
+
+// 已使用过的 TOTP 时间步 Key，防止验证码重放
+func totpUsedKey(userID uint, step int64) string {
+	return fmt.Sprintf("auth:totp_used:%d:%d", userID, step)
+}
+
+// 两步验证登录挑战 Key (存 Token 的 SHA-256 摘要)
+func twoFactorChallengeKey(tokenHash string) string {
+	return "auth:2fa_challenge:" + tokenHash
+}
+
+// 登录挑战的失败次数 Key
+func twoFactorAttemptsKey(tokenHash string) string {
+	return "auth:2fa_attempts:" + tokenHash
+}
+
+// OIDC 登录发起时保存的 state (对应 nonce 和 PKCE verifier)
+func oidcStateKey(state string) string {
+	return "auth:oidc_state:" + state
+}
+
+// 链接预览缓存 Key (存链接的 SHA-256 摘要)
+func linkPreviewKey(urlHash string) string {
+	return "link_preview:" + urlHash
+}
+
+// 内容审核计数 Key (如重复消息检测)
+func moderationCounterKey(key string) string {
+	return "moderation:count:" + key
+}
+
 // 生成 Redis Key：保证顺序一致 (small_id:big_id)
 func generateKey(id1 uint, id2 uint) string {
 	var key string
@@ -5,8 +5,12 @@ import (
 	"errors"
 	"go-chat/global"
 	"go-chat/internal/models"
+	"go-chat/internal/pkg/protocol"
+	"time"
 
+	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
@@ -16,6 +20,10 @@ var (
 	ErrRequestNotFound  = errors.New("申请记录不存在")
 	ErrRequestHandled   = errors.New("该申请已被处理")
 	ErrPermissionDenied = errors.New("无权处理此申请")
+	ErrFriendNotFound   = errors.New("好友关系不存在")
+	ErrRequestExpired   = errors.New("该申请已过期")
+	ErrRequestCooldown  = errors.New("对方近期拒绝了你的申请，请稍后再试")
+	ErrUserNotFound     = errors.New("用户不存在")
 )
 
 // --------------------------
@@ -33,6 +41,13 @@ func SendFriendRequest(ctx context.Context, userID uint, req SendFriendRequestRe
 		return errors.New("目标用户不存在")
 	}
 
+	// 1.1 任意一方拉黑了对方都不允许申请
+	if blocked, err := IsBlocked(ctx, userID, req.TargetID); err != nil {
+		return err
+	} else if blocked {
+		return ErrBlocked
+	}
+
 	// 2. 检查是否已经是好友 (查询 relation 表)
 	var rel models.Relation
 	err := global.DB.WithContext(ctx).
@@ -42,15 +57,29 @@ func SendFriendRequest(ctx context.Context, userID uint, req SendFriendRequestRe
 		return ErrAlreadyFriend // 查到了记录，说明已经是好友
 	}
 
-	// 3. 检查是否重复发送申请 (查询 friend_requests 表，状态为 0-待处理)
+	// 3. 检查是否重复发送申请 (查询 friend_requests 表，状态为 0-待处理 且未过期)
 	var existReq models.FriendRequest
 	err = global.DB.WithContext(ctx).
-		Where("sender_id = ? AND receiver_id = ? AND status = 0", userID, req.TargetID).
+		Where("sender_id = ? AND receiver_id = ? AND status = 0 AND created_at > ?", userID, req.TargetID, friendRequestCutoff()).
 		First(&existReq).Error
 	if err == nil {
 		return ErrRequestExist // 查到了记录，说明申请还在排队
 	}
 
+	// 3.1 被拒绝后的冷却期内不能再次申请，防止骚扰
+	if cooldown := global.Config.GetInt("friend.reject_cooldown_hours"); cooldown > 0 {
+		var rejected int64
+		if err := global.DB.WithContext(ctx).Model(&models.FriendRequest{}).
+			Where("sender_id = ? AND receiver_id = ? AND status = 2 AND updated_at > ?",
+				userID, req.TargetID, time.Now().Add(-time.Duration(cooldown)*time.Hour)).
+			Count(&rejected).Error; err != nil {
+			return err
+		}
+		if rejected > 0 {
+			return ErrRequestCooldown
+		}
+	}
+
 	// 4. 创建申请记录
 	friendReq := models.FriendRequest{
 		SenderID:   userID,
@@ -59,87 +88,146 @@ func SendFriendRequest(ctx context.Context, userID uint, req SendFriendRequestRe
 		Status:     0, // 0: 待处理
 	}
 
-	return global.DB.WithContext(ctx).Create(&friendReq).Error
+	if err := global.DB.WithContext(ctx).Create(&friendReq).Error; err != nil {
+		return err
+	}
+	invalidateRecommend(ctx, userID, req.TargetID)
+
+	// 5. 实时通知接收方
+	var sender models.User
+	global.DB.WithContext(ctx).First(&sender, userID)
+	PushEvent(req.TargetID, protocol.EventFriendRequestNew, toFriendRequestDTO(friendReq, sender))
+	return nil
 }
 
 // --------------------------
 // 2. 处理好友申请 (同意/拒绝)
 // --------------------------
 func HandleFriendRequest(ctx context.Context, userID uint, req HandleFriendRequestReq) error {
-	// 1. 查找申请记录
 	var friendReq models.FriendRequest
-	if err := global.DB.WithContext(ctx).First(&friendReq, req.RequestID).Error; err != nil {
-		return ErrRequestNotFound
-	}
+	changed := false // 本次调用是否真正改变了申请状态 (重复提交时为 false)
+	expired := false
+
+	err := global.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 1. 查找申请记录并加行锁，同一申请的并发处理在这里串行化
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&friendReq, req.RequestID).Error; err != nil {
+			return ErrRequestNotFound
+		}
 
-	// 2. 校验权限：只有接收者才能处理申请
-	if friendReq.ReceiverID != userID {
-		return ErrPermissionDenied
-	}
+		// 2. 校验权限：只有接收者才能处理申请
+		if friendReq.ReceiverID != userID {
+			return ErrPermissionDenied
+		}
 
-	// 3. 校验状态：防止重复处理
-	if friendReq.Status != 0 {
-		return ErrRequestHandled
-	}
+		// 3. 校验状态：重复提交相同操作直接视为成功，与已有结果冲突才报错
+		if friendReq.Status == req.Action {
+			return nil
+		}
+		if friendReq.Status != 0 {
+			return ErrRequestHandled
+		}
 
-	// 4. 处理逻辑
-	return global.DB.Transaction(func(tx *gorm.DB) error {
-		// 4.1 如果是同意操作，先清理可能存在的反向申请
-		if req.Action == 1 {
-			// 检查是否已经是好友（防止重复创建）
-			var existingRel models.Relation
-			err := tx.Where("owner_id = ? AND target_id = ? AND type = 1",
-				friendReq.SenderID, friendReq.ReceiverID).First(&existingRel).Error
-			if err == nil {
-				// 已经是好友关系，只更新申请状态为已同意，不重复创建relation
-				friendReq.Status = 1
-				if err := tx.Save(&friendReq).Error; err != nil {
-					return err
-				}
-				return nil
-			}
-
-			// 删除用户发给对方的好友申请（反向申请）
-			if err := tx.Where("sender_id = ? AND receiver_id = ? AND status = 0",
-				friendReq.ReceiverID, friendReq.SenderID).Delete(&models.FriendRequest{}).Error; err != nil {
-				return err
-			}
+		// 3.1 超过有效期的申请置为过期 (需要提交事务，所以不在这里返回错误)
+		if !friendReq.CreatedAt.After(friendRequestCutoff()) {
+			expired = true
+			return tx.Model(&friendReq).Update("status", 4).Error
 		}
 
-		// 4.2 更新申请状态 (1:同意, 2:拒绝)
-		friendReq.Status = req.Action
-		if err := tx.Save(&friendReq).Error; err != nil {
+		// 4. 更新申请状态 (1:同意, 2:拒绝)
+		if err := tx.Model(&friendReq).Update("status", req.Action).Error; err != nil {
 			return err
 		}
+		changed = true
 
 		// 如果是拒绝，到这里就结束了
 		if req.Action == 2 {
 			return nil
 		}
 
-		// 4.3 如果是同意 (Action == 1)，需要在 relations 表创建双向好友关系
-		// 关系 A -> B
-		r1 := models.Relation{
-			OwnerID:  friendReq.SenderID,
-			TargetID: friendReq.ReceiverID,
-			Type:     1,
-		}
-		if err := tx.Create(&r1).Error; err != nil {
+		// 5. 同意：拉黑关系是在申请之后建立的则不能再成为好友
+		if blocked, err := isBlocked(tx, friendReq.SenderID, friendReq.ReceiverID); err != nil {
 			return err
+		} else if blocked {
+			return ErrBlocked
 		}
 
-		// 关系 B -> A
-		r2 := models.Relation{
-			OwnerID:  friendReq.ReceiverID,
-			TargetID: friendReq.SenderID,
-			Type:     1,
+		// 6. 创建双向好友关系
+		// (owner_id, target_id) 有唯一索引，已存在的记录直接跳过，互相申请并发同意时也不会重复
+		// 两行按 owner_id 升序插入，保证并发事务的加锁顺序一致，避免死锁
+		relations := []models.Relation{
+			{OwnerID: friendReq.SenderID, TargetID: friendReq.ReceiverID, Type: 1},
+			{OwnerID: friendReq.ReceiverID, TargetID: friendReq.SenderID, Type: 1},
 		}
-		if err := tx.Create(&r2).Error; err != nil {
-			return err
+		if relations[0].OwnerID > relations[1].OwnerID {
+			relations[0], relations[1] = relations[1], relations[0]
 		}
-
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&relations).Error
+	})
+	if err != nil {
+		return err
+	}
+	if expired {
+		return ErrRequestExpired
+	}
+	if !changed {
 		return nil
+	}
+
+	invalidateRecommend(ctx, friendReq.SenderID, friendReq.ReceiverID)
+
+	// 7. 对方发给我的反向申请一并视为已同意
+	// 放在事务外做条件更新，避免与对方同时处理时互相持有申请行锁导致死锁
+	if req.Action == 1 {
+		if err := global.DB.WithContext(ctx).Model(&models.FriendRequest{}).
+			Where("sender_id = ? AND receiver_id = ? AND status = 0", friendReq.ReceiverID, friendReq.SenderID).
+			Update("status", 1).Error; err != nil {
+			global.Log.Warn("update reverse friend request failed", zap.Error(err))
+		}
+	}
+
+	// 8. 把处理结果实时通知申请人
+	event := protocol.EventFriendRequestAccepted
+	if req.Action == 2 {
+		event = protocol.EventFriendRequestRejected
+	}
+	PushEvent(friendReq.SenderID, event, FriendRequestEventDTO{
+		RequestID: friendReq.ID,
+		UserID:    userID,
+		Status:    req.Action,
 	})
+	return nil
+}
+
+// WithdrawFriendRequest 撤回我发出的好友申请
+func WithdrawFriendRequest(ctx context.Context, userID uint, req WithdrawFriendRequestReq) error {
+	var friendReq models.FriendRequest
+	if err := global.DB.WithContext(ctx).First(&friendReq, req.RequestID).Error; err != nil {
+		return ErrRequestNotFound
+	}
+
+	// 只有申请人才能撤回
+	if friendReq.SenderID != userID {
+		return ErrPermissionDenied
+	}
+
+	// 条件更新，避免与对方的处理操作并发时覆盖结果
+	result := global.DB.WithContext(ctx).Model(&models.FriendRequest{}).
+		Where("id = ? AND status = 0", friendReq.ID).
+		Update("status", 3)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRequestHandled
+	}
+
+	PushEvent(friendReq.ReceiverID, protocol.EventFriendRequestWithdrawn, FriendRequestEventDTO{
+		RequestID: friendReq.ID,
+		UserID:    userID,
+		Status:    3,
+	})
+	return nil
 }
 
 // --------------------------
@@ -154,7 +242,7 @@ func GetPendingRequests(ctx context.Context, userID uint) ([]FriendRequestDTO, e
 	// 3. Order: 按时间倒序
 	err := global.DB.WithContext(ctx).
 		Preload("Sender").
-		Where("receiver_id = ? AND status = 0", userID).
+		Where("receiver_id = ? AND status = 0 AND created_at > ?", userID, friendRequestCutoff()).
 		Order("created_at desc").
 		Find(&requests).Error
 
@@ -167,29 +255,123 @@ func GetPendingRequests(ctx context.Context, userID uint) ([]FriendRequestDTO, e
 	for _, req := range requests {
 		// 因为用了 Preload，这里可以直接通过 req.Sender 拿到用户信息
 		// 如果没查到 Sender (比如用户注销了)，req.Sender 会是零值，不会 panic
-		dtos = append(dtos, FriendRequestDTO{
-			ID:         req.ID,
-			SenderID:   req.SenderID,
-			SenderName: req.Sender.Username, // 直接取值！
-			Avatar:     req.Sender.Avatar,   // 直接取值！
-			Remark:     req.Remark,
-			Status:     req.Status,
-			CreatedAt:  req.CreatedAt.Format("2006-01-02 15:04:05"),
+		dtos = append(dtos, toFriendRequestDTO(req, req.Sender))
+	}
+
+	return dtos, nil
+}
+
+// GetSentRequests 获取我发出的好友申请 (含已处理的，最新100条)
+func GetSentRequests(ctx context.Context, userID uint) ([]SentFriendRequestDTO, error) {
+	var requests []models.FriendRequest
+	err := global.DB.WithContext(ctx).
+		Preload("Receiver").
+		Where("sender_id = ?", userID).
+		Order("created_at desc").
+		Limit(100).
+		Find(&requests).Error
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := friendRequestCutoff()
+	dtos := make([]SentFriendRequestDTO, 0, len(requests))
+	for _, req := range requests {
+		status := req.Status
+		// 定时任务还没扫到的过期申请，展示时按过期处理
+		if status == 0 && !req.CreatedAt.After(cutoff) {
+			status = 4
+		}
+		dtos = append(dtos, SentFriendRequestDTO{
+			ID:           req.ID,
+			ReceiverID:   req.ReceiverID,
+			ReceiverName: req.Receiver.Username,
+			Avatar:       req.Receiver.Avatar,
+			Remark:       req.Remark,
+			Status:       status,
+			CreatedAt:    req.CreatedAt.Format("2006-01-02 15:04:05"),
 		})
 	}
 
 	return dtos, nil
 }
 
+// toFriendRequestDTO 申请记录转换为接收方看到的列表项
+func toFriendRequestDTO(req models.FriendRequest, sender models.User) FriendRequestDTO {
+	// 如果没查到 Sender (比如用户注销了)，sender 会是零值，不会 panic
+	return FriendRequestDTO{
+		ID:         req.ID,
+		SenderID:   req.SenderID,
+		SenderName: sender.Username,
+		Avatar:     sender.Avatar,
+		Remark:     req.Remark,
+		Status:     req.Status,
+		CreatedAt:  req.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+// friendRequestCutoff 早于该时间创建的待处理申请视为过期
+// 未配置有效期时返回零值，即永不过期
+func friendRequestCutoff() time.Time {
+	days := global.Config.GetInt("friend.request_expire_days")
+	if days <= 0 {
+		return time.Time{}
+	}
+	return time.Now().AddDate(0, 0, -days)
+}
+
+// StartFriendRequestJanitor 定时把过期的待处理申请标记为已过期 (在 main.go 中调用)
+func StartFriendRequestJanitor() {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			expireFriendRequests()
+			<-ticker.C
+		}
+	}()
+}
+
+func expireFriendRequests() {
+	cutoff := friendRequestCutoff()
+	if cutoff.IsZero() {
+		return
+	}
+
+	result := global.DB.Model(&models.FriendRequest{}).
+		Where("status = 0 AND created_at <= ?", cutoff).
+		Update("status", 4)
+	if result.Error != nil {
+		global.Log.Error("expire friend requests failed", zap.Error(result.Error))
+		return
+	}
+	if result.RowsAffected > 0 {
+		global.Log.Info("friend requests expired", zap.Int64("count", result.RowsAffected))
+	}
+}
+
 // SearchUserByUsername 根据用户名搜索用户
-func SearchUserByUsername(ctx context.Context, username string) (*UserResponseDTO, error) {
+// 与搜索者之间存在拉黑关系 (任意一方拉黑) 的用户视为不存在
+func SearchUserByUsername(ctx context.Context, userID uint, username string) (*UserResponseDTO, error) {
 	var user models.User
 	err := global.DB.WithContext(ctx).
 		Where("username = ?", username).
 		First(&user).Error
-
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
 	if err != nil {
-		return nil, errors.New("用户不存在")
+		return nil, err
+	}
+
+	if user.ID != userID {
+		blocked, err := IsBlocked(ctx, userID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, ErrUserNotFound
+		}
 	}
 
 	dto := ToUserDTO(user)
@@ -197,13 +379,22 @@ func SearchUserByUsername(ctx context.Context, username string) (*UserResponseDT
 	return &dto, nil
 }
 
-// GetFriendList 获取我的好友列表（带未读计数）
-func GetFriendList(ctx context.Context, userID uint) ([]UserResponseDTO, error) {
+// GetFriendList 获取我的好友列表（带未读计数，可按分组过滤）
+func GetFriendList(ctx context.Context, userID uint, req FriendListReq) ([]UserResponseDTO, error) {
 	// 1. 查询用户的所有好友关系记录
 	var relations []models.Relation
-	err := global.DB.WithContext(ctx).
-		Where("owner_id = ? AND type = 1", userID).
-		Find(&relations).Error
+	query := global.DB.WithContext(ctx).
+		Where("owner_id = ? AND type = 1", userID)
+	if req.TagID != nil {
+		query = query.Where("tag_id = ?", *req.TagID)
+	}
+	err := query.Find(&relations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 拉黑了我的好友不向我展示在线状态
+	blockedBy, err := blockedByIDs(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -218,13 +409,16 @@ func GetFriendList(ctx context.Context, userID uint) ([]UserResponseDTO, error)
 		}
 
 		// 3. 查询在线状态
-		online := global.RDB.Exists(ctx, onlineStatusKey(user.ID)).Val() > 0
+		online := false
+		if _, ok := blockedBy[user.ID]; !ok {
+			online = global.RDB.Exists(ctx, onlineStatusKey(user.ID)).Val() > 0
+		}
 
 		// 4. 计算未读消息数量：查询该好友发给我的消息中，msg_id > last_read_msg_id 的数量
 		var unreadCount int64
 		global.DB.WithContext(ctx).
 			Model(&models.Message{}).
-			Where("from_user_id = ? AND to_user_id = ? AND id > ?", rel.TargetID, userID, rel.LastReadMsgID).
+			Where("from_user_id = ? AND to_user_id = ? AND id > ? AND status = ?", rel.TargetID, userID, rel.LastReadMsgID, models.MessageNormal).
 			Count(&unreadCount)
 
 		// 5. 获取最后一条消息时间
@@ -233,6 +427,7 @@ func GetFriendList(ctx context.Context, userID uint) ([]UserResponseDTO, error)
 		global.DB.WithContext(ctx).
 			Where("(from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?)",
 				userID, rel.TargetID, rel.TargetID, userID).
+			Where("status = ?", models.MessageNormal).
 			Order("id DESC").
 			First(&lastMsg)
 		if lastMsg.ID > 0 {
@@ -247,6 +442,8 @@ func GetFriendList(ctx context.Context, userID uint) ([]UserResponseDTO, error)
 			Online:      online,
 			UnreadCount: int(unreadCount),
 			LastMsgTime: lastMsgTime,
+			Remark:      rel.Desc,
+			TagID:       rel.TagID,
 		})
 	}
 
@@ -266,7 +463,7 @@ func MarkMessagesAsRead(ctx context.Context, userID uint, req MarkMessagesReadRe
 	// 2. 获取该好友发给我的最后一条消息ID
 	var lastMsg models.Message
 	if err := global.DB.WithContext(ctx).
-		Where("from_user_id = ? AND to_user_id = ?", req.TargetID, userID).
+		Where("from_user_id = ? AND to_user_id = ? AND status = ?", req.TargetID, userID, models.MessageNormal).
 		Order("id DESC").
 		First(&lastMsg).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -282,3 +479,55 @@ func MarkMessagesAsRead(ctx context.Context, userID uint, req MarkMessagesReadRe
 		Where("id = ?", rel.ID).
 		Update("last_read_msg_id", lastMsg.ID).Error
 }
+
+// DeleteFriend 删除好友
+// 双向好友记录在同一事务内物理删除，并通知对方
+// 对方若已拉黑我，其拉黑记录 (type=2) 保留
+func DeleteFriend(ctx context.Context, userID uint, req DeleteFriendReq) error {
+	err := global.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().
+			Where("owner_id = ? AND target_id = ? AND type = 1", userID, req.TargetID).
+			Delete(&models.Relation{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrFriendNotFound
+		}
+
+		return tx.Unscoped().
+			Where("owner_id = ? AND target_id = ? AND type = 1", req.TargetID, userID).
+			Delete(&models.Relation{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateRecommend(ctx, userID, req.TargetID)
+	PushEvent(req.TargetID, protocol.EventFriendDeleted, FriendEventDTO{UserID: userID})
+	return nil
+}
+
+// UpdateFriendRemark 修改好友备注名
+func UpdateFriendRemark(ctx context.Context, userID uint, req UpdateFriendRemarkReq) error {
+	result := global.DB.WithContext(ctx).
+		Model(&models.Relation{}).
+		Where("owner_id = ? AND target_id = ? AND type = 1", userID, req.TargetID).
+		Update("desc", req.Remark)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// 备注没变时 RowsAffected 也为 0，再确认一次关系是否存在
+		var count int64
+		if err := global.DB.WithContext(ctx).Model(&models.Relation{}).
+			Where("owner_id = ? AND target_id = ? AND type = 1", userID, req.TargetID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrFriendNotFound
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"sync"
+	"testing"
+)
+
+// 每个场景重复的次数，让并发调用有更多机会以不同顺序交错
+const friendRaceRounds = 20
+
+func newFriendRequest(t *testing.T, senderID, receiverID uint) uint {
+	t.Helper()
+	req := models.FriendRequest{SenderID: senderID, ReceiverID: receiverID}
+	if err := global.DB.Create(&req).Error; err != nil {
+		t.Fatalf("create friend request: %v", err)
+	}
+	return req.ID
+}
+
+// assertFriends 双方各有且只有一条好友关系
+func assertFriends(t *testing.T, a, b uint) {
+	t.Helper()
+	for _, pair := range [][2]uint{{a, b}, {b, a}} {
+		var rows []models.Relation
+		if err := global.DB.Where("owner_id = ? AND target_id = ?", pair[0], pair[1]).Find(&rows).Error; err != nil {
+			t.Fatalf("query relations: %v", err)
+		}
+		if len(rows) != 1 || rows[0].Type != 1 {
+			t.Fatalf("relation %d->%d: want exactly one friend row, got %+v", pair[0], pair[1], rows)
+		}
+	}
+}
+
+// assertNoRelation 双方之间没有任何关系记录
+func assertNoRelation(t *testing.T, a, b uint) {
+	t.Helper()
+	var count int64
+	if err := global.DB.Model(&models.Relation{}).
+		Where("(owner_id = ? AND target_id = ?) OR (owner_id = ? AND target_id = ?)", a, b, b, a).
+		Count(&count).Error; err != nil {
+		t.Fatalf("count relations: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("relations between %d and %d: want 0, got %d", a, b, count)
+	}
+}
+
+func requestStatus(t *testing.T, id uint) int {
+	t.Helper()
+	var req models.FriendRequest
+	if err := global.DB.First(&req, id).Error; err != nil {
+		t.Fatalf("load friend request: %v", err)
+	}
+	return req.Status
+}
+
+// runConcurrently 同时启动所有调用并返回各自的结果
+func runConcurrently(calls ...func() error) []error {
+	errs := make([]error, len(calls))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			errs[i] = call()
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return errs
+}
+
+// 互相发了申请，双方同时同意对方的申请
+func TestHandleFriendRequestMutualAccept(t *testing.T) {
+	setupTestEnv(t, &models.Relation{}, &models.FriendRequest{})
+	ctx := context.Background()
+
+	for i := 0; i < friendRaceRounds; i++ {
+		users := createTestUsers(t, 2)
+		a, b := users[0], users[1]
+		aToB := newFriendRequest(t, a, b)
+		bToA := newFriendRequest(t, b, a)
+
+		errs := runConcurrently(
+			func() error { return HandleFriendRequest(ctx, b, HandleFriendRequestReq{RequestID: aToB, Action: 1}) },
+			func() error { return HandleFriendRequest(ctx, a, HandleFriendRequestReq{RequestID: bToA, Action: 1}) },
+		)
+		for _, err := range errs {
+			if err != nil {
+				t.Fatalf("round %d: accept failed: %v", i, err)
+			}
+		}
+
+		assertFriends(t, a, b)
+		if s1, s2 := requestStatus(t, aToB), requestStatus(t, bToA); s1 != 1 || s2 != 1 {
+			t.Fatalf("round %d: want both requests accepted, got %d and %d", i, s1, s2)
+		}
+	}
+}
+
+// 同一申请被重复提交同意 (如客户端重试)，都应成功且只建立一次关系
+func TestHandleFriendRequestDoubleAccept(t *testing.T) {
+	setupTestEnv(t, &models.Relation{}, &models.FriendRequest{})
+	ctx := context.Background()
+
+	for i := 0; i < friendRaceRounds; i++ {
+		users := createTestUsers(t, 2)
+		a, b := users[0], users[1]
+		id := newFriendRequest(t, a, b)
+
+		accept := func() error { return HandleFriendRequest(ctx, b, HandleFriendRequestReq{RequestID: id, Action: 1}) }
+		for _, err := range runConcurrently(accept, accept, accept) {
+			if err != nil {
+				t.Fatalf("round %d: accept failed: %v", i, err)
+			}
+		}
+
+		assertFriends(t, a, b)
+		if s := requestStatus(t, id); s != 1 {
+			t.Fatalf("round %d: want status 1, got %d", i, s)
+		}
+	}
+}
+
+// 同一申请同时被同意和拒绝，只有一个操作生效，结果与生效的操作一致
+func TestHandleFriendRequestAcceptRacesReject(t *testing.T) {
+	setupTestEnv(t, &models.Relation{}, &models.FriendRequest{})
+	ctx := context.Background()
+
+	for i := 0; i < friendRaceRounds; i++ {
+		users := createTestUsers(t, 2)
+		a, b := users[0], users[1]
+		id := newFriendRequest(t, a, b)
+
+		errs := runConcurrently(
+			func() error { return HandleFriendRequest(ctx, b, HandleFriendRequestReq{RequestID: id, Action: 1}) },
+			func() error { return HandleFriendRequest(ctx, b, HandleFriendRequestReq{RequestID: id, Action: 2}) },
+		)
+		acceptErr, rejectErr := errs[0], errs[1]
+
+		status := requestStatus(t, id)
+		switch {
+		case acceptErr == nil && errors.Is(rejectErr, ErrRequestHandled):
+			if status != 1 {
+				t.Fatalf("round %d: accept won but status is %d", i, status)
+			}
+			assertFriends(t, a, b)
+		case rejectErr == nil && errors.Is(acceptErr, ErrRequestHandled):
+			if status != 2 {
+				t.Fatalf("round %d: reject won but status is %d", i, status)
+			}
+			assertNoRelation(t, a, b)
+		default:
+			t.Fatalf("round %d: want exactly one winner, got accept=%v reject=%v", i, acceptErr, rejectErr)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"go-chat/internal/pkg/utils"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var ErrSessionNotFound = errors.New("会话不存在或已失效")
+
+// sessionTouchInterval 最后活跃时间的更新间隔，避免每个请求都写库
+const sessionTouchInterval = time.Minute
+
+// createSession 登录成功后创建会话
+func createSession(ctx context.Context, userID uint, device DeviceInfo) (*models.Session, error) {
+	sessionID, err := utils.RandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	session := models.Session{
+		SessionID:  sessionID,
+		UserID:     userID,
+		DeviceName: truncate(device.Name, 64),
+		IP:         truncate(device.IP, 64),
+		UserAgent:  truncate(device.UserAgent, 255),
+		LastSeenAt: time.Now(),
+	}
+	if err := global.DB.WithContext(ctx).Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// isSessionActive 会话存在且未被吊销
+func isSessionActive(ctx context.Context, userID uint, sessionID string) bool {
+	var count int64
+	global.DB.WithContext(ctx).Model(&models.Session{}).
+		Where("session_id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Count(&count)
+	return count > 0
+}
+
+// GetSessions 获取我的登录设备列表 (已过期的会话不再返回)
+func GetSessions(ctx context.Context, userID uint, currentSessionID string) ([]SessionDTO, error) {
+	var sessions []models.Session
+	err := global.DB.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND last_seen_at > ?", userID, time.Now().Add(-refreshTokenTTL())).
+		Order("last_seen_at desc").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]SessionDTO, 0, len(sessions))
+	for _, s := range sessions {
+		result = append(result, SessionDTO{
+			SessionID:  s.SessionID,
+			DeviceName: s.DeviceName,
+			IP:         s.IP,
+			UserAgent:  s.UserAgent,
+			Current:    s.SessionID == currentSessionID,
+			CreatedAt:  s.CreatedAt.UnixMilli(),
+			LastSeenAt: s.LastSeenAt.UnixMilli(),
+		})
+	}
+	return result, nil
+}
+
+// RevokeSession 下线指定设备
+func RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	result := global.DB.WithContext(ctx).Model(&models.Session{}).
+		Where("session_id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+
+	markSessionsRevoked(ctx, userID, []string{sessionID})
+	return nil
+}
+
+// RevokeOtherSessions 下线除当前设备外的所有设备，返回下线的数量
+func RevokeOtherSessions(ctx context.Context, userID uint, currentSessionID string) (int, error) {
+	var sessionIDs []string
+	if err := global.DB.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL AND session_id <> ?", userID, currentSessionID).
+		Pluck("session_id", &sessionIDs).Error; err != nil {
+		return 0, err
+	}
+	if len(sessionIDs) == 0 {
+		return 0, nil
+	}
+
+	if err := global.DB.WithContext(ctx).Model(&models.Session{}).
+		Where("session_id IN ?", sessionIDs).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return 0, err
+	}
+
+	markSessionsRevoked(ctx, userID, sessionIDs)
+	return len(sessionIDs), nil
+}
+
+// markSessionsRevoked 让这些会话已签发的 Access Token 立即失效，并断开对应的 WebSocket
+// 标记只需保留一个 Access Token 有效期，之后旧 Token 自然过期，刷新时会查库拒绝
+func markSessionsRevoked(ctx context.Context, userID uint, sessionIDs []string) {
+	pipe := global.RDB.Pipeline()
+	for _, sid := range sessionIDs {
+		pipe.Set(ctx, revokedSessionKey(sid), "1", utils.AccessTokenTTL())
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		global.Log.Error("mark sessions revoked failed", zap.Uint("user_id", userID), zap.Error(err))
+	}
+
+	for _, sid := range sessionIDs {
+		Manager.KickSession(userID, sid)
+	}
+}
+
+// TouchSession 更新会话最后活跃时间，每个会话每分钟最多写一次库
+func TouchSession(ctx context.Context, sessionID string) {
+	if sessionID == "" {
+		return
+	}
+
+	ok, err := global.RDB.SetNX(ctx, sessionSeenKey(sessionID), "1", sessionTouchInterval).Result()
+	if err != nil || !ok {
+		return
+	}
+
+	if err := global.DB.WithContext(ctx).Model(&models.Session{}).
+		Where("session_id = ?", sessionID).
+		Update("last_seen_at", time.Now()).Error; err != nil {
+		global.Log.Warn("touch session failed", zap.String("session_id", sessionID), zap.Error(err))
+	}
+}
+
+// truncate 按字符截断，保证不超过数据库字段长度
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestEnv 用进程内的 SQLite 和 miniredis 替换全局的 DB、RDB
+// SQLite 不支持行锁，_txlock=immediate 让事务在 BEGIN 时就拿到写锁，并发事务整体串行执行
+// 测试结束后只关闭连接，不把全局变量还原为 nil：登录后更新 last_login 等异步协程可能仍在使用它们
+func setupTestEnv(t *testing.T, tables ...interface{}) {
+	t.Helper()
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=10000&_txlock=immediate&_journal_mode=WAL"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(append([]interface{}{&models.User{}}, tables...)...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	global.Config, global.DB, global.RDB, global.Log = viper.New(), db, rdb, zap.NewNop()
+	t.Cleanup(func() {
+		rdb.Close()
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+var testUserSeq int
+
+// createTestUsers 创建 n 个用户并返回其 ID
+func createTestUsers(t *testing.T, n int) []uint {
+	t.Helper()
+	ids := make([]uint, n)
+	for i := range ids {
+		testUserSeq++
+		u := models.User{Username: fmt.Sprintf("test_user_%d", testUserSeq)}
+		if err := global.DB.Create(&u).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		ids[i] = u.ID
+	}
+	return ids
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"go-chat/internal/pkg/totp"
+	"go-chat/internal/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTOTPAlreadyEnabled = errors.New("两步验证已开启")
+	ErrTOTPNotEnabled     = errors.New("两步验证未开启")
+	ErrTOTPSetupExpired   = errors.New("设置已过期，请重新获取密钥")
+	ErrInvalidTOTPCode    = errors.New("验证码错误")
+	ErrInvalidChallenge   = errors.New("登录已过期，请重新登录")
+	ErrWrongPassword      = errors.New("密码错误")
+)
+
+const (
+	totpSetupTTL          = 10 * time.Minute // 待确认密钥的有效期
+	twoFactorChallengeTTL = 5 * time.Minute  // 登录挑战的有效期
+	twoFactorMaxAttempts  = 5                // 每个登录挑战最多尝试次数
+	recoveryCodeCount     = 10               // 每次生成的恢复码数量
+)
+
+// twoFactorChallenge 密码验证通过、等待第二步验证的登录
+type twoFactorChallenge struct {
+	UserID uint       `json:"user_id"`
+	Device DeviceInfo `json:"device"`
+}
+
+func totpIssuer() string {
+	if issuer := global.Config.GetString("totp.issuer"); issuer != "" {
+		return issuer
+	}
+	return "GoChat"
+}
+
+// SetupTOTP 生成新的 TOTP 密钥，确认前只保存在 Redis 中
+func SetupTOTP(ctx context.Context, userID uint) (*TOTPSetupDTO, error) {
+	var user models.User
+	if err := global.DB.WithContext(ctx).First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := global.RDB.Set(ctx, totpSetupKey(userID), secret, totpSetupTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	return &TOTPSetupDTO{
+		Secret: secret,
+		URI:    totp.ProvisioningURI(totpIssuer(), user.Username, secret),
+	}, nil
+}
+
+// EnableTOTP 用验证器生成的验证码确认密钥，开启两步验证并返回恢复码
+func EnableTOTP(ctx context.Context, userID uint, code string) ([]string, error) {
+	secret, err := global.RDB.Get(ctx, totpSetupKey(userID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrTOTPSetupExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := totp.Validate(secret, code, time.Now(), 1); !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	var codes []string
+	err = global.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND totp_enabled = ?", userID, false).
+			Updates(map[string]interface{}{"totp_secret": secret, "totp_enabled": true})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTOTPAlreadyEnabled
+		}
+
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	global.RDB.Del(ctx, totpSetupKey(userID))
+	return codes, nil
+}
+
+// DisableTOTP 关闭两步验证，需同时验证密码和验证码 (或恢复码)
+func DisableTOTP(ctx context.Context, userID uint, password, code string) error {
+	var user models.User
+	if err := global.DB.WithContext(ctx).First(&user, userID).Error; err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTOTPNotEnabled
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return ErrWrongPassword
+	}
+	if err := verifySecondFactor(ctx, user, code); err != nil {
+		return err
+	}
+
+	return global.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{"totp_secret": "", "totp_enabled": false}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// createTwoFactorChallenge 密码验证通过后创建登录挑战，客户端凭挑战 Token 提交验证码
+func createTwoFactorChallenge(ctx context.Context, user models.User, device DeviceInfo) (*LoginResponseDTO, error) {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := json.Marshal(twoFactorChallenge{UserID: user.ID, Device: device})
+	if err := global.RDB.Set(ctx, twoFactorChallengeKey(hashToken(token)), data, twoFactorChallengeTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	return &LoginResponseDTO{
+		Username:          user.Username,
+		Nickname:          user.Nickname,
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+	}, nil
+}
+
+// LoginTwoFactor 完成两步验证登录
+func LoginTwoFactor(ctx context.Context, challengeToken, code string) (*LoginResponseDTO, error) {
+	key := twoFactorChallengeKey(hashToken(challengeToken))
+	data, err := global.RDB.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var challenge twoFactorChallenge
+	if err := json.Unmarshal(data, &challenge); err != nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	var user models.User
+	if err := global.DB.WithContext(ctx).First(&user, challenge.UserID).Error; err != nil {
+		return nil, ErrInvalidChallenge
+	}
+	if err := checkLoginLocked(ctx, user.Username, challenge.Device.IP); err != nil {
+		global.RDB.Del(ctx, key)
+		return nil, err
+	}
+
+	if err := verifySecondFactor(ctx, user, code); err != nil {
+		recordLoginFailure(ctx, user.Username, challenge.Device.IP)
+		auditLoginFailure(user.Username, user.ID, challenge.Device, LoginFailBadTOTP)
+
+		// 同一挑战尝试次数过多则作废，需要重新输入密码
+		attemptsKey := twoFactorAttemptsKey(hashToken(challengeToken))
+		if attempts, _ := global.RDB.Incr(ctx, attemptsKey).Result(); attempts >= twoFactorMaxAttempts {
+			global.RDB.Del(ctx, key, attemptsKey)
+		} else if attempts == 1 {
+			global.RDB.Expire(ctx, attemptsKey, twoFactorChallengeTTL)
+		}
+		return nil, err
+	}
+
+	// 挑战只能成功使用一次
+	if n, err := global.RDB.Del(ctx, key).Result(); err != nil || n == 0 {
+		return nil, ErrInvalidChallenge
+	}
+	return completeLogin(ctx, user, challenge.Device)
+}
+
+// verifySecondFactor 校验 TOTP 验证码或恢复码
+func verifySecondFactor(ctx context.Context, user models.User, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now(), 1)
+		if !ok {
+			return ErrInvalidTOTPCode
+		}
+		// 同一验证码不能重复使用 (覆盖允许的时钟偏差范围)
+		fresh, err := global.RDB.SetNX(ctx, totpUsedKey(user.ID, step), "1", 3*totp.Period*time.Second).Result()
+		if err != nil {
+			global.Log.Warn("totp replay check failed", zap.Error(err))
+		} else if !fresh {
+			return ErrInvalidTOTPCode
+		}
+		return nil
+	}
+
+	return useRecoveryCode(ctx, user.ID, code)
+}
+
+// useRecoveryCode 核销一个恢复码
+func useRecoveryCode(ctx context.Context, userID uint, code string) error {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if normalized == "" {
+		return ErrInvalidTOTPCode
+	}
+
+	result := global.DB.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashToken(normalized)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTOTPCode
+	}
+	return nil
+}
+
+// replaceRecoveryCodes 生成新的一组恢复码并作废旧的，明文只在此时返回一次
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := utils.RandomToken(5)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, fmt.Sprintf("%s-%s", raw[:5], raw[5:]))
+		records = append(records, models.RecoveryCode{UserID: userID, CodeHash: hashToken(raw)})
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"go-chat/internal/pkg/imageutil"
+	"go-chat/internal/pkg/utils"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrWrongOldPassword = errors.New("原密码错误")
+	ErrSamePassword     = errors.New("新密码不能与原密码相同")
+	ErrInvalidImage     = errors.New("图片格式不支持或已损坏，请上传 JPEG/PNG/GIF 图片")
+	ErrImageTooLarge    = errors.New("图片尺寸过大")
+)
+
+// avatarMaxPixels 解码前允许的最大像素数 (约 4000 万)，防止解压炸弹
+const avatarMaxPixels = 40_000_000
+
+// UpdateProfile 修改个人资料，传 nil 的字段保持不变
+func (s *UserService) UpdateProfile(ctx context.Context, userID uint, nickname, avatar, email, phone *string) (*models.User, error) {
+	updates := map[string]interface{}{}
+	if nickname != nil {
+		name := strings.TrimSpace(*nickname)
+		if name == "" {
+			return nil, errors.New("昵称不能为空")
+		}
+		updates["nickname"] = name
+	}
+	if avatar != nil {
+		updates["avatar"] = strings.TrimSpace(*avatar)
+	}
+	emailChanged := false
+	if email != nil {
+		var current models.User
+		if err := global.DB.WithContext(ctx).Select("email").First(&current, userID).Error; err != nil {
+			return nil, err
+		}
+		if newEmail := strings.TrimSpace(*email); newEmail != current.Email {
+			// 新邮箱需要重新验证
+			updates["email"] = newEmail
+			updates["email_verified"] = false
+			emailChanged = newEmail != ""
+		}
+	}
+	if phone != nil {
+		updates["phone"] = strings.TrimSpace(*phone)
+	}
+
+	if len(updates) > 0 {
+		if err := global.DB.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if emailChanged {
+		sendEmailVerificationAsync(userID)
+	}
+
+	var user models.User
+	if err := global.DB.WithContext(ctx).First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ChangePassword 修改密码，需验证原密码，成功后下线其他所有设备
+func (s *UserService) ChangePassword(ctx context.Context, userID uint, oldPassword, newPassword, currentSessionID string) error {
+	var user models.User
+	if err := global.DB.WithContext(ctx).Select("id", "password").First(&user, userID).Error; err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(oldPassword)); err != nil {
+		return ErrWrongOldPassword
+	}
+	if oldPassword == newPassword {
+		return ErrSamePassword
+	}
+
+	hashPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := global.DB.WithContext(ctx).Model(&user).Update("password", string(hashPassword)).Error; err != nil {
+		return err
+	}
+
+	// 密码可能已泄露，其他设备一律重新登录
+	if _, err := RevokeOtherSessions(ctx, userID, currentSessionID); err != nil {
+		global.Log.Error("revoke sessions after password change failed", zap.Uint("user_id", userID), zap.Error(err))
+	}
+	return nil
+}
+
+// UploadAvatar 上传头像：校验图片后居中裁剪、缩放为正方形，存储后更新用户头像地址
+func (s *UserService) UploadAvatar(ctx context.Context, userID uint, data []byte) (string, error) {
+	img, format, err := imageutil.Decode(data, avatarMaxPixels)
+	if errors.Is(err, imageutil.ErrTooLarge) {
+		return "", ErrImageTooLarge
+	}
+	if err != nil {
+		return "", ErrInvalidImage
+	}
+
+	size := global.Config.GetInt("avatar.size")
+	if size <= 0 {
+		size = 256
+	}
+	img = imageutil.CropSquare(img)
+	img = imageutil.Fit(img, size, size)
+
+	// 重新编码，顺带去掉原图中的 EXIF 等元数据
+	var buf bytes.Buffer
+	if err := imageutil.Encode(&buf, img, format); err != nil {
+		return "", err
+	}
+
+	name, err := utils.RandomToken(8)
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("avatars/%d/%s%s", userID, name, imageutil.Ext(format))
+	if err := global.Storage.Put(ctx, key, &buf, int64(buf.Len()), imageutil.ContentType(format)); err != nil {
+		return "", err
+	}
+
+	url := global.Storage.URL(key)
+	if err := global.DB.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("avatar", url).Error; err != nil {
+		global.Storage.Delete(ctx, key)
+		return "", err
+	}
+	return url, nil
+}
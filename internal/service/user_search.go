@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go-chat/global"
+	"go-chat/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrSearchTooFrequent = errors.New("搜索过于频繁，请稍后再试")
+
+// likeEscaper 转义 LIKE 通配符，防止用户输入 % _ 扩大匹配范围
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchUsers 分页模糊搜索用户
+// 用户名前缀匹配、昵称包含匹配；手机号、邮箱只做精确匹配，且需对方开启了对应的隐私开关
+// 与搜索者存在拉黑关系 (任意一方) 的用户、已禁用的用户和自己不会出现在结果中
+func SearchUsers(ctx context.Context, userID uint, req SearchUsersReq) (*UserPageDTO, error) {
+	// 限流，防止通过穷举手机号/邮箱枚举用户
+	limit := global.Config.GetInt("search.user_rate_limit")
+	if !allowRate(ctx, userSearchRateKey(userID), limit, time.Minute) {
+		return nil, ErrSearchTooFrequent
+	}
+
+	keyword := strings.TrimSpace(req.Keyword)
+	if keyword == "" {
+		return &UserPageDTO{List: []UserResponseDTO{}, Page: req.Page, PageSize: req.PageSize}, nil
+	}
+	escaped := likeEscaper.Replace(keyword)
+
+	query := global.DB.WithContext(ctx).Model(&models.User{}).
+		Where("id <> ? AND status = 1", userID).
+		Where("id NOT IN (?)", global.DB.Model(&models.Relation{}).
+			Select("owner_id").
+			Where("target_id = ? AND type = 2", userID)).
+		Where("id NOT IN (?)", global.DB.Model(&models.Relation{}).
+			Select("target_id").
+			Where("owner_id = ? AND type = 2", userID)).
+		Where(global.DB.
+						Where("username LIKE ?", escaped+"%").
+						Or("nickname LIKE ?", "%"+escaped+"%").
+						Or("phone = ? AND phone_searchable = ?", keyword, true).
+						Or("email = ? AND email_searchable = ?", keyword, true)).
+		Session(&gorm.Session{}) // 之后 Count 与 Find 复用同一组条件
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	// 用户名完全匹配的排最前，其次是用户名前缀匹配
+	var users []models.User
+	err := query.
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "CASE WHEN username = ? THEN 0 WHEN username LIKE ? THEN 1 ELSE 2 END, id ASC",
+			Vars:               []interface{}{keyword, escaped + "%"},
+			WithoutParentheses: true,
+		}}).
+		Offset((req.Page - 1) * req.PageSize).
+		Limit(req.PageSize).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserPageDTO{
+		List:     ToUserDTOs(users),
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	}, nil
+}
+
+// GetPrivacySettings 获取我的隐私设置
+func GetPrivacySettings(ctx context.Context, userID uint) (*PrivacySettingsDTO, error) {
+	var user models.User
+	if err := global.DB.WithContext(ctx).
+		Select("phone_searchable", "email_searchable").
+		First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return &PrivacySettingsDTO{
+		PhoneSearchable: user.PhoneSearchable,
+		EmailSearchable: user.EmailSearchable,
+	}, nil
+}
+
+// UpdatePrivacySettings 修改隐私设置，未传的字段保持不变
+func UpdatePrivacySettings(ctx context.Context, userID uint, req UpdatePrivacyReq) error {
+	updates := map[string]interface{}{}
+	if req.PhoneSearchable != nil {
+		updates["phone_searchable"] = *req.PhoneSearchable
+	}
+	if req.EmailSearchable != nil {
+		updates["email_searchable"] = *req.EmailSearchable
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	return global.DB.WithContext(ctx).Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(updates).Error
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"go-chat/internal/models"
+	"testing"
+)
+
+// 双方任意一方拉黑了另一方，都不会出现在对方的搜索结果中
+func TestSearchUsersExcludesBlocked(t *testing.T) {
+	setupTestEnv(t, &models.Relation{}, &models.FriendRequest{})
+	ctx := context.Background()
+	ids := createTestUsers(t, 4)
+	me, blockedByMe, blockerOfMe, other := ids[0], ids[1], ids[2], ids[3]
+
+	if err := BlockUser(ctx, me, BlockUserReq{TargetID: blockedByMe}); err != nil {
+		t.Fatalf("block: %v", err)
+	}
+	if err := BlockUser(ctx, blockerOfMe, BlockUserReq{TargetID: me}); err != nil {
+		t.Fatalf("block: %v", err)
+	}
+
+	page, err := SearchUsers(ctx, me, SearchUsersReq{Keyword: "test", Page: 1, PageSize: 20})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if page.Total != 1 || len(page.List) != 1 || page.List[0].ID != other {
+		t.Fatalf("want only user %d, got total %d %+v", other, page.Total, page.List)
+	}
+}
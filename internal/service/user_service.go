@@ -4,7 +4,6 @@ import (
 	"context"
 	"go-chat/global"
 	"go-chat/internal/models"
-	"go-chat/internal/pkg/utils"
 	"time"
 
 	"go.uber.org/zap"
@@ -34,27 +33,58 @@ func (s *UserService) Register(ctx context.Context, username, password, email st
 	if err := global.DB.WithContext(ctx).Create(&newUser).Error; err != nil {
 		return err
 	}
+	if newUser.Email != "" {
+		sendEmailVerificationAsync(newUser.ID)
+	}
 	return nil
 }
 
-func (s *UserService) Login(ctx context.Context, username, password string) (*LoginResponseDTO, error) {
+func (s *UserService) Login(ctx context.Context, username, password string, device DeviceInfo) (*LoginResponseDTO, error) {
+	// 用户名或 IP 失败次数过多，锁定期内直接拒绝，不再校验密码
+	if err := checkLoginLocked(ctx, username, device.IP); err != nil {
+		auditLoginFailure(username, 0, device, LoginFailLocked)
+		return nil, err
+	}
+
 	var user models.User
 	err := global.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error
 	if err != nil {
+		// 用户不存在同样计数，避免通过锁定行为探测用户名是否存在
+		recordLoginFailure(ctx, username, device.IP)
+		auditLoginFailure(username, 0, device, LoginFailUnknownUser)
 		return nil, ErrInvalidPassword
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		recordLoginFailure(ctx, username, device.IP)
+		auditLoginFailure(username, user.ID, device, LoginFailBadPassword)
 		return nil, ErrInvalidPassword
 	}
-	token, err := utils.GenerateToken(user.ID, user.Username)
+	// 密码正确后才提示禁用，避免泄露账号状态
+	if user.Status == 2 {
+		auditLoginFailure(username, user.ID, device, LoginFailDisabled)
+		return nil, ErrUserDisabled
+	}
+
+	// 开启了两步验证：先返回挑战，验证码通过后再签发 Token
+	if user.TOTPEnabled {
+		return createTwoFactorChallenge(ctx, user, device)
+	}
+
+	return completeLogin(ctx, user, device)
+}
+
+// completeLogin 身份验证全部通过后创建会话并签发 Token
+func completeLogin(ctx context.Context, user models.User, device DeviceInfo) (*LoginResponseDTO, error) {
+	clearLoginFailures(ctx, user.Username)
+
+	session, err := createSession(ctx, user.ID, device)
 	if err != nil {
-		return nil, ErrGenerateToken
+		return nil, err
 	}
-	dto := LoginResponseDTO{
-		Token:    token,
-		Username: user.Username,
-		Nickname: user.Nickname,
+	dto, err := issueTokens(ctx, user, session.SessionID)
+	if err != nil {
+		return nil, err
 	}
 	go func() { // 修改最后登录时间不是什么重要的环节，在成功登录后单独开进程处理，设置3秒的timeout防止进程挂起占用资源
 		timeOut, cancel := context.WithTimeout(context.Background(), 3*time.Second) //主进程结束返回后会取消ctx,所以需要挂在新的ctxBackground上
@@ -68,5 +98,5 @@ func (s *UserService) Login(ctx context.Context, username, password string) (*Lo
 				zap.String("username", user.Username))
 		}
 	}()
-	return &dto, nil
+	return dto, nil
 }